@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	gw "github.com/DmitySH/go-http-proxy/gateway/auth"
+	"github.com/DmitySH/go-http-proxy/internal/oauth"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
@@ -33,9 +34,25 @@ func Run() {
 	if regErr := gw.RegisterAuthHandlerFromEndpoint(ctx, gwMux, grpcEndpointAddr, opts); regErr != nil {
 		log.Fatalln("failed to register handler for grpc endpoint:", regErr)
 	}
+	if regErr := gw.RegisterAdminHandlerFromEndpoint(ctx, gwMux, grpcEndpointAddr, opts); regErr != nil {
+		log.Fatalln("failed to register admin handler for grpc endpoint:", regErr)
+	}
+	if regErr := gw.RegisterOAuthHandlerFromEndpoint(ctx, gwMux, grpcEndpointAddr, opts); regErr != nil {
+		log.Fatalln("failed to register oauth handler for grpc endpoint:", regErr)
+	}
+
+	conn, dialErr := grpc.Dial(grpcEndpointAddr, opts...)
+	if dialErr != nil {
+		log.Fatalln("failed to dial grpc endpoint:", dialErr)
+	}
+	defer conn.Close()
+	oauthHandler := oauth.NewHandler(gw.NewOAuthClient(conn), viper.GetString("OAUTH_CONSENT_URL"))
 
 	httpMux := http.NewServeMux()
 	httpMux.Handle("/", gwMux)
+	httpMux.HandleFunc("/oauth/authorize", oauthHandler.Authorize)
+	httpMux.HandleFunc("/oauth/token", oauthHandler.Token)
+	httpMux.HandleFunc("/oauth/device_authorization", oauthHandler.DeviceAuthorization)
 	httpMux.HandleFunc("/docs/swagger.json", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, swaggerFilePath)
 	})
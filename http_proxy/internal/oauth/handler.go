@@ -0,0 +1,298 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	gw "github.com/DmitySH/go-http-proxy/gateway/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+const (
+	oauthErrorDomain     = "oauth"
+	redirectableErrorKey = "redirectable"
+	serverError          = "server_error"
+	invalidRequestError  = "invalid_request"
+	invalidClientError   = "invalid_client"
+)
+
+const (
+	forwardedForHeader     = "x-forwarded-for"
+	gatewayUserAgentHeader = "grpcgateway-user-agent"
+)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// Handler serves OAuth 2.0 endpoints in protocol format: authorization endpoint with
+// query parameters and redirects, token endpoint with form encoded requests and JSON responses.
+type Handler struct {
+	client gw.OAuthClient
+	// consentURL is page of first-party frontend where user logs in and approves request.
+	consentURL string
+}
+
+func NewHandler(client gw.OAuthClient, consentURL string) *Handler {
+	return &Handler{
+		client:     client,
+		consentURL: consentURL,
+	}
+}
+
+// Authorize validates authorization request and sends user agent to consent page with the same query.
+func (h *Handler) Authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	params := &gw.AuthorizationParams{
+		ClientId:            query.Get("client_id"),
+		RedirectUri:         query.Get("redirect_uri"),
+		ResponseType:        query.Get("response_type"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		Nonce:               query.Get("nonce"),
+	}
+
+	_, validateErr := h.client.ValidateAuthorization(outgoingContext(r), &gw.ValidateAuthorizationRequest{Params: params})
+	if validateErr != nil {
+		oauthError, description, redirectable := parseError(validateErr)
+		if !redirectable {
+			http.Error(w, description, httpStatus(oauthError))
+			return
+		}
+
+		redirectURI, buildURIErr := withQuery(params.RedirectUri, url.Values{
+			"error":             {oauthError},
+			"error_description": {description},
+			"state":             {params.State},
+		})
+		if buildURIErr != nil {
+			http.Error(w, buildURIErr.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, redirectURI, http.StatusFound)
+		return
+	}
+
+	consentURI, buildURIErr := withQuery(h.consentURL, query)
+	if buildURIErr != nil {
+		log.Println("can't build consent url:", buildURIErr)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, consentURI, http.StatusFound)
+}
+
+// Token exchanges grant for tokens.
+func (h *Handler) Token(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, invalidRequestError, "token endpoint accepts only POST")
+		return
+	}
+	if parseFormErr := r.ParseForm(); parseFormErr != nil {
+		writeError(w, http.StatusBadRequest, invalidRequestError, parseFormErr.Error())
+		return
+	}
+
+	clientID, clientSecret, basicAuth, credentialsErr := clientCredentials(r)
+	if credentialsErr != nil {
+		writeError(w, http.StatusBadRequest, invalidRequestError, credentialsErr.Error())
+		return
+	}
+
+	tokens, tokenErr := h.client.Token(outgoingContext(r), &gw.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+		Code:         r.PostForm.Get("code"),
+		RedirectUri:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+		DeviceCode:   r.PostForm.Get("device_code"),
+	})
+	if tokenErr != nil {
+		oauthError, description, _ := parseError(tokenErr)
+		if oauthError == invalidClientError && basicAuth {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeError(w, httpStatus(oauthError), oauthError, description)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  tokens.GetAccessToken(),
+		TokenType:    tokens.GetTokenType(),
+		ExpiresIn:    tokens.GetExpiresIn(),
+		RefreshToken: tokens.GetRefreshToken(),
+		Scope:        tokens.GetScope(),
+		IDToken:      tokens.GetIdToken(),
+	})
+}
+
+// DeviceAuthorization starts device flow of RFC 8628 for form encoded request of client.
+func (h *Handler) DeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, invalidRequestError, "device authorization endpoint accepts only POST")
+		return
+	}
+	if parseFormErr := r.ParseForm(); parseFormErr != nil {
+		writeError(w, http.StatusBadRequest, invalidRequestError, parseFormErr.Error())
+		return
+	}
+
+	clientID, clientSecret, basicAuth, credentialsErr := clientCredentials(r)
+	if credentialsErr != nil {
+		writeError(w, http.StatusBadRequest, invalidRequestError, credentialsErr.Error())
+		return
+	}
+
+	start, startErr := h.client.StartDeviceAuthorization(outgoingContext(r), &gw.StartDeviceAuthorizationRequest{
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+		Scope:        r.PostForm.Get("scope"),
+	})
+	if startErr != nil {
+		oauthError, description, _ := parseError(startErr)
+		if oauthError == invalidClientError && basicAuth {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeError(w, httpStatus(oauthError), oauthError, description)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deviceAuthorizationResponse{
+		DeviceCode:              start.GetDeviceCode(),
+		UserCode:                start.GetUserCode(),
+		VerificationURI:         start.GetVerificationUri(),
+		VerificationURIComplete: start.GetVerificationUriComplete(),
+		ExpiresIn:               start.GetExpiresIn(),
+		Interval:                start.GetInterval(),
+	})
+}
+
+// clientCredentials reads client authentication from Authorization header (client_secret_basic)
+// or from form (client_secret_post). Public clients send only client_id in form.
+func clientCredentials(r *http.Request) (string, string, bool, error) {
+	basicID, basicSecret, basicAuth := r.BasicAuth()
+	if !basicAuth {
+		return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), false, nil
+	}
+
+	clientID, unescapeErr := url.QueryUnescape(basicID)
+	if unescapeErr != nil {
+		return "", "", true, unescapeErr
+	}
+	clientSecret, unescapeErr := url.QueryUnescape(basicSecret)
+	if unescapeErr != nil {
+		return "", "", true, unescapeErr
+	}
+
+	return clientID, clientSecret, true, nil
+}
+
+// parseError extracts OAuth error code attached by auth service. Errors without it,
+// like unavailable service, are server errors.
+func parseError(err error) (string, string, bool) {
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetDomain() == oauthErrorDomain {
+			return info.GetReason(), st.Message(), info.GetMetadata()[redirectableErrorKey] == "true"
+		}
+	}
+
+	return serverError, st.Message(), false
+}
+
+func httpStatus(oauthError string) int {
+	switch oauthError {
+	case serverError:
+		return http.StatusInternalServerError
+	case invalidClientError:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// outgoingContext passes client address and user agent the same way gateway does,
+// so auth service records them in sessions and audit log.
+func outgoingContext(r *http.Request) context.Context {
+	clientIP, _, splitErr := net.SplitHostPort(r.RemoteAddr)
+	if splitErr != nil {
+		clientIP = r.RemoteAddr
+	}
+	forwardedFor := clientIP
+	if existing := r.Header.Get(forwardedForHeader); existing != "" {
+		forwardedFor = existing + ", " + clientIP
+	}
+
+	return metadata.AppendToOutgoingContext(r.Context(),
+		forwardedForHeader, forwardedFor,
+		gatewayUserAgentHeader, r.UserAgent())
+}
+
+func withQuery(rawURL string, params url.Values) (string, error) {
+	u, parseErr := url.Parse(rawURL)
+	if parseErr != nil {
+		return "", parseErr
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+func writeError(w http.ResponseWriter, code int, oauthError string, description string) {
+	writeJSON(w, code, errorResponse{Error: oauthError, ErrorDescription: description})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if encodeErr := json.NewEncoder(w).Encode(body); encodeErr != nil {
+		log.Println("can't write response:", encodeErr)
+	}
+}
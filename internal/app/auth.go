@@ -1,22 +1,32 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
 	"github.com/DmitySH/go-auth-service/internal/hashser"
+	"github.com/DmitySH/go-auth-service/internal/mailer"
 	"github.com/DmitySH/go-auth-service/internal/middleware"
+	"github.com/DmitySH/go-auth-service/internal/passkey"
 	"github.com/DmitySH/go-auth-service/internal/repository"
 	"github.com/DmitySH/go-auth-service/internal/server"
 	"github.com/DmitySH/go-auth-service/internal/service"
 	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"github.com/DmitySH/go-auth-service/internal/webhook"
 	"github.com/DmitySH/go-auth-service/pkg/api/auth"
 	"github.com/DmitySH/go-auth-service/pkg/grpcutils"
 	"github.com/DmitySH/go-auth-service/pkg/log"
+	"github.com/DmitySH/go-auth-service/pkg/secretbox"
+	"github.com/golang-jwt/jwt"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	easy "github.com/t-tomalak/logrus-easy-formatter"
 	"google.golang.org/grpc"
 	"io"
 	defaultlog "log"
+	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -25,6 +35,8 @@ const (
 	logPath = "logs/log.log"
 )
 
+const smtpMailer = "smtp"
+
 const day = time.Hour * 24
 
 func Run() {
@@ -64,26 +76,226 @@ func Run() {
 
 	authRepo := repository.NewAuthRepository(db)
 	passwordHasher := hashser.NewBcryptHasher(viper.GetInt("BCRYPT_COST"))
-	tokenGenerator := tokengen.NewJWTGenerator(
-		viper.GetString("JWT_ACCESS_TOKEN_SECRET_KEY"),
-		viper.GetString("JWT_REFRESH_TOKEN_SECRET_KEY"),
-		appName,
-		time.Minute*time.Duration(viper.GetInt("JWT_ACCESS_TOKEN_MINUTES_TTL")),
-		day*time.Duration(viper.GetInt("JWT_REFRESH_TOKEN_DAYS_TTL")))
-
-	authService := service.NewAuthService(logger, authRepo, passwordHasher, tokenGenerator,
-		time.Minute*time.Duration(viper.GetInt("SESSION_CLEAR_INTERVAL_MINUTES")))
+	accessTTL := time.Minute * time.Duration(viper.GetInt("JWT_ACCESS_TOKEN_MINUTES_TTL"))
+	refreshTTL := day * time.Duration(viper.GetInt("JWT_REFRESH_TOKEN_DAYS_TTL"))
+
+	accessKey, accessKeyErr := newAccessSigningKey()
+	if accessKeyErr != nil {
+		logger.Fatal("can't create access token signing key:", accessKeyErr)
+	}
+	refreshKey := tokengen.NewHMACSigningKey("", viper.GetString("JWT_REFRESH_TOKEN_SECRET_KEY"))
+	accessKeys := tokengen.NewKeyRing(accessKey)
+	refreshKeys := tokengen.NewKeyRing(refreshKey)
+
+	if viper.GetBool("JWT_KEY_ROTATION_ENABLED") {
+		keyStorage := repository.NewSigningKeyRepository(db)
+		rotationInterval := time.Hour * time.Duration(viper.GetInt("JWT_KEY_ROTATION_INTERVAL_HOURS"))
+		prepublishPeriod := time.Hour * time.Duration(viper.GetInt("JWT_KEY_PREPUBLISH_HOURS"))
+		checkInterval := time.Minute * time.Duration(viper.GetInt("JWT_KEY_ROTATION_CHECK_MINUTES"))
+		if prepublishPeriod >= rotationInterval {
+			logger.Fatal("key prepublish period must be shorter than rotation interval")
+		}
+		encryptionKey, parseEncryptionKeyErr := tokengen.ParseKeyEncryptionKey(viper.GetString("JWT_KEY_ENCRYPTION_KEY"))
+		if parseEncryptionKeyErr != nil {
+			logger.Fatal("can't parse signing key encryption key:", parseEncryptionKeyErr)
+		}
+
+		startKeyRotation(logger, tokengen.NewKeyRotator(logger, keyStorage, accessKeys, tokengen.KeyRotationConfig{
+			TokenType:          entity.AccessTokenType,
+			Algorithm:          viper.GetString("JWT_ACCESS_TOKEN_ALGORITHM"),
+			RotationInterval:   rotationInterval,
+			PrepublishPeriod:   prepublishPeriod,
+			VerificationPeriod: accessTTL,
+			CheckInterval:      checkInterval,
+			EncryptionKey:      encryptionKey,
+		}, legacySigningKeys(accessKey)...))
+		startKeyRotation(logger, tokengen.NewKeyRotator(logger, keyStorage, refreshKeys, tokengen.KeyRotationConfig{
+			TokenType:          entity.RefreshTokenType,
+			Algorithm:          jwt.SigningMethodHS256.Alg(),
+			RotationInterval:   rotationInterval,
+			PrepublishPeriod:   prepublishPeriod,
+			VerificationPeriod: refreshTTL,
+			CheckInterval:      checkInterval,
+			EncryptionKey:      encryptionKey,
+		}, legacySigningKeys(refreshKey)...))
+	}
+
+	tokenGenerator := tokengen.NewJWTGenerator(accessKeys, refreshKeys, appName, accessTTL, refreshTTL)
+
+	passkeyProvider, createPasskeyProviderErr := passkey.NewWebAuthnProvider(passkey.Config{
+		RPID:          viper.GetString("PASSKEY_RP_ID"),
+		RPDisplayName: viper.GetString("PASSKEY_RP_DISPLAY_NAME"),
+		RPOrigins:     strings.Split(viper.GetString("PASSKEY_RP_ORIGINS"), ","),
+	})
+	if createPasskeyProviderErr != nil {
+		logger.Fatal("can't create passkey provider:", createPasskeyProviderErr)
+	}
+
+	mfaSecretKey, parseMFASecretKeyErr := secretbox.ParseKey(viper.GetString("MFA_SECRET_ENCRYPTION_KEY"))
+	if parseMFASecretKeyErr != nil {
+		logger.Fatal("can't parse totp secret encryption key:", parseMFASecretKeyErr)
+	}
+
+	authService := service.NewAuthService(logger, authRepo, passwordHasher, tokenGenerator, newMailer(), passkeyProvider,
+		service.Config{
+			EnumerationProtection: viper.GetBool("ENUMERATION_PROTECTION_ENABLED"),
+			SessionClearInterval:  time.Minute * time.Duration(viper.GetInt("SESSION_CLEAR_INTERVAL_MINUTES")),
+			Validation: service.ValidationConfig{
+				Strict:   viper.GetBool("STRICT_VALIDATION_ENABLED"),
+				CacheTTL: time.Second * time.Duration(viper.GetInt("STRICT_VALIDATION_CACHE_SECONDS")),
+			},
+			PasswordReset: service.PasswordResetConfig{
+				TokenTTL:         time.Minute * time.Duration(viper.GetInt("PASSWORD_RESET_TOKEN_MINUTES_TTL")),
+				URL:              viper.GetString("PASSWORD_RESET_URL"),
+				MaxSendsPerEmail: viper.GetInt("PASSWORD_RESET_MAX_SENDS_PER_EMAIL"),
+				MaxSendsPerIP:    viper.GetInt("PASSWORD_RESET_MAX_SENDS_PER_IP"),
+			},
+			EmailVerification: service.EmailVerificationConfig{
+				Required:         viper.GetBool("EMAIL_VERIFICATION_REQUIRED"),
+				TokenTTL:         time.Hour * time.Duration(viper.GetInt("EMAIL_VERIFICATION_TOKEN_HOURS_TTL")),
+				URL:              viper.GetString("EMAIL_VERIFICATION_URL"),
+				MaxSendsPerEmail: viper.GetInt("EMAIL_VERIFICATION_MAX_SENDS_PER_EMAIL"),
+				MaxSendsPerIP:    viper.GetInt("EMAIL_VERIFICATION_MAX_SENDS_PER_IP"),
+			},
+			LoginThrottle: service.LoginThrottleConfig{
+				Window:             time.Minute * time.Duration(viper.GetInt("LOGIN_ATTEMPT_WINDOW_MINUTES")),
+				BackoffAfter:       viper.GetInt("LOGIN_BACKOFF_AFTER_FAILURES"),
+				BackoffBase:        time.Second * time.Duration(viper.GetInt("LOGIN_BACKOFF_BASE_SECONDS")),
+				BackoffMax:         time.Second * time.Duration(viper.GetInt("LOGIN_BACKOFF_MAX_SECONDS")),
+				LockoutThreshold:   viper.GetInt("LOGIN_LOCKOUT_THRESHOLD"),
+				IPLockoutThreshold: viper.GetInt("LOGIN_IP_LOCKOUT_THRESHOLD"),
+				LockoutDuration:    time.Minute * time.Duration(viper.GetInt("LOGIN_LOCKOUT_MINUTES")),
+			},
+			MFA: service.MFAConfig{
+				Issuer:              appName,
+				ChallengeTTL:        time.Minute * time.Duration(viper.GetInt("MFA_CHALLENGE_MINUTES_TTL")),
+				RecoveryCodesCount:  viper.GetInt("MFA_RECOVERY_CODES_COUNT"),
+				SecretEncryptionKey: mfaSecretKey,
+			},
+			Passkey: service.PasskeyConfig{
+				CeremonyTTL: time.Minute * time.Duration(viper.GetInt("PASSKEY_CEREMONY_MINUTES_TTL")),
+			},
+			EmailLogin: service.EmailLoginConfig{
+				TokenTTL:         time.Minute * time.Duration(viper.GetInt("EMAIL_LOGIN_TOKEN_MINUTES_TTL")),
+				URL:              viper.GetString("EMAIL_LOGIN_URL"),
+				MaxSendsPerEmail: viper.GetInt("EMAIL_LOGIN_MAX_SENDS_PER_EMAIL"),
+				MaxSendsPerIP:    viper.GetInt("EMAIL_LOGIN_MAX_SENDS_PER_IP"),
+			},
+			Organization: service.OrganizationConfig{
+				InvitationTTL: time.Hour * time.Duration(viper.GetInt("ORG_INVITATION_HOURS_TTL")),
+				InvitationURL: viper.GetString("ORG_INVITATION_URL"),
+			},
+			Admin: service.AdminConfig{
+				StaticKey: viper.GetString("ADMIN_API_KEY"),
+			},
+			OAuth: service.OAuthConfig{
+				CodeTTL:                  time.Second * time.Duration(viper.GetInt("OAUTH_CODE_SECONDS_TTL")),
+				ClientTokenTTL:           time.Minute * time.Duration(viper.GetInt("OAUTH_CLIENT_TOKEN_MINUTES_TTL")),
+				DeviceCodeTTL:            time.Second * time.Duration(viper.GetInt("OAUTH_DEVICE_CODE_SECONDS_TTL")),
+				DevicePollInterval:       time.Second * time.Duration(viper.GetInt("OAUTH_DEVICE_POLL_SECONDS")),
+				DeviceVerificationURL:    viper.GetString("OAUTH_DEVICE_VERIFICATION_URL"),
+				UserCodeLockoutThreshold: viper.GetInt("OAUTH_USER_CODE_LOCKOUT_THRESHOLD"),
+			},
+			OIDC: service.OIDCConfig{
+				Issuer:     viper.GetString("OIDC_ISSUER_URL"),
+				IDTokenTTL: time.Minute * time.Duration(viper.GetInt("OIDC_ID_TOKEN_MINUTES_TTL")),
+			},
+		})
+	if webhookEndpoints := viper.GetString("WEBHOOK_URLS"); webhookEndpoints != "" {
+		startWebhookDispatcher(logger, repository.NewOutboxRepository(db), strings.Split(webhookEndpoints, ","))
+	}
+
+	trustedProxies, parseTrustedProxiesErr := middleware.ParseTrustedProxies(viper.GetString("TRUSTED_PROXY_CIDRS"))
+	if parseTrustedProxiesErr != nil {
+		logger.Fatal("can't parse trusted proxies:", parseTrustedProxiesErr)
+	}
+
 	authServer := server.NewAuthServer(authService)
+	adminServer := server.NewAdminServer(authService)
+	oauthServer := server.NewOAuthServer(authService)
 
 	var opts = []grpc.ServerOption{
-		grpc.UnaryInterceptor(middleware.LogInterceptor),
+		grpc.ChainUnaryInterceptor(
+			middleware.LogInterceptor,
+			middleware.NewClientInfoInterceptor(trustedProxies),
+		),
 	}
 	grpcServer := grpc.NewServer(opts...)
 
 	auth.RegisterAuthServer(grpcServer, authServer)
+	auth.RegisterAdminServer(grpcServer, adminServer)
+	auth.RegisterOAuthServer(grpcServer, oauthServer)
 
 	runSrvErr := grpcutils.RunAndShutdownServer(serverCfg, grpcServer)
 	if runSrvErr != nil {
 		logger.Fatal("run server error:", runSrvErr)
 	}
 }
+
+func newAccessSigningKey() (*tokengen.SigningKey, error) {
+	privateKeyPath := viper.GetString("JWT_ACCESS_TOKEN_PRIVATE_KEY_PATH")
+	if privateKeyPath == "" {
+		return tokengen.NewHMACSigningKey("", viper.GetString("JWT_ACCESS_TOKEN_SECRET_KEY")), nil
+	}
+
+	pemBytes, readKeyErr := os.ReadFile(privateKeyPath)
+	if readKeyErr != nil {
+		return nil, fmt.Errorf("can't read private key: %w", readKeyErr)
+	}
+
+	return tokengen.ParsePrivateKeyPEM(viper.GetString("JWT_ACCESS_TOKEN_KEY_ID"), pemBytes)
+}
+
+func newMailer() service.Mailer {
+	if viper.GetString("MAILER") == smtpMailer {
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     viper.GetString("SMTP_HOST"),
+			Port:     viper.GetInt("SMTP_PORT"),
+			Username: viper.GetString("SMTP_USERNAME"),
+			Password: viper.GetString("SMTP_PASSWORD"),
+			From:     viper.GetString("MAIL_FROM"),
+		})
+	}
+
+	return mailer.NewFileMailer(viper.GetString("MAIL_FILE_PATH"))
+}
+
+func startWebhookDispatcher(logger *logrus.Logger, storage webhook.OutboxStorage, endpoints []string) {
+	secret := viper.GetString("WEBHOOK_SECRET")
+	if secret == "" {
+		logger.Fatal("webhook secret is required when webhook urls are set")
+	}
+	requestTimeout := time.Second * time.Duration(viper.GetInt("WEBHOOK_REQUEST_TIMEOUT_SECONDS"))
+	batchSize := viper.GetInt("WEBHOOK_BATCH_SIZE")
+
+	webhook.NewDispatcher(logger, storage, &http.Client{Timeout: requestTimeout}, webhook.Config{
+		Endpoints:    endpoints,
+		Secret:       secret,
+		BatchSize:    batchSize,
+		PollInterval: time.Second * time.Duration(viper.GetInt("WEBHOOK_POLL_SECONDS")),
+		MaxAttempts:  viper.GetInt("WEBHOOK_MAX_ATTEMPTS"),
+		BackoffBase:  time.Second * time.Duration(viper.GetInt("WEBHOOK_BACKOFF_BASE_SECONDS")),
+		BackoffMax:   time.Second * time.Duration(viper.GetInt("WEBHOOK_BACKOFF_MAX_SECONDS")),
+		Lease:        requestTimeout * time.Duration(batchSize+1),
+	}).Start(context.Background())
+}
+
+func startKeyRotation(logger *logrus.Logger, rotator *tokengen.KeyRotator) {
+	ctx := context.Background()
+	if rotateErr := rotator.Rotate(ctx); rotateErr != nil {
+		logger.Fatal("can't initialize signing keys:", rotateErr)
+	}
+
+	rotator.Start(ctx)
+}
+
+// legacySigningKeys keeps statically configured key valid for verification
+// after rotation is enabled, so outstanding tokens survive the switch.
+// Rotator drops them once tokens they signed have expired.
+func legacySigningKeys(key *tokengen.SigningKey) []*tokengen.SigningKey {
+	if key.IsEmpty() {
+		return nil
+	}
+
+	return []*tokengen.SigningKey{key}
+}
@@ -1,6 +1,9 @@
 package autherrors
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 func Is(err error, status Status) bool {
 	if stError, ok := err.(st); ok {
@@ -23,6 +26,7 @@ func OneOf(err error, statuses ...Status) bool {
 type StatusError struct {
 	errorStatus Status
 	innerError  error
+	retryAfter  time.Duration
 }
 
 func (s *StatusError) Error() string {
@@ -37,6 +41,23 @@ func (s *StatusError) Status() Status {
 	return s.errorStatus
 }
 
+func (s *StatusError) RetryAfter() time.Duration {
+	return s.retryAfter
+}
+
 func NewStatusError(status Status, innerError error) *StatusError {
 	return &StatusError{errorStatus: status, innerError: innerError}
 }
+
+func NewRetryableStatusError(status Status, retryAfter time.Duration) *StatusError {
+	return &StatusError{errorStatus: status, retryAfter: retryAfter}
+}
+
+// RetryAfter returns how long client should wait before retrying, if error specifies it.
+func RetryAfter(err error) (time.Duration, bool) {
+	if retryable, ok := err.(interface{ RetryAfter() time.Duration }); ok && retryable.RetryAfter() > 0 {
+		return retryable.RetryAfter(), true
+	}
+
+	return 0, false
+}
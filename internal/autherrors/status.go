@@ -6,15 +6,69 @@ const (
 	UserExists          Status = "user already exists"
 	UserNotExists       Status = "user doesn't exist"
 	UserInvalidPassword Status = "user password is invalid"
+	TooManyAttempts     Status = "too many failed login attempts"
+	InvalidCredentials  Status = "invalid credentials"
+	UserPending         Status = "user account is pending email verification"
+	UserSuspended       Status = "user is suspended"
+	UserDeleted         Status = "user is deleted"
+
+	InvalidUserStatus       Status = "user status is unknown"
+	InvalidStatusTransition Status = "user status can't be changed this way"
 
 	InvalidToken Status = "token is invalid"
 	InvalidEmail Status = "email is invalid"
 	WeakPassword Status = "password is too weak"
 
+	EmailNotVerified Status = "email is not verified"
+
 	InvalidFingerprint Status = "fingerprint must be correct uuid"
 
 	SessionNotExists Status = "session doesn't exist"
 	InvalidSession   Status = "session is invalid"
+	InvalidSessionID Status = "session id must be correct uuid"
+
+	RefreshTokenReused Status = "refresh token was already used"
+
+	MFAAlreadyEnabled Status = "two-factor authentication is already enabled"
+	MFANotEnrolled    Status = "two-factor authentication enrollment wasn't started"
+	InvalidMFACode    Status = "two-factor authentication code is invalid"
+
+	InvalidPasskey        Status = "passkey is invalid"
+	InvalidCeremonyID     Status = "ceremony id must be correct uuid"
+	CeremonyNotExists     Status = "passkey ceremony doesn't exist or has expired"
+	PasskeyCloneSuspected Status = "passkey sign count didn't increase, authenticator may be cloned"
+
+	AccessDenied      Status = "access denied"
+	InvalidRoleName   Status = "role name must be non-empty and without spaces"
+	InvalidPermission Status = "permission must be non-empty and without spaces"
+	RoleNotExists     Status = "role doesn't exist"
+	RoleNotAssigned   Status = "role isn't assigned to user"
+	AdminRoleLocked   Status = "admin role must keep auth:roles:manage permission and at least one holder"
+
+	InvalidOrganizationName Status = "organization name must be non-empty"
+	NotOrganizationMember   Status = "user isn't member of organization"
+	InvitationNotExists     Status = "invitation doesn't exist, has expired or was sent to another email"
+
+	InvalidOAuthClient       Status = "oauth client must have name and valid redirect uris or scope, client with scope must be confidential"
+	OAuthClientNotExists     Status = "oauth client doesn't exist"
+	InvalidClientCredentials Status = "oauth client authentication failed"
+	InvalidRedirectURI       Status = "redirect uri isn't registered for client"
+	UnsupportedResponseType  Status = "response type isn't supported"
+	InvalidCodeChallenge     Status = "code challenge is required and must use S256 method"
+	InvalidScope             Status = "scope is invalid"
+	UnsupportedGrantType     Status = "grant type isn't supported"
+	InvalidGrant             Status = "authorization code or refresh token is invalid, expired or was issued to another client"
+	InvalidNonce             Status = "nonce is too long"
+	UnauthorizedClient       Status = "oauth client isn't allowed to use client credentials grant"
+	InvalidUserCode          Status = "user code is invalid, expired or was already used"
+	AuthorizationPending     Status = "user hasn't resolved device authorization yet"
+	SlowDown                 Status = "device polls too often, interval is increased"
+	ExpiredDeviceCode        Status = "device code has expired"
+	DeviceAccessDenied       Status = "user denied device authorization"
+	InsufficientScope        Status = "token scope doesn't allow the request"
+
+	InvalidAPIKey   Status = "api key must have name, scope out of user's permissions and expiry in future"
+	APIKeyNotExists Status = "api key doesn't exist"
 )
 
 type st interface {
@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// APIKeyPrefix makes keys recognizable by people and secret scanners, and tells them from JWT.
+const APIKeyPrefix = "gas_"
+
+// APIKey is long-lived credential of user for CLI tools and CI jobs. Only hash of the key is stored.
+type APIKey struct {
+	ID      uuid.UUID
+	UserID  int64 `db:"user_id"`
+	Name    string
+	KeyHash string `db:"key_hash"`
+	// Scope is space separated permissions of the key. User's own permissions still limit it.
+	Scope      string
+	CreatedAt  time.Time  `db:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+}
+
+func (k APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !k.ExpiresAt.After(now)
+}
@@ -0,0 +1,81 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	AuditRegister          = "register"
+	AuditLoginSuccess      = "login_success"
+	AuditLoginFailure      = "login_failure"
+	AuditRefresh           = "refresh"
+	AuditRefreshTokenReuse = "refresh_token_reuse"
+	AuditLogout            = "logout"
+	AuditPasswordChange    = "password_change"
+	AuditPasswordReset     = "password_reset"
+	AuditOAuthAuthorize    = "oauth_authorize"
+	AuditClientToken       = "client_token"
+	AuditAPIKeyCreate      = "api_key_create"
+	AuditAPIKeyRevoke      = "api_key_revoke"
+)
+
+const AuditSuccess = "success"
+
+// AuditGenesisHash is previous hash of the first event in chain.
+var AuditGenesisHash = strings.Repeat("0", sha256.Size*2)
+
+// AuditEvent is record of security relevant action. Events form hash chain:
+// each one hashes the previous one, so changed or removed event breaks the chain.
+type AuditEvent struct {
+	ID   int64
+	Type string `db:"event_type"`
+	// Actor is who performed the action: user email or name of admin credential.
+	Actor string
+	// SubjectUserID is user the action was performed on, 0 if unknown.
+	SubjectUserID int64 `db:"subject_user_id"`
+	Details       string
+	IPAddress     string `db:"ip_address"`
+	UserAgent     string `db:"user_agent"`
+	RequestID     string `db:"request_id"`
+	Outcome       string
+	CreatedAt     time.Time `db:"created_at"`
+	PrevHash      string    `db:"prev_hash"`
+	Hash          string
+}
+
+// ChainHash is hash of event contents and hash of the previous event.
+// Time is hashed in microseconds, as it is stored in database.
+func (e AuditEvent) ChainHash() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n%q\n%q\n%d\n%q\n%q\n%q\n%q\n%q\n%d",
+		e.PrevHash, e.Type, e.Actor, e.SubjectUserID, e.Details,
+		e.IPAddress, e.UserAgent, e.RequestID, e.Outcome, e.CreatedAt.UnixMicro())
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type AuditFilter struct {
+	// SubjectUserID selects events of one user, 0 selects all.
+	SubjectUserID int64
+	From          *time.Time
+	To            *time.Time
+	AfterID       int64
+	Limit         int
+}
+
+type AuditPage struct {
+	Events      []AuditEvent
+	NextAfterID int64
+}
+
+// AuditVerification is result of walking the whole hash chain.
+type AuditVerification struct {
+	Valid         bool
+	CheckedEvents int64
+	// BrokenEventID is the first event that doesn't match the chain.
+	BrokenEventID int64
+}
@@ -6,14 +6,61 @@ import (
 )
 
 type AuthUser struct {
-	ID       int64
-	Email    string
-	Password string
+	ID              int64
+	Email           string
+	Password        string
+	EmailVerified   bool `db:"email_verified"`
+	Status          UserStatus
+	StatusChangedAt *time.Time `db:"status_changed_at"`
+}
+
+// UserFilter selects page of users ordered by id.
+type UserFilter struct {
+	// EmailQuery matches users whose email contains it.
+	EmailQuery string
+	AfterID    int64
+	Limit      int
+}
+
+type UserPage struct {
+	Users []AuthUser
+	// NextAfterID is cursor of the next page, 0 if this page is the last.
+	NextAfterID int64
+}
+
+type PasswordChange struct {
+	CurrentPassword      string
+	NewPassword          string
+	RevokeOtherSessions  bool
+	RevokeCurrentSession bool
 }
 
 type Session struct {
-	ID          uuid.UUID
-	Fingerprint uuid.UUID
-	UserID      int64     `db:"user_id"`
-	ExpiresAt   time.Time `db:"expires_at"`
+	ID              uuid.UUID
+	Fingerprint     uuid.UUID
+	UserID          int64      `db:"user_id"`
+	ExpiresAt       time.Time  `db:"expires_at"`
+	FamilyID        uuid.UUID  `db:"family_id"`
+	RotatedAt       *time.Time `db:"rotated_at"`
+	CreatedAt       time.Time  `db:"created_at"`
+	LastRefreshedAt *time.Time `db:"last_refreshed_at"`
+	IPAddress       string     `db:"ip_address"`
+	UserAgent       string     `db:"user_agent"`
+	// OrgID is organization the session acts in, nil for personal context.
+	OrgID *int64 `db:"org_id"`
+	// ClientID is OAuth client the session was authorized for, nil for first-party login.
+	ClientID *string `db:"client_id"`
+	// Scope limits permissions of client session, space separated.
+	Scope string
+	// AuthTime is when user authenticated, kept across rotations of the session.
+	AuthTime time.Time `db:"auth_time"`
+	// AMR is space separated authentication methods references of RFC 8176.
+	AMR string
+}
+
+// EmailLogin is proof of email ownership: either link token or email with code.
+type EmailLogin struct {
+	Token string
+	Email string
+	Code  string
 }
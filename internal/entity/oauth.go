@@ -0,0 +1,132 @@
+package entity
+
+import "time"
+
+const (
+	CodeResponseType       = "code"
+	AuthorizationCodeGrant = "authorization_code"
+	RefreshTokenGrant      = "refresh_token"
+	ClientCredentialsGrant = "client_credentials"
+	DeviceCodeGrant        = "urn:ietf:params:oauth:grant-type:device_code"
+	PKCEMethodS256         = "S256"
+)
+
+type OAuthClient struct {
+	ID   string
+	Name string
+	// SecretHash is nil for public clients: they can't keep secret and rely on PKCE only.
+	SecretHash   *string   `db:"secret_hash"`
+	RedirectURIs []string  `db:"-"`
+	CreatedAt    time.Time `db:"created_at"`
+	// Scope is what client can get for itself with client credentials grant, empty disables the grant.
+	Scope string
+}
+
+func (c OAuthClient) IsConfidential() bool {
+	return c.SecretHash != nil
+}
+
+// HasRedirectURI matches redirect URI exactly, as OAuth 2.0 Security BCP requires.
+func (c OAuthClient) HasRedirectURI(redirectURI string) bool {
+	for _, uri := range c.RedirectURIs {
+		if uri == redirectURI {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AuthorizationRequest is parameters passed by client to authorization endpoint.
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// AuthorizationPrompt is what consent page shows to user.
+type AuthorizationPrompt struct {
+	ClientName string
+	Scope      string
+	// ConsentGranted is true if user already allowed client the requested scope.
+	ConsentGranted bool
+}
+
+type OAuthConsent struct {
+	UserID    int64  `db:"user_id"`
+	ClientID  string `db:"client_id"`
+	Scope     string
+	GrantedAt time.Time `db:"granted_at"`
+}
+
+type AuthorizationCode struct {
+	CodeHash      string `db:"code_hash"`
+	ClientID      string `db:"client_id"`
+	UserID        int64  `db:"user_id"`
+	RedirectURI   string `db:"redirect_uri"`
+	Scope         string
+	CodeChallenge string    `db:"code_challenge"`
+	ExpiresAt     time.Time `db:"expires_at"`
+	Nonce         string
+	AuthTime      time.Time `db:"auth_time"`
+	AMR           string
+}
+
+// OAuthTokenRequest is parameters passed by client to token endpoint.
+type OAuthTokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	DeviceCode   string
+}
+
+type OAuthTokens struct {
+	TokenPair
+	Scope string
+	// IDToken is issued when scope has openid.
+	IDToken string
+}
+
+const (
+	DevicePending  = "pending"
+	DeviceApproved = "approved"
+	DeviceDenied   = "denied"
+)
+
+// DeviceAuthorization is request of input-constrained device, that user approves on another device
+// by user code, while the device polls token endpoint with device code.
+type DeviceAuthorization struct {
+	DeviceCodeHash string `db:"device_code_hash"`
+	UserCode       string `db:"user_code"`
+	ClientID       string `db:"client_id"`
+	Scope          string
+	Status         string
+	// UserID, AuthTime and AMR are set when user resolves the request.
+	UserID   *int64     `db:"user_id"`
+	AuthTime *time.Time `db:"auth_time"`
+	AMR      string
+	// PollInterval is minimal number of seconds between polls, it grows when device polls faster.
+	PollInterval int        `db:"poll_interval"`
+	LastPolledAt *time.Time `db:"last_polled_at"`
+	ExpiresAt    time.Time  `db:"expires_at"`
+}
+
+// DeviceAuthorizationStart is what device shows to user and uses to poll.
+type DeviceAuthorizationStart struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresAt               time.Time
+	PollInterval            time.Duration
+}
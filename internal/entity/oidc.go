@@ -0,0 +1,59 @@
+package entity
+
+import "time"
+
+const OpenIDScope = "openid"
+
+// Authentication methods references of RFC 8176.
+const (
+	PasswordAMR     = "pwd"
+	OneTimeCodeAMR  = "otp"
+	MultiFactorAMR  = "mfa"
+	HardwareKeyAMR  = "hwk"
+	UserPresenceAMR = "user"
+)
+
+// Authentication context classes reported in acr claim.
+const (
+	SingleFactorACR = "urn:go-auth-service:acr:1fa"
+	MultiFactorACR  = "urn:go-auth-service:acr:2fa"
+)
+
+type IDTokenClaims struct {
+	Issuer        string
+	Subject       string
+	Audience      string
+	Email         string
+	EmailVerified bool
+	Nonce         string
+	AuthTime      time.Time
+	AMR           []string
+	ACR           string
+	IssuedAt      time.Time
+	ExpiresAt     time.Time
+}
+
+type UserInfo struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// OpenIDConfiguration is provider metadata of OpenID Connect Discovery.
+type OpenIDConfiguration struct {
+	Issuer                            string
+	AuthorizationEndpoint             string
+	TokenEndpoint                     string
+	UserInfoEndpoint                  string
+	DeviceAuthorizationEndpoint       string
+	JWKSURI                           string
+	ScopesSupported                   []string
+	ResponseTypesSupported            []string
+	GrantTypesSupported               []string
+	SubjectTypesSupported             []string
+	IDTokenSigningAlgValuesSupported  []string
+	TokenEndpointAuthMethodsSupported []string
+	CodeChallengeMethodsSupported     []string
+	ClaimsSupported                   []string
+	ACRValuesSupported                []string
+}
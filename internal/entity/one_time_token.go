@@ -0,0 +1,24 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	PasswordResetPurpose     = "password_reset"
+	EmailVerificationPurpose = "email_verification"
+	MFAChallengePurpose      = "mfa_challenge"
+	EmailLoginPurpose        = "email_login"
+)
+
+type OneTimeToken struct {
+	TokenHash string `db:"token_hash"`
+	UserID    int64  `db:"user_id"`
+	Purpose   string
+	CreatedAt time.Time  `db:"created_at"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	// Fingerprint binds token to device that requested it.
+	Fingerprint *uuid.UUID
+}
@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+const (
+	ManageMembersPermission = "org:members:manage"
+	// OrgOwnerRole is given to user that creates organization.
+	OrgOwnerRole  = "org_owner"
+	OrgMemberRole = "org_member"
+)
+
+type Organization struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Membership is user's place in organization. Role applies only inside that organization.
+type Membership struct {
+	OrgID   int64  `db:"org_id"`
+	OrgName string `db:"org_name"`
+	UserID  int64  `db:"user_id"`
+	Role    string
+}
+
+type OrganizationInvitation struct {
+	TokenHash  string `db:"token_hash"`
+	OrgID      int64  `db:"org_id"`
+	Email      string
+	Role       string
+	InvitedBy  int64      `db:"invited_by"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	AcceptedAt *time.Time `db:"accepted_at"`
+}
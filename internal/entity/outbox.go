@@ -0,0 +1,45 @@
+package entity
+
+import "time"
+
+const (
+	UserRegisteredEvent      = "user.registered"
+	UserPasswordChangedEvent = "user.password_changed"
+	UserDeletedEvent         = "user.deleted"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// OutboxEvent is domain event saved in the same transaction as the change it describes.
+type OutboxEvent struct {
+	ID        int64
+	Type      string `db:"event_type"`
+	Payload   []byte
+	CreatedAt time.Time `db:"created_at"`
+	// ScheduledAt is when deliveries to webhook endpoints were created for event.
+	ScheduledAt *time.Time `db:"scheduled_at"`
+}
+
+type UserEventPayload struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+}
+
+// WebhookDelivery tracks delivery of one event to one endpoint.
+type WebhookDelivery struct {
+	EventID       int64 `db:"event_id"`
+	Endpoint      string
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time  `db:"next_attempt_at"`
+	LastError     string     `db:"last_error"`
+	DeliveredAt   *time.Time `db:"delivered_at"`
+
+	EventType      string    `db:"event_type"`
+	Payload        []byte    `db:"payload"`
+	EventCreatedAt time.Time `db:"event_created_at"`
+}
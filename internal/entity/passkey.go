@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	PasskeyRegistrationPurpose = "registration"
+	PasskeyLoginPurpose        = "login"
+)
+
+type PasskeyCredential struct {
+	ID              []byte
+	UserID          int64  `db:"user_id"`
+	PublicKey       []byte `db:"public_key"`
+	AttestationType string `db:"attestation_type"`
+	AAGUID          []byte
+	SignCount       uint32 `db:"sign_count"`
+	// Transports is comma separated list of transports reported by authenticator.
+	Transports     string
+	BackupEligible bool       `db:"backup_eligible"`
+	BackupState    bool       `db:"backup_state"`
+	CreatedAt      time.Time  `db:"created_at"`
+	LastUsedAt     *time.Time `db:"last_used_at"`
+	// CloneWarning is set by assertion when sign count didn't grow, so authenticator may be cloned.
+	CloneWarning bool `db:"-"`
+}
+
+// PasskeyCeremony holds server side state between begin and finish steps of
+// WebAuthn registration or assertion. UserID is nil for discoverable login.
+type PasskeyCeremony struct {
+	ID          uuid.UUID
+	UserID      *int64 `db:"user_id"`
+	Purpose     string
+	SessionData []byte    `db:"session_data"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// PasskeyChallenge is result of begin step: options are passed to browser as is.
+type PasskeyChallenge struct {
+	CeremonyID  uuid.UUID
+	Options     []byte
+	SessionData []byte
+}
@@ -0,0 +1,37 @@
+package entity
+
+import "time"
+
+const (
+	ManageRolesPermission   = "auth:roles:manage"
+	ManageUsersPermission   = "auth:users:manage"
+	ReadAuditPermission     = "auth:audit:read"
+	ManageClientsPermission = "auth:clients:manage"
+)
+
+// AdminRole is built-in role. It always keeps ManageRolesPermission and at least one holder,
+// so roles can't be locked out of management.
+const AdminRole = "admin"
+
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string  `db:"-"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// UserAccess is what user is allowed to do: it is put into access token.
+type UserAccess struct {
+	Roles       []string
+	Permissions []string
+}
+
+func (a UserAccess) HasPermission(permission string) bool {
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+type SigningKey struct {
+	ID          string
+	TokenType   string `db:"token_type"`
+	Algorithm   string
+	Material    string
+	Encrypted   bool
+	CreatedAt   time.Time `db:"created_at"`
+	ActivatesAt time.Time `db:"activates_at"`
+	RetiresAt   time.Time `db:"retires_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
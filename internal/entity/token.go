@@ -1,6 +1,9 @@
 package entity
 
-import "time"
+import (
+	"github.com/google/uuid"
+	"time"
+)
 
 type TokenPair struct {
 	Access  Token
@@ -11,3 +14,39 @@ type Token struct {
 	Token     string
 	ExpiresAt time.Time
 }
+
+// Principals access token can be issued to.
+const (
+	UserPrincipal   = "user"
+	ClientPrincipal = "client"
+)
+
+type AccessClaims struct {
+	TokenID uuid.UUID
+	// Principal is UserPrincipal or ClientPrincipal. Tokens of client principal carry only ClientID and Permissions.
+	Principal string
+	// APIKey is set when credential is API key of user, TokenID is then ID of the key.
+	APIKey        bool
+	Email         string
+	EmailVerified bool
+	SessionID     uuid.UUID
+	Roles         []string
+	Permissions   []string
+	OrgID         int64
+	ClientID      string
+	AuthTime      time.Time
+	AMR           []string
+	ExpiresAt     time.Time
+}
+
+type JSONWebKey struct {
+	Kty string
+	Use string
+	Kid string
+	Alg string
+	N   string
+	E   string
+	Crv string
+	X   string
+	Y   string
+}
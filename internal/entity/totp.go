@@ -0,0 +1,30 @@
+package entity
+
+import "time"
+
+type UserTOTP struct {
+	UserID int64 `db:"user_id"`
+	// Secret is sealed with MFA encryption key unless Encrypted is false: then it was stored
+	// before secrets were encrypted and is sealed once it's read.
+	Secret       string
+	Encrypted    bool
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+}
+
+func (t UserTOTP) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// LoginResult holds either token pair or MFA challenge token when second factor is required.
+type LoginResult struct {
+	Tokens      TokenPair
+	MFARequired bool
+	MFAToken    Token
+}
@@ -0,0 +1,34 @@
+package entity
+
+type UserStatus string
+
+const (
+	// UserPending is account that waits for email verification.
+	UserPending   UserStatus = "pending"
+	UserActive    UserStatus = "active"
+	UserSuspended UserStatus = "suspended"
+	// UserDeleted is final: account keeps its email, but can't be used anymore.
+	UserDeleted UserStatus = "deleted"
+)
+
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	UserPending:   {UserActive, UserSuspended, UserDeleted},
+	UserActive:    {UserSuspended, UserDeleted},
+	UserSuspended: {UserActive, UserDeleted},
+	UserDeleted:   {},
+}
+
+func (s UserStatus) Valid() bool {
+	_, ok := userStatusTransitions[s]
+	return ok
+}
+
+func (s UserStatus) CanTransitionTo(next UserStatus) bool {
+	for _, allowed := range userStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileMailer appends mails to a file instead of sending them. Use it for local development only.
+type FileMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (m *FileMailer) Send(_ context.Context, mail entity.Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, openErr := os.OpenFile(m.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if openErr != nil {
+		return fmt.Errorf("can't open mail file: %w", openErr)
+	}
+	defer f.Close()
+
+	_, writeErr := fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC1123Z), mail.To, mail.Subject, mail.Body)
+	if writeErr != nil {
+		return fmt.Errorf("can't write mail: %w", writeErr)
+	}
+
+	return nil
+}
+
+func NewFileMailer(path string) *FileMailer {
+	return &FileMailer{path: path}
+}
@@ -0,0 +1,90 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, mail entity.Mail) error {
+	addr := net.JoinHostPort(m.cfg.Host, fmt.Sprint(m.cfg.Port))
+
+	var dialer net.Dialer
+	conn, dialErr := dialer.DialContext(ctx, "tcp", addr)
+	if dialErr != nil {
+		return fmt.Errorf("can't connect to smtp server: %w", dialErr)
+	}
+
+	client, clientErr := smtp.NewClient(conn, m.cfg.Host)
+	if clientErr != nil {
+		conn.Close()
+		return fmt.Errorf("can't create smtp client: %w", clientErr)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if tlsErr := client.StartTLS(&tls.Config{ServerName: m.cfg.Host}); tlsErr != nil {
+			return fmt.Errorf("can't start tls: %w", tlsErr)
+		}
+	}
+
+	if m.cfg.Username != "" {
+		auth := smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+		if authErr := client.Auth(auth); authErr != nil {
+			return fmt.Errorf("can't authenticate: %w", authErr)
+		}
+	}
+
+	if mailErr := client.Mail(m.cfg.From); mailErr != nil {
+		return fmt.Errorf("can't set sender: %w", mailErr)
+	}
+	if rcptErr := client.Rcpt(mail.To); rcptErr != nil {
+		return fmt.Errorf("can't set recipient: %w", rcptErr)
+	}
+
+	w, dataErr := client.Data()
+	if dataErr != nil {
+		return fmt.Errorf("can't start data: %w", dataErr)
+	}
+	if _, writeErr := w.Write(m.message(mail)); writeErr != nil {
+		return fmt.Errorf("can't write message: %w", writeErr)
+	}
+	if closeErr := w.Close(); closeErr != nil {
+		return fmt.Errorf("can't send message: %w", closeErr)
+	}
+
+	return client.Quit()
+}
+
+func (m *SMTPMailer) message(mail entity.Mail) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + m.cfg.From + "\r\n")
+	b.WriteString("To: " + mail.To + "\r\n")
+	b.WriteString("Subject: " + mail.Subject + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
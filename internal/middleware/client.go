@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"strings"
+)
+
+const (
+	forwardedForHeader     = "x-forwarded-for"
+	gatewayUserAgentHeader = "grpcgateway-user-agent"
+	userAgentHeader        = "user-agent"
+)
+
+// NewClientInfoInterceptor puts client IP and user agent into context. X-Forwarded-For is read
+// only from peers in trustedProxies: entries are taken from the right while they were appended
+// by trusted proxies, so the first untrusted address is the client and clients can't spoof it.
+func NewClientInfoInterceptor(trustedProxies []*net.IPNet) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+
+		ctx = context.WithValue(ctx, "client_ip", clientIP(ctx, md, trustedProxies))
+		ctx = context.WithValue(ctx, "user_agent", userAgent(md))
+
+		return handler(ctx, req)
+	}
+}
+
+// ParseTrustedProxies parses comma separated list of CIDRs.
+func ParseTrustedProxies(cidrs string) ([]*net.IPNet, error) {
+	var trustedProxies []*net.IPNet
+	for _, cidr := range strings.Split(cidrs, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		_, network, parseErr := net.ParseCIDR(cidr)
+		if parseErr != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network %q: %w", cidr, parseErr)
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+
+	return trustedProxies, nil
+}
+
+func clientIP(ctx context.Context, md metadata.MD, trustedProxies []*net.IPNet) string {
+	ip := peerIP(ctx)
+	if ip == nil {
+		return ""
+	}
+
+	var forwarded []string
+	for _, header := range md.Get(forwardedForHeader) {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	for i := len(forwarded) - 1; i >= 0 && isTrustedProxy(ip, trustedProxies); i-- {
+		forwardedIP := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if forwardedIP == nil {
+			break
+		}
+		ip = forwardedIP
+	}
+
+	return ip.String()
+}
+
+func peerIP(ctx context.Context) net.IP {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+
+	host, _, splitErr := net.SplitHostPort(p.Addr.String())
+	if splitErr != nil {
+		host = p.Addr.String()
+	}
+
+	return net.ParseIP(host)
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func userAgent(md metadata.MD) string {
+	if gatewayUA := md.Get(gatewayUserAgentHeader); len(gatewayUA) > 0 {
+		return gatewayUA[0]
+	}
+	if ua := md.Get(userAgentHeader); len(ua) > 0 {
+		return ua[0]
+	}
+
+	return ""
+}
@@ -0,0 +1,109 @@
+package passkey
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"strings"
+)
+
+const (
+	userHandleSize      = 8
+	transportsSeparator = ","
+)
+
+// webAuthnUser adapts user and their passkeys to webauthn.User.
+type webAuthnUser struct {
+	user        entity.AuthUser
+	credentials []webauthn.Credential
+}
+
+func newWebAuthnUser(user entity.AuthUser, passkeys []entity.PasskeyCredential) *webAuthnUser {
+	credentials := make([]webauthn.Credential, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		credentials = append(credentials, webauthn.Credential{
+			ID:              passkey.ID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       splitTransports(passkey.Transports),
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: passkey.SignCount,
+			},
+		})
+	}
+
+	return &webAuthnUser{user: user, credentials: credentials}
+}
+
+// WebAuthnID is user handle stored by authenticator. User id is used instead of email,
+// so handle doesn't disclose personal data and survives email change.
+func (u *webAuthnUser) WebAuthnID() []byte {
+	handle := make([]byte, userHandleSize)
+	binary.BigEndian.PutUint64(handle, uint64(u.user.ID))
+
+	return handle
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func parseUserHandle(handle []byte) (int64, error) {
+	if len(handle) != userHandleSize {
+		return 0, errors.New("invalid user handle")
+	}
+
+	return int64(binary.BigEndian.Uint64(handle)), nil
+}
+
+func convertCredential(userID int64, credential *webauthn.Credential) entity.PasskeyCredential {
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	return entity.PasskeyCredential{
+		ID:              credential.ID,
+		UserID:          userID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, transportsSeparator),
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+}
+
+func splitTransports(transports string) []protocol.AuthenticatorTransport {
+	if transports == "" {
+		return nil
+	}
+
+	parts := strings.Split(transports, transportsSeparator)
+	converted := make([]protocol.AuthenticatorTransport, 0, len(parts))
+	for _, part := range parts {
+		converted = append(converted, protocol.AuthenticatorTransport(part))
+	}
+
+	return converted
+}
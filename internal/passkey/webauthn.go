@@ -0,0 +1,161 @@
+package passkey
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+type Config struct {
+	// RPID is domain of the site, passkeys are bound to it.
+	RPID          string
+	RPDisplayName string
+	RPOrigins     []string
+}
+
+// WebAuthnProvider runs WebAuthn ceremonies. It keeps no state: session data of begin step
+// is returned to caller and has to be passed back to finish step.
+type WebAuthnProvider struct {
+	webAuthn *webauthn.WebAuthn
+}
+
+func NewWebAuthnProvider(cfg Config) (*WebAuthnProvider, error) {
+	webAuthn, createErr := webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		// Passkeys replace password, so they must be discoverable and verify user.
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		},
+	})
+	if createErr != nil {
+		return nil, fmt.Errorf("can't create webauthn relying party: %w", createErr)
+	}
+
+	return &WebAuthnProvider{webAuthn: webAuthn}, nil
+}
+
+func (p *WebAuthnProvider) BeginRegistration(user entity.AuthUser,
+	passkeys []entity.PasskeyCredential) (entity.PasskeyChallenge, error) {
+	waUser := newWebAuthnUser(user, passkeys)
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(waUser.credentials))
+	for _, credential := range waUser.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, beginErr := p.webAuthn.BeginRegistration(waUser, webauthn.WithExclusions(exclusions))
+	if beginErr != nil {
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't begin registration: %w", beginErr)
+	}
+
+	return newChallenge(creation, session)
+}
+
+func (p *WebAuthnProvider) FinishRegistration(user entity.AuthUser, passkeys []entity.PasskeyCredential,
+	sessionData []byte, response []byte) (entity.PasskeyCredential, error) {
+	var session webauthn.SessionData
+	if unmarshalErr := json.Unmarshal(sessionData, &session); unmarshalErr != nil {
+		return entity.PasskeyCredential{}, fmt.Errorf("can't decode session data: %w", unmarshalErr)
+	}
+
+	parsedResponse, parseErr := protocol.ParseCredentialCreationResponseBody(bytes.NewReader(response))
+	if parseErr != nil {
+		return entity.PasskeyCredential{}, invalidPasskeyError(parseErr)
+	}
+
+	credential, createErr := p.webAuthn.CreateCredential(newWebAuthnUser(user, passkeys), session, parsedResponse)
+	if createErr != nil {
+		return entity.PasskeyCredential{}, invalidPasskeyError(createErr)
+	}
+
+	return convertCredential(user.ID, credential), nil
+}
+
+func (p *WebAuthnProvider) BeginLogin() (entity.PasskeyChallenge, error) {
+	assertion, session, beginErr := p.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired))
+	if beginErr != nil {
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't begin login: %w", beginErr)
+	}
+
+	return newChallenge(assertion, session)
+}
+
+// FinishLogin verifies assertion and returns its owner with passkey's updated sign count.
+// findUser resolves user handle of discoverable credential to user and their passkeys.
+func (p *WebAuthnProvider) FinishLogin(sessionData []byte, response []byte,
+	findUser service.PasskeyUserFinder) (entity.AuthUser, entity.PasskeyCredential, error) {
+	var session webauthn.SessionData
+	if unmarshalErr := json.Unmarshal(sessionData, &session); unmarshalErr != nil {
+		return entity.AuthUser{}, entity.PasskeyCredential{}, fmt.Errorf("can't decode session data: %w", unmarshalErr)
+	}
+
+	parsedResponse, parseErr := protocol.ParseCredentialRequestResponseBody(bytes.NewReader(response))
+	if parseErr != nil {
+		return entity.AuthUser{}, entity.PasskeyCredential{}, invalidPasskeyError(parseErr)
+	}
+
+	var (
+		owner   entity.AuthUser
+		findErr error
+	)
+	handler := func(_, userHandle []byte) (webauthn.User, error) {
+		userID, parseHandleErr := parseUserHandle(userHandle)
+		if parseHandleErr != nil {
+			return nil, parseHandleErr
+		}
+
+		var passkeys []entity.PasskeyCredential
+		owner, passkeys, findErr = findUser(userID)
+		if findErr != nil {
+			return nil, findErr
+		}
+
+		return newWebAuthnUser(owner, passkeys), nil
+	}
+
+	credential, validateErr := p.webAuthn.ValidateDiscoverableLogin(handler, session, parsedResponse)
+	if findErr != nil && !errors.Is(findErr, service.ErrEntityNotFound) {
+		return entity.AuthUser{}, entity.PasskeyCredential{}, findErr
+	}
+	if validateErr != nil {
+		return entity.AuthUser{}, entity.PasskeyCredential{}, invalidPasskeyError(validateErr)
+	}
+
+	passkey := convertCredential(owner.ID, credential)
+	passkey.CloneWarning = credential.Authenticator.CloneWarning
+
+	return owner, passkey, nil
+}
+
+func newChallenge(options any, session *webauthn.SessionData) (entity.PasskeyChallenge, error) {
+	encodedOptions, marshalOptionsErr := json.Marshal(options)
+	if marshalOptionsErr != nil {
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't encode options: %w", marshalOptionsErr)
+	}
+
+	sessionData, marshalSessionErr := json.Marshal(session)
+	if marshalSessionErr != nil {
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't encode session data: %w", marshalSessionErr)
+	}
+
+	return entity.PasskeyChallenge{Options: encodedOptions, SessionData: sessionData}, nil
+}
+
+func invalidPasskeyError(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) && protocolErr.DevInfo != "" {
+		return fmt.Errorf("%w: %s: %s", service.ErrInvalidPasskey, protocolErr.Details, protocolErr.DevInfo)
+	}
+
+	return fmt.Errorf("%w: %v", service.ErrInvalidPasskey, err)
+}
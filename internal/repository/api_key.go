@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+const apiKeyTable = "api_key"
+
+func (r *AuthRepository) CreateAPIKey(ctx context.Context, key entity.APIKey) error {
+	createKeySQL, args, buildSqlErr := r.psql.Insert(apiKeyTable).
+		Columns("id", "user_id", "name", "key_hash", "scope", "created_at", "expires_at").
+		Values(key.ID, key.UserID, key.Name, key.KeyHash, key.Scope, key.CreatedAt, key.ExpiresAt).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, createKeyErr := r.db.ExecContext(ctx, createKeySQL, args...); createKeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createKeyErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetUserAPIKeys(ctx context.Context, userID int64) ([]entity.APIKey, error) {
+	getKeysSQL, args, buildSqlErr := r.psql.Select("*").
+		From(apiKeyTable).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("created_at").
+		ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var keys []entity.APIKey
+	if getKeysErr := r.db.SelectContext(ctx, &keys, getKeysSQL, args...); getKeysErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getKeysErr)
+	}
+
+	return keys, nil
+}
+
+func (r *AuthRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (entity.APIKey, error) {
+	getKeySQL, args, buildSqlErr := r.psql.Select("*").
+		From(apiKeyTable).
+		Where(sq.Eq{"key_hash": keyHash}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.APIKey{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var key entity.APIKey
+	getKeyErr := r.db.GetContext(ctx, &key, getKeySQL, args...)
+	if errors.Is(getKeyErr, sql.ErrNoRows) {
+		return entity.APIKey{}, service.ErrEntityNotFound
+	}
+	if getKeyErr != nil {
+		return entity.APIKey{}, fmt.Errorf("error during sql executing: %w", getKeyErr)
+	}
+
+	return key, nil
+}
+
+// TouchAPIKey records use of the key, but not more often than once after usedBefore,
+// so validation doesn't write on every request.
+func (r *AuthRepository) TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time,
+	usedBefore time.Time) error {
+	touchKeySQL, args, buildSqlErr := r.psql.Update(apiKeyTable).
+		Set("last_used_at", usedAt).
+		Where(sq.Eq{"id": keyID}).
+		Where(sq.Or{sq.Eq{"last_used_at": nil}, sq.Lt{"last_used_at": usedBefore}}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, touchKeyErr := r.db.ExecContext(ctx, touchKeySQL, args...); touchKeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", touchKeyErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteUserAPIKey(ctx context.Context, userID int64, keyID uuid.UUID) error {
+	deleteKeySQL, args, buildSqlErr := r.psql.Delete(apiKeyTable).
+		Where(sq.Eq{"id": keyID, "user_id": userID}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, deleteKeyErr := r.db.ExecContext(ctx, deleteKeySQL, args...)
+	if deleteKeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteKeyErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrEntityNotFound
+	}
+
+	return nil
+}
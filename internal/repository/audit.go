@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	sq "github.com/Masterminds/squirrel"
+)
+
+const auditEventTable = "audit_event"
+
+// auditChainLockID is key of advisory lock that serializes appends to audit hash chain.
+const auditChainLockID = 7_100_190
+
+// AppendAuditEvent links event to the last one in chain and saves it.
+func (r *AuthRepository) AppendAuditEvent(ctx context.Context, event entity.AuditEvent) (entity.AuditEvent, error) {
+	lastHashSQL, lastHashArgs, buildSqlErr := r.psql.Select("hash").
+		From(auditEventTable).
+		OrderBy("id DESC").
+		Limit(1).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.AuditEvent{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return entity.AuditEvent{}, fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	if _, lockErr := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLockID); lockErr != nil {
+		return entity.AuditEvent{}, fmt.Errorf("can't lock audit chain: %w", lockErr)
+	}
+
+	var prevHash string
+	getLastHashErr := tx.GetContext(ctx, &prevHash, lastHashSQL, lastHashArgs...)
+	if errors.Is(getLastHashErr, sql.ErrNoRows) {
+		prevHash = entity.AuditGenesisHash
+	} else if getLastHashErr != nil {
+		return entity.AuditEvent{}, fmt.Errorf("error during sql execution: %w", getLastHashErr)
+	}
+
+	event.PrevHash = prevHash
+	event.Hash = event.ChainHash()
+
+	appendEventSQL, appendEventArgs, buildSqlErr := r.psql.Insert(auditEventTable).
+		Columns("event_type", "actor", "subject_user_id", "details", "ip_address",
+			"user_agent", "request_id", "outcome", "created_at", "prev_hash", "hash").
+		Values(event.Type, event.Actor, event.SubjectUserID, event.Details, event.IPAddress,
+			event.UserAgent, event.RequestID, event.Outcome, event.CreatedAt, event.PrevHash, event.Hash).
+		Suffix("RETURNING id").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.AuditEvent{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if appendEventErr := tx.GetContext(ctx, &event.ID, appendEventSQL, appendEventArgs...); appendEventErr != nil {
+		return entity.AuditEvent{}, fmt.Errorf("error during sql execution: %w", appendEventErr)
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return entity.AuditEvent{}, fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return event, nil
+}
+
+func (r *AuthRepository) GetAuditEvents(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	getEvents := r.psql.Select("*").
+		From(auditEventTable).
+		Where(sq.Gt{"id": filter.AfterID}).
+		OrderBy("id").
+		Limit(uint64(filter.Limit))
+	if filter.SubjectUserID != 0 {
+		getEvents = getEvents.Where(sq.Eq{"subject_user_id": filter.SubjectUserID})
+	}
+	if filter.From != nil {
+		getEvents = getEvents.Where(sq.GtOrEq{"created_at": *filter.From})
+	}
+	if filter.To != nil {
+		getEvents = getEvents.Where(sq.Lt{"created_at": *filter.To})
+	}
+
+	getEventsSQL, args, buildSqlErr := getEvents.ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var events []entity.AuditEvent
+	if getEventsErr := r.db.SelectContext(ctx, &events, getEventsSQL, args...); getEventsErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getEventsErr)
+	}
+
+	return events, nil
+}
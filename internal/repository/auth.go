@@ -68,19 +68,89 @@ func (r *AuthRepository) GetUserByID(ctx context.Context, id int64) (entity.Auth
 	return user, nil
 }
 
-func (r *AuthRepository) CreateUser(ctx context.Context, user entity.AuthUser) error {
+func (r *AuthRepository) CreateUser(ctx context.Context, user entity.AuthUser) (int64, error) {
 	createUserSQL, args, buildSqlErr := r.psql.Insert(userTable).
-		Columns("email", "password").
-		Values(user.Email, user.Password).
+		Columns("email", "password", "email_verified", "status").
+		Values(user.Email, user.Password, user.EmailVerified, user.Status).
+		Suffix("RETURNING id").
 		ToSql()
 
 	if buildSqlErr != nil {
-		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+		return 0, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return 0, fmt.Errorf("can't begin transaction: %w", beginTxErr)
 	}
+	defer tx.Rollback()
 
-	_, createUserErr := r.db.ExecContext(ctx, createUserSQL, args...)
+	var userID int64
+	createUserErr := tx.GetContext(ctx, &userID, createUserSQL, args...)
 	if createUserErr != nil {
-		return fmt.Errorf("error during sql execution: %w", createUserErr)
+		return 0, fmt.Errorf("error during sql execution: %w", createUserErr)
+	}
+
+	insertEventErr := r.insertOutboxEvent(ctx, tx, entity.UserRegisteredEvent,
+		entity.UserEventPayload{UserID: userID, Email: user.Email}, time.Now())
+	if insertEventErr != nil {
+		return 0, insertEventErr
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return 0, fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return userID, nil
+}
+
+func (r *AuthRepository) UpdateUserPassword(ctx context.Context, userID int64, hashedPassword string) error {
+	updatePasswordSQL, args, buildSqlErr := r.psql.Update(userTable).
+		Set("password", hashedPassword).
+		Where(sq.Eq{"id": userID}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	_, updatePasswordErr := tx.ExecContext(ctx, updatePasswordSQL, args...)
+	if updatePasswordErr != nil {
+		return fmt.Errorf("error during sql execution: %w", updatePasswordErr)
+	}
+
+	insertEventErr := r.insertOutboxEvent(ctx, tx, entity.UserPasswordChangedEvent,
+		entity.UserEventPayload{UserID: userID}, time.Now())
+	if insertEventErr != nil {
+		return insertEventErr
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) SetUserEmailVerified(ctx context.Context, userID int64) error {
+	verifyEmailSQL, args, buildSqlErr := r.psql.Update(userTable).
+		Set("email_verified", true).
+		Where(sq.Eq{"id": userID}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, verifyEmailErr := r.db.ExecContext(ctx, verifyEmailSQL, args...)
+	if verifyEmailErr != nil {
+		return fmt.Errorf("error during sql execution: %w", verifyEmailErr)
 	}
 
 	return nil
@@ -88,8 +158,12 @@ func (r *AuthRepository) CreateUser(ctx context.Context, user entity.AuthUser) e
 
 func (r *AuthRepository) CreateSession(ctx context.Context, session entity.Session) error {
 	createSessionSQL, args, buildSqlErr := r.psql.Insert(sessionTable).
-		Columns("id", "user_id", "fingerprint", "expires_at").
-		Values(session.ID, session.UserID, session.Fingerprint, session.ExpiresAt).
+		Columns("id", "user_id", "fingerprint", "expires_at", "family_id",
+			"created_at", "last_refreshed_at", "ip_address", "user_agent", "org_id", "client_id", "scope",
+			"auth_time", "amr").
+		Values(session.ID, session.UserID, session.Fingerprint, session.ExpiresAt, session.FamilyID,
+			session.CreatedAt, session.LastRefreshedAt, session.IPAddress, session.UserAgent, session.OrgID,
+			session.ClientID, session.Scope, session.AuthTime, session.AMR).
 		ToSql()
 
 	if buildSqlErr != nil {
@@ -126,6 +200,47 @@ func (r *AuthRepository) GetSessionByUUID(ctx context.Context, sessionUUID uuid.
 	return session, nil
 }
 
+func (r *AuthRepository) GetActiveSessionsByUserID(ctx context.Context, userID int64) ([]entity.Session, error) {
+	getSessionsSQL, args, buildSqlErr := r.psql.Select("*").
+		From(sessionTable).
+		Where(sq.Eq{"user_id": userID, "rotated_at": nil}).
+		Where(sq.Gt{"expires_at": time.Now()}).
+		OrderBy("created_at DESC").
+		ToSql()
+
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var sessions []entity.Session
+	if getSessionsErr := r.db.SelectContext(ctx, &sessions, getSessionsSQL, args...); getSessionsErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getSessionsErr)
+	}
+
+	return sessions, nil
+}
+
+func (r *AuthRepository) HasActiveSession(ctx context.Context, familyID uuid.UUID) (bool, error) {
+	activeSessionSQL, args, buildSqlErr := r.psql.Select("1").
+		From(sessionTable).
+		Where(sq.Eq{"family_id": familyID, "rotated_at": nil}).
+		Where(sq.Gt{"expires_at": time.Now()}).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+
+	if buildSqlErr != nil {
+		return false, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var exists bool
+	if activeSessionErr := r.db.GetContext(ctx, &exists, activeSessionSQL, args...); activeSessionErr != nil {
+		return false, fmt.Errorf("error during sql executing: %w", activeSessionErr)
+	}
+
+	return exists, nil
+}
+
 func (r *AuthRepository) DeleteSessionByUUID(ctx context.Context, sessionUUID uuid.UUID) error {
 	deleteSessionSQL, args, buildSqlErr := r.psql.Delete(sessionTable).
 		Where(sq.Eq{"id": sessionUUID}).
@@ -143,6 +258,109 @@ func (r *AuthRepository) DeleteSessionByUUID(ctx context.Context, sessionUUID uu
 	return nil
 }
 
+func (r *AuthRepository) MarkSessionRotated(ctx context.Context, sessionUUID uuid.UUID, rotatedAt time.Time) error {
+	markSessionSQL, args, buildSqlErr := r.psql.Update(sessionTable).
+		Set("rotated_at", rotatedAt).
+		Where(sq.Eq{"id": sessionUUID, "rotated_at": nil}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, markSessionErr := r.db.ExecContext(ctx, markSessionSQL, args...)
+	if markSessionErr != nil {
+		return fmt.Errorf("error during sql execution: %w", markSessionErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrSessionRotated
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteSessionsByFamily(ctx context.Context, familyID uuid.UUID) error {
+	deleteSessionsSQL, args, buildSqlErr := r.psql.Delete(sessionTable).
+		Where(sq.Eq{"family_id": familyID}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteSessionsErr := r.db.ExecContext(ctx, deleteSessionsSQL, args...)
+	if deleteSessionsErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteSessionsErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteUserSessionsByFamily(ctx context.Context, userID int64, familyID uuid.UUID) error {
+	deleteSessionsSQL, args, buildSqlErr := r.psql.Delete(sessionTable).
+		Where(sq.Eq{"user_id": userID, "family_id": familyID}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, deleteSessionsErr := r.db.ExecContext(ctx, deleteSessionsSQL, args...)
+	if deleteSessionsErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteSessionsErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrEntityNotFound
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteSessionsByUserID(ctx context.Context, userID int64) error {
+	deleteSessionsSQL, args, buildSqlErr := r.psql.Delete(sessionTable).
+		Where(sq.Eq{"user_id": userID}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteSessionsErr := r.db.ExecContext(ctx, deleteSessionsSQL, args...)
+	if deleteSessionsErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteSessionsErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteSessionsByUserIDExceptFamily(ctx context.Context, userID int64, familyID uuid.UUID) error {
+	deleteSessionsSQL, args, buildSqlErr := r.psql.Delete(sessionTable).
+		Where(sq.Eq{"user_id": userID}).
+		Where(sq.NotEq{"family_id": familyID}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteSessionsErr := r.db.ExecContext(ctx, deleteSessionsSQL, args...)
+	if deleteSessionsErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteSessionsErr)
+	}
+
+	return nil
+}
+
 func (r *AuthRepository) DeleteExpiredSessions(ctx context.Context, expiresAfter time.Time) error {
 	deleteSessionsSQL, args, buildSqlErr := r.psql.Delete(sessionTable).
 		Where(sq.Lt{"expires_at": expiresAfter}).
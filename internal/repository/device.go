@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"time"
+)
+
+const deviceAuthorizationTable = "device_authorization"
+
+func (r *AuthRepository) CreateDeviceAuthorization(ctx context.Context, authorization entity.DeviceAuthorization) error {
+	createAuthorizationSQL, args, buildSqlErr := r.psql.Insert(deviceAuthorizationTable).
+		Columns("device_code_hash", "user_code", "client_id", "scope", "status", "poll_interval", "expires_at").
+		Values(authorization.DeviceCodeHash, authorization.UserCode, authorization.ClientID, authorization.Scope,
+			authorization.Status, authorization.PollInterval, authorization.ExpiresAt).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, createAuthorizationErr := r.db.ExecContext(ctx, createAuthorizationSQL, args...); createAuthorizationErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createAuthorizationErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetDeviceAuthorization(ctx context.Context,
+	deviceCodeHash string) (entity.DeviceAuthorization, error) {
+	getAuthorizationSQL, args, buildSqlErr := r.psql.Select("*").
+		From(deviceAuthorizationTable).
+		Where(sq.Eq{"device_code_hash": deviceCodeHash}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var authorization entity.DeviceAuthorization
+	getAuthorizationErr := r.db.GetContext(ctx, &authorization, getAuthorizationSQL, args...)
+	if errors.Is(getAuthorizationErr, sql.ErrNoRows) {
+		return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+	}
+	if getAuthorizationErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("error during sql executing: %w", getAuthorizationErr)
+	}
+
+	return authorization, nil
+}
+
+// GetPendingDeviceAuthorization finds unresolved unexpired request by user code.
+func (r *AuthRepository) GetPendingDeviceAuthorization(ctx context.Context, userCode string,
+	now time.Time) (entity.DeviceAuthorization, error) {
+	getAuthorizationSQL, args, buildSqlErr := r.psql.Select("*").
+		From(deviceAuthorizationTable).
+		Where(sq.Eq{"user_code": userCode, "status": entity.DevicePending}).
+		Where(sq.Gt{"expires_at": now}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var authorization entity.DeviceAuthorization
+	getAuthorizationErr := r.db.GetContext(ctx, &authorization, getAuthorizationSQL, args...)
+	if errors.Is(getAuthorizationErr, sql.ErrNoRows) {
+		return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+	}
+	if getAuthorizationErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("error during sql executing: %w", getAuthorizationErr)
+	}
+
+	return authorization, nil
+}
+
+// ResolveDeviceAuthorization records user's decision on pending unexpired request and returns it.
+func (r *AuthRepository) ResolveDeviceAuthorization(ctx context.Context, userCode string,
+	resolution entity.DeviceAuthorization, now time.Time) (entity.DeviceAuthorization, error) {
+	resolveSQL, args, buildSqlErr := r.psql.Update(deviceAuthorizationTable).
+		Set("status", resolution.Status).
+		Set("user_id", resolution.UserID).
+		Set("auth_time", resolution.AuthTime).
+		Set("amr", resolution.AMR).
+		Where(sq.Eq{"user_code": userCode, "status": entity.DevicePending}).
+		Where(sq.Gt{"expires_at": now}).
+		Suffix("RETURNING *").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var authorization entity.DeviceAuthorization
+	resolveErr := r.db.GetContext(ctx, &authorization, resolveSQL, args...)
+	if errors.Is(resolveErr, sql.ErrNoRows) {
+		return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+	}
+	if resolveErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("error during sql execution: %w", resolveErr)
+	}
+
+	return authorization, nil
+}
+
+func (r *AuthRepository) RecordDevicePoll(ctx context.Context, deviceCodeHash string, polledAt time.Time,
+	pollInterval int) error {
+	recordPollSQL, args, buildSqlErr := r.psql.Update(deviceAuthorizationTable).
+		Set("last_polled_at", polledAt).
+		Set("poll_interval", pollInterval).
+		Where(sq.Eq{"device_code_hash": deviceCodeHash}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, recordPollErr := r.db.ExecContext(ctx, recordPollSQL, args...); recordPollErr != nil {
+		return fmt.Errorf("error during sql execution: %w", recordPollErr)
+	}
+
+	return nil
+}
+
+// ConsumeDeviceAuthorization deletes resolved request and returns it, so tokens are issued only once.
+func (r *AuthRepository) ConsumeDeviceAuthorization(ctx context.Context,
+	deviceCodeHash string) (entity.DeviceAuthorization, error) {
+	consumeSQL, args, buildSqlErr := r.psql.Delete(deviceAuthorizationTable).
+		Where(sq.Eq{"device_code_hash": deviceCodeHash}).
+		Where(sq.NotEq{"status": entity.DevicePending}).
+		Suffix("RETURNING *").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var authorization entity.DeviceAuthorization
+	consumeErr := r.db.GetContext(ctx, &authorization, consumeSQL, args...)
+	if errors.Is(consumeErr, sql.ErrNoRows) {
+		return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+	}
+	if consumeErr != nil {
+		return entity.DeviceAuthorization{}, fmt.Errorf("error during sql execution: %w", consumeErr)
+	}
+
+	return authorization, nil
+}
+
+func (r *AuthRepository) DeleteExpiredDeviceAuthorizations(ctx context.Context, expiredAt time.Time) error {
+	deleteAuthorizationsSQL, args, buildSqlErr := r.psql.Delete(deviceAuthorizationTable).
+		Where(sq.Lt{"expires_at": expiredAt}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, deleteAuthorizationsErr := r.db.ExecContext(ctx, deleteAuthorizationsSQL, args...); deleteAuthorizationsErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteAuthorizationsErr)
+	}
+
+	return nil
+}
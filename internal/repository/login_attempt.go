@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"time"
+)
+
+const loginAttemptTable = "login_attempt"
+
+func (r *AuthRepository) GetLoginAttempts(ctx context.Context, keys []string) ([]entity.LoginAttempt, error) {
+	getAttemptsSQL, args, buildSqlErr := r.psql.Select("*").
+		From(loginAttemptTable).
+		Where(sq.Eq{"key": keys}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var attempts []entity.LoginAttempt
+	if getAttemptsErr := r.db.SelectContext(ctx, &attempts, getAttemptsSQL, args...); getAttemptsErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getAttemptsErr)
+	}
+
+	return attempts, nil
+}
+
+// ReserveLoginAttempt counts attempt for key as failure before it is made and returns failures
+// within the window. Counter starts over when previous failure happened before windowStart.
+// Key is blocked for blockDelay of returned count in the same transaction, so concurrent
+// attempts wait for the row and see the block. Blocked key returns ErrEntityNotFound.
+func (r *AuthRepository) ReserveLoginAttempt(ctx context.Context, key string, attemptAt time.Time,
+	windowStart time.Time, blockDelay func(failures int) time.Duration) (int, error) {
+	reserveSQL, reserveArgs, buildSqlErr := r.psql.Insert(loginAttemptTable).
+		Columns("key", "failures", "last_failure_at").
+		Values(key, 1, attemptAt).
+		Suffix(`ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_attempt.last_failure_at < ? THEN 1 ELSE login_attempt.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+			WHERE login_attempt.blocked_until IS NULL OR login_attempt.blocked_until <= ?
+			RETURNING failures`, windowStart, attemptAt).
+		ToSql()
+	if buildSqlErr != nil {
+		return 0, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return 0, fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	var failures int
+	reserveErr := tx.GetContext(ctx, &failures, reserveSQL, reserveArgs...)
+	if errors.Is(reserveErr, sql.ErrNoRows) {
+		return 0, service.ErrEntityNotFound
+	}
+	if reserveErr != nil {
+		return 0, fmt.Errorf("error during sql execution: %w", reserveErr)
+	}
+
+	if delay := blockDelay(failures); delay > 0 {
+		blockSQL, blockArgs, buildSqlErr := r.psql.Update(loginAttemptTable).
+			Set("blocked_until", attemptAt.Add(delay)).
+			Where(sq.Eq{"key": key}).
+			ToSql()
+		if buildSqlErr != nil {
+			return 0, fmt.Errorf("can't build sql: %w", buildSqlErr)
+		}
+
+		if _, blockErr := tx.ExecContext(ctx, blockSQL, blockArgs...); blockErr != nil {
+			return 0, fmt.Errorf("error during sql execution: %w", blockErr)
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return 0, fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return failures, nil
+}
+
+// ReleaseLoginAttempt takes back attempt that succeeded. Block it has set is lifted too:
+// while it was in place no other attempt could pass and set its own.
+func (r *AuthRepository) ReleaseLoginAttempt(ctx context.Context, key string, liftBlock bool) error {
+	release := r.psql.Update(loginAttemptTable).
+		Set("failures", sq.Expr("GREATEST(failures - 1, 0)")).
+		Where(sq.Eq{"key": key})
+	if liftBlock {
+		release = release.Set("blocked_until", nil)
+	}
+
+	releaseSQL, args, buildSqlErr := release.ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, releaseErr := r.db.ExecContext(ctx, releaseSQL, args...); releaseErr != nil {
+		return fmt.Errorf("error during sql execution: %w", releaseErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteLoginAttempts(ctx context.Context, key string) error {
+	deleteSQL, args, buildSqlErr := r.psql.Delete(loginAttemptTable).
+		Where(sq.Eq{"key": key}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteErr := r.db.ExecContext(ctx, deleteSQL, args...)
+	if deleteErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteStaleLoginAttempts(ctx context.Context, lastFailureBefore time.Time) error {
+	deleteSQL, args, buildSqlErr := r.psql.Delete(loginAttemptTable).
+		Where(sq.Lt{"last_failure_at": lastFailureBefore}).
+		Where(sq.Or{sq.Eq{"blocked_until": nil}, sq.Lt{"blocked_until": time.Now()}}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteErr := r.db.ExecContext(ctx, deleteSQL, args...)
+	if deleteErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteErr)
+	}
+
+	return nil
+}
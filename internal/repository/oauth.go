@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"time"
+)
+
+const (
+	oauthClientTable            = "oauth_client"
+	oauthClientRedirectURITable = "oauth_client_redirect_uri"
+	oauthConsentTable           = "oauth_consent"
+	oauthAuthorizationCodeTable = "oauth_authorization_code"
+)
+
+func (r *AuthRepository) CreateOAuthClient(ctx context.Context, client entity.OAuthClient) error {
+	createClientSQL, createClientArgs, buildSqlErr := r.psql.Insert(oauthClientTable).
+		Columns("id", "name", "secret_hash", "created_at", "scope").
+		Values(client.ID, client.Name, client.SecretHash, client.CreatedAt, client.Scope).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	if _, createClientErr := tx.ExecContext(ctx, createClientSQL, createClientArgs...); createClientErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createClientErr)
+	}
+
+	if len(client.RedirectURIs) > 0 {
+		insertURIs := r.psql.Insert(oauthClientRedirectURITable).Columns("client_id", "redirect_uri")
+		for _, uri := range client.RedirectURIs {
+			insertURIs = insertURIs.Values(client.ID, uri)
+		}
+		insertURIsSQL, insertURIsArgs, buildSqlErr := insertURIs.Suffix("ON CONFLICT DO NOTHING").ToSql()
+		if buildSqlErr != nil {
+			return fmt.Errorf("can't build sql: %w", buildSqlErr)
+		}
+
+		if _, insertURIsErr := tx.ExecContext(ctx, insertURIsSQL, insertURIsArgs...); insertURIsErr != nil {
+			return fmt.Errorf("error during sql execution: %w", insertURIsErr)
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetOAuthClient(ctx context.Context, clientID string) (entity.OAuthClient, error) {
+	getClientSQL, args, buildSqlErr := r.psql.Select("*").
+		From(oauthClientTable).
+		Where(sq.Eq{"id": clientID}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.OAuthClient{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var client entity.OAuthClient
+	getClientErr := r.db.GetContext(ctx, &client, getClientSQL, args...)
+	if errors.Is(getClientErr, sql.ErrNoRows) {
+		return entity.OAuthClient{}, service.ErrEntityNotFound
+	}
+	if getClientErr != nil {
+		return entity.OAuthClient{}, fmt.Errorf("error during sql executing: %w", getClientErr)
+	}
+
+	getURIsSQL, args, buildSqlErr := r.psql.Select("redirect_uri").
+		From(oauthClientRedirectURITable).
+		Where(sq.Eq{"client_id": clientID}).
+		OrderBy("redirect_uri").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.OAuthClient{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if getURIsErr := r.db.SelectContext(ctx, &client.RedirectURIs, getURIsSQL, args...); getURIsErr != nil {
+		return entity.OAuthClient{}, fmt.Errorf("error during sql executing: %w", getURIsErr)
+	}
+
+	return client, nil
+}
+
+func (r *AuthRepository) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	deleteClientSQL, args, buildSqlErr := r.psql.Delete(oauthClientTable).
+		Where(sq.Eq{"id": clientID}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, deleteClientErr := r.db.ExecContext(ctx, deleteClientSQL, args...)
+	if deleteClientErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteClientErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrEntityNotFound
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetOAuthConsent(ctx context.Context, userID int64, clientID string) (entity.OAuthConsent, error) {
+	getConsentSQL, args, buildSqlErr := r.psql.Select("*").
+		From(oauthConsentTable).
+		Where(sq.Eq{"user_id": userID, "client_id": clientID}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.OAuthConsent{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var consent entity.OAuthConsent
+	getConsentErr := r.db.GetContext(ctx, &consent, getConsentSQL, args...)
+	if errors.Is(getConsentErr, sql.ErrNoRows) {
+		return entity.OAuthConsent{}, service.ErrEntityNotFound
+	}
+	if getConsentErr != nil {
+		return entity.OAuthConsent{}, fmt.Errorf("error during sql executing: %w", getConsentErr)
+	}
+
+	return consent, nil
+}
+
+func (r *AuthRepository) SaveOAuthConsent(ctx context.Context, consent entity.OAuthConsent) error {
+	saveConsentSQL, args, buildSqlErr := r.psql.Insert(oauthConsentTable).
+		Columns("user_id", "client_id", "scope", "granted_at").
+		Values(consent.UserID, consent.ClientID, consent.Scope, consent.GrantedAt).
+		Suffix("ON CONFLICT (user_id, client_id) DO UPDATE SET scope = EXCLUDED.scope, granted_at = EXCLUDED.granted_at").
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, saveConsentErr := r.db.ExecContext(ctx, saveConsentSQL, args...); saveConsentErr != nil {
+		return fmt.Errorf("error during sql execution: %w", saveConsentErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) CreateAuthorizationCode(ctx context.Context, code entity.AuthorizationCode) error {
+	createCodeSQL, args, buildSqlErr := r.psql.Insert(oauthAuthorizationCodeTable).
+		Columns("code_hash", "client_id", "user_id", "redirect_uri", "scope", "code_challenge", "expires_at",
+			"nonce", "auth_time", "amr").
+		Values(code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope, code.CodeChallenge, code.ExpiresAt,
+			code.Nonce, code.AuthTime, code.AMR).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, createCodeErr := r.db.ExecContext(ctx, createCodeSQL, args...); createCodeErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createCodeErr)
+	}
+
+	return nil
+}
+
+// ConsumeAuthorizationCode deletes unexpired code and returns it, so code can be exchanged only once.
+func (r *AuthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string,
+	now time.Time) (entity.AuthorizationCode, error) {
+	consumeCodeSQL, args, buildSqlErr := r.psql.Delete(oauthAuthorizationCodeTable).
+		Where(sq.Eq{"code_hash": codeHash}).
+		Where(sq.Gt{"expires_at": now}).
+		Suffix("RETURNING *").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.AuthorizationCode{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var code entity.AuthorizationCode
+	consumeCodeErr := r.db.GetContext(ctx, &code, consumeCodeSQL, args...)
+	if errors.Is(consumeCodeErr, sql.ErrNoRows) {
+		return entity.AuthorizationCode{}, service.ErrEntityNotFound
+	}
+	if consumeCodeErr != nil {
+		return entity.AuthorizationCode{}, fmt.Errorf("error during sql execution: %w", consumeCodeErr)
+	}
+
+	return code, nil
+}
+
+func (r *AuthRepository) DeleteExpiredAuthorizationCodes(ctx context.Context, expiredAt time.Time) error {
+	deleteCodesSQL, args, buildSqlErr := r.psql.Delete(oauthAuthorizationCodeTable).
+		Where(sq.Lt{"expires_at": expiredAt}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, deleteCodesErr := r.db.ExecContext(ctx, deleteCodesSQL, args...); deleteCodesErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteCodesErr)
+	}
+
+	return nil
+}
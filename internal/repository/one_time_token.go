@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"time"
+)
+
+const oneTimeTokenTable = "one_time_token"
+
+func (r *AuthRepository) CreateOneTimeToken(ctx context.Context, token entity.OneTimeToken) error {
+	createTokenSQL, args, buildSqlErr := r.psql.Insert(oneTimeTokenTable).
+		Columns("token_hash", "user_id", "purpose", "expires_at", "fingerprint").
+		Values(token.TokenHash, token.UserID, token.Purpose, token.ExpiresAt, token.Fingerprint).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, createTokenErr := r.db.ExecContext(ctx, createTokenSQL, args...)
+	if createTokenErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createTokenErr)
+	}
+
+	return nil
+}
+
+// GetOneTimeToken finds unused and unexpired token without using it.
+func (r *AuthRepository) GetOneTimeToken(ctx context.Context, tokenHash string,
+	purpose string, now time.Time) (entity.OneTimeToken, error) {
+	getTokenSQL, args, buildSqlErr := r.psql.Select("*").
+		From(oneTimeTokenTable).
+		Where(sq.Eq{"token_hash": tokenHash, "purpose": purpose, "used_at": nil}).
+		Where(sq.Gt{"expires_at": now}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return entity.OneTimeToken{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var token entity.OneTimeToken
+	getTokenErr := r.db.GetContext(ctx, &token, getTokenSQL, args...)
+	if errors.Is(getTokenErr, sql.ErrNoRows) {
+		return entity.OneTimeToken{}, service.ErrEntityNotFound
+	}
+	if getTokenErr != nil {
+		return entity.OneTimeToken{}, fmt.Errorf("error during sql execution: %w", getTokenErr)
+	}
+
+	return token, nil
+}
+
+// ConsumeOneTimeToken marks unused and unexpired token as used in a single statement,
+// so concurrent requests can't use the same token twice.
+func (r *AuthRepository) ConsumeOneTimeToken(ctx context.Context, tokenHash string,
+	purpose string, usedAt time.Time) (entity.OneTimeToken, error) {
+	consumeTokenSQL, args, buildSqlErr := r.psql.Update(oneTimeTokenTable).
+		Set("used_at", usedAt).
+		Where(sq.Eq{"token_hash": tokenHash, "purpose": purpose, "used_at": nil}).
+		Where(sq.Gt{"expires_at": usedAt}).
+		Suffix("RETURNING *").
+		ToSql()
+
+	if buildSqlErr != nil {
+		return entity.OneTimeToken{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var token entity.OneTimeToken
+	consumeTokenErr := r.db.GetContext(ctx, &token, consumeTokenSQL, args...)
+	if errors.Is(consumeTokenErr, sql.ErrNoRows) {
+		return entity.OneTimeToken{}, service.ErrEntityNotFound
+	}
+	if consumeTokenErr != nil {
+		return entity.OneTimeToken{}, fmt.Errorf("error during sql execution: %w", consumeTokenErr)
+	}
+
+	return token, nil
+}
+
+func (r *AuthRepository) DeleteUserOneTimeTokens(ctx context.Context, userID int64, purpose string) error {
+	deleteTokensSQL, args, buildSqlErr := r.psql.Delete(oneTimeTokenTable).
+		Where(sq.Eq{"user_id": userID, "purpose": purpose}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteTokensErr := r.db.ExecContext(ctx, deleteTokensSQL, args...)
+	if deleteTokensErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteTokensErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) DeleteExpiredOneTimeTokens(ctx context.Context, expiredAt time.Time) error {
+	deleteTokensSQL, args, buildSqlErr := r.psql.Delete(oneTimeTokenTable).
+		Where(sq.Lt{"expires_at": expiredAt}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteTokensErr := r.db.ExecContext(ctx, deleteTokensSQL, args...)
+	if deleteTokensErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteTokensErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"time"
+)
+
+const (
+	organizationTable           = "organization"
+	organizationMemberTable     = "organization_member"
+	organizationInvitationTable = "organization_invitation"
+)
+
+// CreateOrganization creates organization with owner as its first member.
+func (r *AuthRepository) CreateOrganization(ctx context.Context, name string,
+	owner entity.Membership) (entity.Organization, error) {
+	createOrgSQL, createOrgArgs, buildSqlErr := r.psql.Insert(organizationTable).
+		Columns("name").
+		Values(name).
+		Suffix("RETURNING *").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.Organization{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return entity.Organization{}, fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	var org entity.Organization
+	if createOrgErr := tx.GetContext(ctx, &org, createOrgSQL, createOrgArgs...); createOrgErr != nil {
+		return entity.Organization{}, fmt.Errorf("error during sql execution: %w", createOrgErr)
+	}
+
+	addOwnerSQL, addOwnerArgs, buildSqlErr := r.psql.Insert(organizationMemberTable).
+		Columns("org_id", "user_id", "role").
+		Values(org.ID, owner.UserID, owner.Role).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.Organization{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, addOwnerErr := tx.ExecContext(ctx, addOwnerSQL, addOwnerArgs...); addOwnerErr != nil {
+		return entity.Organization{}, fmt.Errorf("error during sql execution: %w", addOwnerErr)
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return entity.Organization{}, fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return org, nil
+}
+
+func (r *AuthRepository) GetUserMemberships(ctx context.Context, userID int64) ([]entity.Membership, error) {
+	getMembershipsSQL, args, buildSqlErr := r.selectMemberships().
+		Where(sq.Eq{"m.user_id": userID}).
+		OrderBy("o.name", "o.id").
+		ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var memberships []entity.Membership
+	if getMembershipsErr := r.db.SelectContext(ctx, &memberships, getMembershipsSQL, args...); getMembershipsErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getMembershipsErr)
+	}
+
+	return memberships, nil
+}
+
+func (r *AuthRepository) GetMembership(ctx context.Context, orgID int64, userID int64) (entity.Membership, error) {
+	getMembershipSQL, args, buildSqlErr := r.selectMemberships().
+		Where(sq.Eq{"m.org_id": orgID, "m.user_id": userID}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.Membership{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var membership entity.Membership
+	getMembershipErr := r.db.GetContext(ctx, &membership, getMembershipSQL, args...)
+
+	if errors.Is(getMembershipErr, sql.ErrNoRows) {
+		return entity.Membership{}, service.ErrEntityNotFound
+	}
+	if getMembershipErr != nil {
+		return entity.Membership{}, fmt.Errorf("error during sql executing: %w", getMembershipErr)
+	}
+
+	return membership, nil
+}
+
+// GetMemberAccess returns what user is allowed to do inside organization.
+func (r *AuthRepository) GetMemberAccess(ctx context.Context, orgID int64, userID int64) (entity.UserAccess, error) {
+	membership, getMembershipErr := r.GetMembership(ctx, orgID, userID)
+	if getMembershipErr != nil {
+		return entity.UserAccess{}, getMembershipErr
+	}
+
+	getPermissionsSQL, args, buildSqlErr := r.psql.Select("permission").
+		From(rolePermissionTable).
+		Where(sq.Eq{"role": membership.Role}).
+		OrderBy("permission").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.UserAccess{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	access := entity.UserAccess{Roles: []string{membership.Role}}
+	if getPermissionsErr := r.db.SelectContext(ctx, &access.Permissions, getPermissionsSQL, args...); getPermissionsErr != nil {
+		return entity.UserAccess{}, fmt.Errorf("error during sql executing: %w", getPermissionsErr)
+	}
+
+	return access, nil
+}
+
+// SaveMembership adds user to organization. Existing member keeps their role,
+// so accepted invitation can't demote or promote anyone, the owner included.
+func (r *AuthRepository) SaveMembership(ctx context.Context, membership entity.Membership) error {
+	saveMembershipSQL, args, buildSqlErr := r.psql.Insert(organizationMemberTable).
+		Columns("org_id", "user_id", "role").
+		Values(membership.OrgID, membership.UserID, membership.Role).
+		Suffix("ON CONFLICT (org_id, user_id) DO NOTHING").
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, saveMembershipErr := r.db.ExecContext(ctx, saveMembershipSQL, args...)
+	if saveMembershipErr != nil {
+		return fmt.Errorf("error during sql execution: %w", saveMembershipErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) CreateInvitation(ctx context.Context, invitation entity.OrganizationInvitation) error {
+	createInvitationSQL, args, buildSqlErr := r.psql.Insert(organizationInvitationTable).
+		Columns("token_hash", "org_id", "email", "role", "invited_by", "expires_at").
+		Values(invitation.TokenHash, invitation.OrgID, invitation.Email, invitation.Role,
+			invitation.InvitedBy, invitation.ExpiresAt).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, createInvitationErr := r.db.ExecContext(ctx, createInvitationSQL, args...)
+	if createInvitationErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createInvitationErr)
+	}
+
+	return nil
+}
+
+// AcceptInvitation marks invitation accepted, so it can't be used twice.
+func (r *AuthRepository) AcceptInvitation(ctx context.Context, tokenHash string,
+	email string, acceptedAt time.Time) (entity.OrganizationInvitation, error) {
+	acceptInvitationSQL, args, buildSqlErr := r.psql.Update(organizationInvitationTable).
+		Set("accepted_at", acceptedAt).
+		Where(sq.Eq{"token_hash": tokenHash, "email": email, "accepted_at": nil}).
+		Where(sq.Gt{"expires_at": acceptedAt}).
+		Suffix("RETURNING *").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.OrganizationInvitation{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var invitation entity.OrganizationInvitation
+	acceptInvitationErr := r.db.GetContext(ctx, &invitation, acceptInvitationSQL, args...)
+	if errors.Is(acceptInvitationErr, sql.ErrNoRows) {
+		return entity.OrganizationInvitation{}, service.ErrEntityNotFound
+	}
+	if acceptInvitationErr != nil {
+		return entity.OrganizationInvitation{}, fmt.Errorf("error during sql execution: %w", acceptInvitationErr)
+	}
+
+	return invitation, nil
+}
+
+func (r *AuthRepository) DeleteExpiredInvitations(ctx context.Context, expiredAt time.Time) error {
+	deleteInvitationsSQL, args, buildSqlErr := r.psql.Delete(organizationInvitationTable).
+		Where(sq.Lt{"expires_at": expiredAt}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteInvitationsErr := r.db.ExecContext(ctx, deleteInvitationsSQL, args...)
+	if deleteInvitationsErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteInvitationsErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) selectMemberships() sq.SelectBuilder {
+	return r.psql.Select("m.org_id", "o.name AS org_name", "m.user_id", "m.role").
+		From(organizationMemberTable + " m").
+		Join(organizationTable + " o ON o.id = m.org_id")
+}
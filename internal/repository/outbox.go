@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"time"
+)
+
+const (
+	outboxEventTable     = "outbox_event"
+	webhookDeliveryTable = "webhook_delivery"
+)
+
+// scheduleDeliveriesSQL marks batch of new events scheduled and creates their deliveries
+// in one statement, so event can't be scheduled without deliveries.
+const scheduleDeliveriesSQL = `
+WITH scheduled AS (
+    UPDATE outbox_event SET scheduled_at = $1
+    WHERE id IN (SELECT id FROM outbox_event WHERE scheduled_at IS NULL ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED)
+    RETURNING id
+)
+INSERT INTO webhook_delivery (event_id, endpoint, next_attempt_at)
+SELECT scheduled.id, endpoint, $1 FROM scheduled CROSS JOIN unnest($3::VARCHAR[]) AS endpoint
+ON CONFLICT DO NOTHING`
+
+// claimDeliveriesSQL postpones due deliveries for lease period, so other dispatchers
+// don't pick them while they are being sent.
+const claimDeliveriesSQL = `
+UPDATE webhook_delivery d SET next_attempt_at = $1
+FROM outbox_event e
+WHERE e.id = d.event_id AND (d.event_id, d.endpoint) IN (
+    SELECT event_id, endpoint FROM webhook_delivery
+    WHERE status = 'pending' AND next_attempt_at <= $2
+    ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED)
+RETURNING d.*, e.event_type, e.payload, e.created_at AS event_created_at`
+
+type OutboxRepository struct {
+	db   *sqlx.DB
+	psql sq.StatementBuilderType
+}
+
+func (r *OutboxRepository) ScheduleWebhookDeliveries(ctx context.Context, endpoints []string,
+	limit int, scheduledAt time.Time) error {
+	_, scheduleErr := r.db.ExecContext(ctx, scheduleDeliveriesSQL, scheduledAt, limit, pq.Array(endpoints))
+	if scheduleErr != nil {
+		return fmt.Errorf("error during sql execution: %w", scheduleErr)
+	}
+
+	return nil
+}
+
+func (r *OutboxRepository) ClaimWebhookDeliveries(ctx context.Context, limit int, now time.Time,
+	leaseUntil time.Time) ([]entity.WebhookDelivery, error) {
+	var deliveries []entity.WebhookDelivery
+	claimErr := r.db.SelectContext(ctx, &deliveries, claimDeliveriesSQL, leaseUntil, now, limit)
+	if claimErr != nil {
+		return nil, fmt.Errorf("error during sql execution: %w", claimErr)
+	}
+
+	return deliveries, nil
+}
+
+func (r *OutboxRepository) UpdateWebhookDelivery(ctx context.Context, delivery entity.WebhookDelivery) error {
+	updateDeliverySQL, args, buildSqlErr := r.psql.Update(webhookDeliveryTable).
+		Set("status", delivery.Status).
+		Set("attempts", delivery.Attempts).
+		Set("next_attempt_at", delivery.NextAttemptAt).
+		Set("last_error", delivery.LastError).
+		Set("delivered_at", delivery.DeliveredAt).
+		Where(sq.Eq{"event_id": delivery.EventID, "endpoint": delivery.Endpoint}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, updateDeliveryErr := r.db.ExecContext(ctx, updateDeliverySQL, args...); updateDeliveryErr != nil {
+		return fmt.Errorf("error during sql execution: %w", updateDeliveryErr)
+	}
+
+	return nil
+}
+
+func NewOutboxRepository(db *sqlx.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db:   db,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar)}
+}
+
+// insertOutboxEvent saves event in transaction of the change it describes.
+func (r *AuthRepository) insertOutboxEvent(ctx context.Context, tx *sqlx.Tx, eventType string,
+	payload entity.UserEventPayload, createdAt time.Time) error {
+	payloadJSON, marshalErr := json.Marshal(payload)
+	if marshalErr != nil {
+		return fmt.Errorf("can't marshal event payload: %w", marshalErr)
+	}
+
+	insertEventSQL, args, buildSqlErr := r.psql.Insert(outboxEventTable).
+		Columns("event_type", "payload", "created_at").
+		Values(eventType, payloadJSON, createdAt).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, insertEventErr := tx.ExecContext(ctx, insertEventSQL, args...); insertEventErr != nil {
+		return fmt.Errorf("can't save outbox event: %w", insertEventErr)
+	}
+
+	return nil
+}
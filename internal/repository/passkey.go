@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	passkeyCredentialTable = "passkey_credential"
+	passkeyCeremonyTable   = "passkey_ceremony"
+)
+
+func (r *AuthRepository) GetUserPasskeys(ctx context.Context, userID int64) ([]entity.PasskeyCredential, error) {
+	getPasskeysSQL, args, buildSqlErr := r.psql.Select("*").
+		From(passkeyCredentialTable).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("created_at").
+		ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var passkeys []entity.PasskeyCredential
+	if getPasskeysErr := r.db.SelectContext(ctx, &passkeys, getPasskeysSQL, args...); getPasskeysErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getPasskeysErr)
+	}
+
+	return passkeys, nil
+}
+
+func (r *AuthRepository) CreatePasskey(ctx context.Context, passkey entity.PasskeyCredential) error {
+	createPasskeySQL, args, buildSqlErr := r.psql.Insert(passkeyCredentialTable).
+		Columns("id", "user_id", "public_key", "attestation_type", "aaguid", "sign_count",
+			"transports", "backup_eligible", "backup_state").
+		Values(passkey.ID, passkey.UserID, passkey.PublicKey, passkey.AttestationType, passkey.AAGUID, passkey.SignCount,
+			passkey.Transports, passkey.BackupEligible, passkey.BackupState).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, createPasskeyErr := r.db.ExecContext(ctx, createPasskeySQL, args...)
+	if createPasskeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createPasskeyErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) UpdatePasskeyUsage(ctx context.Context, passkey entity.PasskeyCredential, usedAt time.Time) error {
+	updatePasskeySQL, args, buildSqlErr := r.psql.Update(passkeyCredentialTable).
+		Set("sign_count", passkey.SignCount).
+		Set("backup_state", passkey.BackupState).
+		Set("last_used_at", usedAt).
+		Where(sq.Eq{"id": passkey.ID}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, updatePasskeyErr := r.db.ExecContext(ctx, updatePasskeySQL, args...)
+	if updatePasskeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", updatePasskeyErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) CreatePasskeyCeremony(ctx context.Context, ceremony entity.PasskeyCeremony) error {
+	createCeremonySQL, args, buildSqlErr := r.psql.Insert(passkeyCeremonyTable).
+		Columns("id", "user_id", "purpose", "session_data", "expires_at").
+		Values(ceremony.ID, ceremony.UserID, ceremony.Purpose, ceremony.SessionData, ceremony.ExpiresAt).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, createCeremonyErr := r.db.ExecContext(ctx, createCeremonySQL, args...)
+	if createCeremonyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createCeremonyErr)
+	}
+
+	return nil
+}
+
+// ConsumePasskeyCeremony deletes unexpired ceremony and returns it, so its challenge can be answered only once.
+func (r *AuthRepository) ConsumePasskeyCeremony(ctx context.Context, ceremonyID uuid.UUID,
+	purpose string, now time.Time) (entity.PasskeyCeremony, error) {
+	consumeCeremonySQL, args, buildSqlErr := r.psql.Delete(passkeyCeremonyTable).
+		Where(sq.Eq{"id": ceremonyID, "purpose": purpose}).
+		Where(sq.Gt{"expires_at": now}).
+		Suffix("RETURNING *").
+		ToSql()
+
+	if buildSqlErr != nil {
+		return entity.PasskeyCeremony{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var ceremony entity.PasskeyCeremony
+	consumeCeremonyErr := r.db.GetContext(ctx, &ceremony, consumeCeremonySQL, args...)
+	if errors.Is(consumeCeremonyErr, sql.ErrNoRows) {
+		return entity.PasskeyCeremony{}, service.ErrEntityNotFound
+	}
+	if consumeCeremonyErr != nil {
+		return entity.PasskeyCeremony{}, fmt.Errorf("error during sql execution: %w", consumeCeremonyErr)
+	}
+
+	return ceremony, nil
+}
+
+func (r *AuthRepository) DeleteExpiredPasskeyCeremonies(ctx context.Context, expiredAt time.Time) error {
+	deleteCeremoniesSQL, args, buildSqlErr := r.psql.Delete(passkeyCeremonyTable).
+		Where(sq.Lt{"expires_at": expiredAt}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteCeremoniesErr := r.db.ExecContext(ctx, deleteCeremoniesSQL, args...)
+	if deleteCeremoniesErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteCeremoniesErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+)
+
+const (
+	roleTable           = "role"
+	permissionTable     = "permission"
+	rolePermissionTable = "role_permission"
+	userRoleTable       = "user_role"
+)
+
+// roleHolderLockID is key of advisory lock that serializes revocations which must keep last holder,
+// so two of them can't each see the other holder and remove both.
+const roleHolderLockID = 7_100_192
+
+func (r *AuthRepository) GetRole(ctx context.Context, name string) (entity.Role, error) {
+	getRoleSQL, args, buildSqlErr := r.psql.Select("*").
+		From(roleTable).
+		Where(sq.Eq{"name": name}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.Role{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var role entity.Role
+	getRoleErr := r.db.GetContext(ctx, &role, getRoleSQL, args...)
+
+	if errors.Is(getRoleErr, sql.ErrNoRows) {
+		return entity.Role{}, service.ErrEntityNotFound
+	}
+	if getRoleErr != nil {
+		return entity.Role{}, fmt.Errorf("error during sql executing: %w", getRoleErr)
+	}
+
+	return role, nil
+}
+
+func (r *AuthRepository) GetRoles(ctx context.Context) ([]entity.Role, error) {
+	getRolesSQL, args, buildSqlErr := r.psql.Select("*").
+		From(roleTable).
+		OrderBy("name").
+		ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var roles []entity.Role
+	if getRolesErr := r.db.SelectContext(ctx, &roles, getRolesSQL, args...); getRolesErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getRolesErr)
+	}
+
+	getPermissionsSQL, args, buildSqlErr := r.psql.Select("role", "permission").
+		From(rolePermissionTable).
+		OrderBy("permission").
+		ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var rolePermissions []struct {
+		Role       string
+		Permission string
+	}
+	if getPermissionsErr := r.db.SelectContext(ctx, &rolePermissions, getPermissionsSQL, args...); getPermissionsErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getPermissionsErr)
+	}
+
+	permissions := make(map[string][]string, len(roles))
+	for _, rp := range rolePermissions {
+		permissions[rp.Role] = append(permissions[rp.Role], rp.Permission)
+	}
+	for i := range roles {
+		roles[i].Permissions = permissions[roles[i].Name]
+	}
+
+	return roles, nil
+}
+
+// SaveRole creates role or updates existing one, replacing its permissions.
+func (r *AuthRepository) SaveRole(ctx context.Context, role entity.Role) error {
+	saveRoleSQL, saveRoleArgs, buildSqlErr := r.psql.Insert(roleTable).
+		Columns("name", "description").
+		Values(role.Name, role.Description).
+		Suffix("ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description").
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	deletePermissionsSQL, deletePermissionsArgs, buildSqlErr := r.psql.Delete(rolePermissionTable).
+		Where(sq.Eq{"role": role.Name}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	if _, saveRoleErr := tx.ExecContext(ctx, saveRoleSQL, saveRoleArgs...); saveRoleErr != nil {
+		return fmt.Errorf("error during sql execution: %w", saveRoleErr)
+	}
+	if _, deletePermissionsErr := tx.ExecContext(ctx, deletePermissionsSQL, deletePermissionsArgs...); deletePermissionsErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deletePermissionsErr)
+	}
+
+	if len(role.Permissions) > 0 {
+		insertPermissions := r.psql.Insert(permissionTable).Columns("name")
+		insertRolePermissions := r.psql.Insert(rolePermissionTable).Columns("role", "permission")
+		for _, permission := range role.Permissions {
+			insertPermissions = insertPermissions.Values(permission)
+			insertRolePermissions = insertRolePermissions.Values(role.Name, permission)
+		}
+
+		insertPermissionsSQL, insertPermissionsArgs, buildSqlErr := insertPermissions.
+			Suffix("ON CONFLICT (name) DO NOTHING").
+			ToSql()
+		if buildSqlErr != nil {
+			return fmt.Errorf("can't build sql: %w", buildSqlErr)
+		}
+		insertRolePermissionsSQL, insertRolePermissionsArgs, buildSqlErr := insertRolePermissions.ToSql()
+		if buildSqlErr != nil {
+			return fmt.Errorf("can't build sql: %w", buildSqlErr)
+		}
+
+		if _, insertErr := tx.ExecContext(ctx, insertPermissionsSQL, insertPermissionsArgs...); insertErr != nil {
+			return fmt.Errorf("error during sql execution: %w", insertErr)
+		}
+		if _, insertErr := tx.ExecContext(ctx, insertRolePermissionsSQL, insertRolePermissionsArgs...); insertErr != nil {
+			return fmt.Errorf("error during sql execution: %w", insertErr)
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) GetUserAccess(ctx context.Context, userID int64) (entity.UserAccess, error) {
+	getRolesSQL, args, buildSqlErr := r.psql.Select("role").
+		From(userRoleTable).
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("role").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.UserAccess{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var access entity.UserAccess
+	if getRolesErr := r.db.SelectContext(ctx, &access.Roles, getRolesSQL, args...); getRolesErr != nil {
+		return entity.UserAccess{}, fmt.Errorf("error during sql executing: %w", getRolesErr)
+	}
+
+	getPermissionsSQL, args, buildSqlErr := r.psql.Select("DISTINCT rp.permission").
+		From(rolePermissionTable + " rp").
+		Join(userRoleTable + " ur ON ur.role = rp.role").
+		Where(sq.Eq{"ur.user_id": userID}).
+		OrderBy("rp.permission").
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.UserAccess{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if getPermissionsErr := r.db.SelectContext(ctx, &access.Permissions, getPermissionsSQL, args...); getPermissionsErr != nil {
+		return entity.UserAccess{}, fmt.Errorf("error during sql executing: %w", getPermissionsErr)
+	}
+
+	return access, nil
+}
+
+func (r *AuthRepository) AssignUserRole(ctx context.Context, userID int64, role string) error {
+	assignRoleSQL, args, buildSqlErr := r.psql.Insert(userRoleTable).
+		Columns("user_id", "role").
+		Values(userID, role).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, assignRoleErr := r.db.ExecContext(ctx, assignRoleSQL, args...)
+	if assignRoleErr != nil {
+		return fmt.Errorf("error during sql execution: %w", assignRoleErr)
+	}
+
+	return nil
+}
+
+// RevokeUserRole removes role from user. With keepLastHolder it refuses to leave role without holders.
+func (r *AuthRepository) RevokeUserRole(ctx context.Context, userID int64, role string, keepLastHolder bool) error {
+	revokeRoleSQL, revokeArgs, buildSqlErr := r.psql.Delete(userRoleTable).
+		Where(sq.Eq{"user_id": userID, "role": role}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	countHoldersSQL, countArgs, buildSqlErr := r.psql.Select("COUNT(*)").
+		From(userRoleTable).
+		Where(sq.Eq{"role": role}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	if keepLastHolder {
+		if _, lockErr := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", roleHolderLockID); lockErr != nil {
+			return fmt.Errorf("can't lock role holders: %w", lockErr)
+		}
+	}
+
+	res, revokeRoleErr := tx.ExecContext(ctx, revokeRoleSQL, revokeArgs...)
+	if revokeRoleErr != nil {
+		return fmt.Errorf("error during sql execution: %w", revokeRoleErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrEntityNotFound
+	}
+
+	if keepLastHolder {
+		var holders int
+		if countErr := tx.GetContext(ctx, &holders, countHoldersSQL, countArgs...); countErr != nil {
+			return fmt.Errorf("error during sql executing: %w", countErr)
+		}
+		if holders == 0 {
+			return service.ErrLastRoleHolder
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
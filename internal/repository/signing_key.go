@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+const signingKeyTable = "signing_key"
+
+// signingKeyLockID is key of advisory lock that serializes creation of signing keys.
+const signingKeyLockID = 7_100_191
+
+type SigningKeyRepository struct {
+	db   *sqlx.DB
+	psql sq.StatementBuilderType
+}
+
+func (r *SigningKeyRepository) GetSigningKeys(ctx context.Context, tokenType string) ([]entity.SigningKey, error) {
+	getKeysSQL, args, buildSqlErr := r.psql.Select("*").
+		From(signingKeyTable).
+		Where(sq.Eq{"token_type": tokenType}).
+		OrderBy("activates_at").
+		ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var keys []entity.SigningKey
+	if getKeysErr := r.db.SelectContext(ctx, &keys, getKeysSQL, args...); getKeysErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", getKeysErr)
+	}
+
+	return keys, nil
+}
+
+// CreateSigningKey saves key unless another replica already created a key activating after
+// latestActivatesAt, the newest activation the caller has seen.
+func (r *SigningKeyRepository) CreateSigningKey(ctx context.Context, key entity.SigningKey,
+	latestActivatesAt time.Time) error {
+	newerKeySQL, newerKeyArgs, buildSqlErr := r.psql.Select("1").
+		Prefix("SELECT EXISTS (").
+		From(signingKeyTable).
+		Where(sq.Eq{"token_type": key.TokenType}).
+		Where(sq.Gt{"activates_at": latestActivatesAt}).
+		Suffix(")").
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	createKeySQL, createKeyArgs, buildSqlErr := r.psql.Insert(signingKeyTable).
+		Columns("id", "token_type", "algorithm", "material", "encrypted", "activates_at", "retires_at", "expires_at").
+		Values(key.ID, key.TokenType, key.Algorithm, key.Material, key.Encrypted,
+			key.ActivatesAt, key.RetiresAt, key.ExpiresAt).
+		Suffix("ON CONFLICT (token_type, activates_at) DO NOTHING").
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	if _, lockErr := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", signingKeyLockID); lockErr != nil {
+		return fmt.Errorf("can't lock signing keys: %w", lockErr)
+	}
+
+	var newerKeyExists bool
+	if getNewerKeyErr := tx.GetContext(ctx, &newerKeyExists, newerKeySQL, newerKeyArgs...); getNewerKeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", getNewerKeyErr)
+	}
+	if newerKeyExists {
+		return nil
+	}
+
+	if _, createKeyErr := tx.ExecContext(ctx, createKeySQL, createKeyArgs...); createKeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", createKeyErr)
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+// EncryptSigningKey replaces plaintext material of key stored before encryption was introduced.
+func (r *SigningKeyRepository) EncryptSigningKey(ctx context.Context, id string, material string) error {
+	encryptKeySQL, args, buildSqlErr := r.psql.Update(signingKeyTable).
+		Set("material", material).
+		Set("encrypted", true).
+		Where(sq.Eq{"id": id, "encrypted": false}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, encryptKeyErr := r.db.ExecContext(ctx, encryptKeySQL, args...); encryptKeyErr != nil {
+		return fmt.Errorf("error during sql execution: %w", encryptKeyErr)
+	}
+
+	return nil
+}
+
+func (r *SigningKeyRepository) DeleteExpiredSigningKeys(ctx context.Context, tokenType string, expiredAt time.Time) error {
+	deleteKeysSQL, args, buildSqlErr := r.psql.Delete(signingKeyTable).
+		Where(sq.Eq{"token_type": tokenType}).
+		Where(sq.Lt{"expires_at": expiredAt}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	_, deleteKeysErr := r.db.ExecContext(ctx, deleteKeysSQL, args...)
+	if deleteKeysErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteKeysErr)
+	}
+
+	return nil
+}
+
+func NewSigningKeyRepository(db *sqlx.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{
+		db:   db,
+		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar)}
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"time"
+)
+
+const (
+	userTOTPTable     = "user_totp"
+	recoveryCodeTable = "recovery_code"
+)
+
+func (r *AuthRepository) GetUserTOTP(ctx context.Context, userID int64) (entity.UserTOTP, error) {
+	getTOTPSQL, args, buildSqlErr := r.psql.Select("*").
+		From(userTOTPTable).
+		Where(sq.Eq{"user_id": userID}).
+		ToSql()
+	if buildSqlErr != nil {
+		return entity.UserTOTP{}, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var totp entity.UserTOTP
+	getTOTPErr := r.db.GetContext(ctx, &totp, getTOTPSQL, args...)
+
+	if errors.Is(getTOTPErr, sql.ErrNoRows) {
+		return entity.UserTOTP{}, service.ErrEntityNotFound
+	}
+	if getTOTPErr != nil {
+		return entity.UserTOTP{}, fmt.Errorf("error during sql executing: %w", getTOTPErr)
+	}
+
+	return totp, nil
+}
+
+// SaveUnconfirmedTOTP stores new encrypted secret unless user already has confirmed one.
+func (r *AuthRepository) SaveUnconfirmedTOTP(ctx context.Context, userID int64, sealedSecret string) error {
+	saveTOTPSQL, args, buildSqlErr := r.psql.Insert(userTOTPTable).
+		Columns("user_id", "secret", "encrypted").
+		Values(userID, sealedSecret, true).
+		Suffix(`ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			encrypted = EXCLUDED.encrypted,
+			created_at = EXCLUDED.created_at
+			WHERE user_totp.confirmed_at IS NULL`).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, saveTOTPErr := r.db.ExecContext(ctx, saveTOTPSQL, args...)
+	if saveTOTPErr != nil {
+		return fmt.Errorf("error during sql execution: %w", saveTOTPErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrTOTPConfirmed
+	}
+
+	return nil
+}
+
+// EncryptUserTOTP replaces plaintext secret with encrypted one.
+func (r *AuthRepository) EncryptUserTOTP(ctx context.Context, userID int64, sealedSecret string) error {
+	encryptSQL, args, buildSqlErr := r.psql.Update(userTOTPTable).
+		Set("secret", sealedSecret).
+		Set("encrypted", true).
+		Where(sq.Eq{"user_id": userID, "encrypted": false}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	if _, encryptErr := r.db.ExecContext(ctx, encryptSQL, args...); encryptErr != nil {
+		return fmt.Errorf("error during sql execution: %w", encryptErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) ConfirmUserTOTP(ctx context.Context, userID int64, step int64, confirmedAt time.Time) error {
+	confirmSQL, args, buildSqlErr := r.psql.Update(userTOTPTable).
+		Set("confirmed_at", confirmedAt).
+		Set("last_used_step", step).
+		Where(sq.Eq{"user_id": userID, "confirmed_at": nil}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, confirmErr := r.db.ExecContext(ctx, confirmSQL, args...)
+	if confirmErr != nil {
+		return fmt.Errorf("error during sql execution: %w", confirmErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrTOTPConfirmed
+	}
+
+	return nil
+}
+
+// UseTOTPStep remembers time step of accepted code. Steps that are not newer
+// than the last used one are refused, so a code can't be replayed.
+func (r *AuthRepository) UseTOTPStep(ctx context.Context, userID int64, step int64) error {
+	useStepSQL, args, buildSqlErr := r.psql.Update(userTOTPTable).
+		Set("last_used_step", step).
+		Where(sq.Eq{"user_id": userID}).
+		Where(sq.Lt{"last_used_step": step}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, useStepErr := r.db.ExecContext(ctx, useStepSQL, args...)
+	if useStepErr != nil {
+		return fmt.Errorf("error during sql execution: %w", useStepErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrTOTPCodeUsed
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes drops user's previous recovery codes and stores new ones atomically.
+func (r *AuthRepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	deleteCodesSQL, deleteArgs, buildSqlErr := r.psql.Delete(recoveryCodeTable).
+		Where(sq.Eq{"user_id": userID}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	insertCodes := r.psql.Insert(recoveryCodeTable).Columns("code_hash", "user_id")
+	for _, codeHash := range codeHashes {
+		insertCodes = insertCodes.Values(codeHash, userID)
+	}
+	insertCodesSQL, insertArgs, buildSqlErr := insertCodes.ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	if _, deleteCodesErr := tx.ExecContext(ctx, deleteCodesSQL, deleteArgs...); deleteCodesErr != nil {
+		return fmt.Errorf("error during sql execution: %w", deleteCodesErr)
+	}
+	if _, insertCodesErr := tx.ExecContext(ctx, insertCodesSQL, insertArgs...); insertCodesErr != nil {
+		return fmt.Errorf("error during sql execution: %w", insertCodesErr)
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
+
+func (r *AuthRepository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string, usedAt time.Time) error {
+	useCodeSQL, args, buildSqlErr := r.psql.Update(recoveryCodeTable).
+		Set("used_at", usedAt).
+		Where(sq.Eq{"user_id": userID, "code_hash": codeHash, "used_at": nil}).
+		ToSql()
+
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	res, useCodeErr := r.db.ExecContext(ctx, useCodeSQL, args...)
+	if useCodeErr != nil {
+		return fmt.Errorf("error during sql execution: %w", useCodeErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrEntityNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	sq "github.com/Masterminds/squirrel"
+	"strings"
+	"time"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *AuthRepository) SearchUsers(ctx context.Context, filter entity.UserFilter) ([]entity.AuthUser, error) {
+	searchUsers := r.psql.Select("*").
+		From(userTable).
+		Where(sq.Gt{"id": filter.AfterID}).
+		OrderBy("id").
+		Limit(uint64(filter.Limit))
+	if filter.EmailQuery != "" {
+		searchUsers = searchUsers.Where(sq.ILike{"email": "%" + likeEscaper.Replace(filter.EmailQuery) + "%"})
+	}
+
+	searchUsersSQL, args, buildSqlErr := searchUsers.ToSql()
+	if buildSqlErr != nil {
+		return nil, fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	var users []entity.AuthUser
+	if searchUsersErr := r.db.SelectContext(ctx, &users, searchUsersSQL, args...); searchUsersErr != nil {
+		return nil, fmt.Errorf("error during sql executing: %w", searchUsersErr)
+	}
+
+	return users, nil
+}
+
+// UpdateUserStatus changes status only if it is still from, so concurrent changes can't skip the state machine.
+func (r *AuthRepository) UpdateUserStatus(ctx context.Context, userID int64,
+	from entity.UserStatus, to entity.UserStatus, changedAt time.Time) error {
+	updateStatusSQL, args, buildSqlErr := r.psql.Update(userTable).
+		Set("status", to).
+		Set("status_changed_at", changedAt).
+		Where(sq.Eq{"id": userID, "status": from}).
+		ToSql()
+	if buildSqlErr != nil {
+		return fmt.Errorf("can't build sql: %w", buildSqlErr)
+	}
+
+	tx, beginTxErr := r.db.BeginTxx(ctx, nil)
+	if beginTxErr != nil {
+		return fmt.Errorf("can't begin transaction: %w", beginTxErr)
+	}
+	defer tx.Rollback()
+
+	res, updateStatusErr := tx.ExecContext(ctx, updateStatusSQL, args...)
+	if updateStatusErr != nil {
+		return fmt.Errorf("error during sql execution: %w", updateStatusErr)
+	}
+
+	affected, rowsErr := res.RowsAffected()
+	if rowsErr != nil {
+		return fmt.Errorf("can't get affected rows: %w", rowsErr)
+	}
+	if affected == 0 {
+		return service.ErrEntityNotFound
+	}
+
+	if to == entity.UserDeleted {
+		insertEventErr := r.insertOutboxEvent(ctx, tx, entity.UserDeletedEvent,
+			entity.UserEventPayload{UserID: userID}, changedAt)
+		if insertEventErr != nil {
+			return insertEventErr
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return fmt.Errorf("can't commit transaction: %w", commitErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/pkg/api/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type AdminServer struct {
+	auth.UnimplementedAdminServer
+	adminSvc service.Administration
+}
+
+func NewAdminServer(service service.Administration) *AdminServer {
+	return &AdminServer{
+		adminSvc: service,
+	}
+}
+
+func (s *AdminServer) ListUsers(ctx context.Context, req *auth.ListUsersRequest) (*auth.ListUsersResponse, error) {
+	page, listErr := s.adminSvc.ListUsers(ctx, req.GetAccessToken(), entity.UserFilter{
+		EmailQuery: req.GetEmailQuery(),
+		AfterID:    req.GetAfterId(),
+		Limit:      int(req.GetPageSize()),
+	})
+	if listErr != nil {
+		return nil, adminStatusError(listErr)
+	}
+
+	return &auth.ListUsersResponse{Users: convertUsers(page.Users), NextAfterId: page.NextAfterID}, nil
+}
+
+func (s *AdminServer) GetUser(ctx context.Context, req *auth.AdminUserRequest) (*auth.GetUserResponse, error) {
+	user, sessions, getErr := s.adminSvc.GetUser(ctx, req.GetAccessToken(), req.GetUserId())
+	if getErr != nil {
+		return nil, adminStatusError(getErr)
+	}
+
+	return &auth.GetUserResponse{User: convertUser(user), Sessions: convertSessions(sessions)}, nil
+}
+
+func (s *AdminServer) SetUserStatus(ctx context.Context, req *auth.SetUserStatusRequest) (*emptypb.Empty, error) {
+	setStatusErr := s.adminSvc.SetUserStatus(ctx, req.GetAccessToken(), req.GetUserId(), entity.UserStatus(req.GetStatus()))
+	if setStatusErr != nil {
+		return nil, adminStatusError(setStatusErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AdminServer) ForcePasswordReset(ctx context.Context, req *auth.AdminUserRequest) (*emptypb.Empty, error) {
+	if resetErr := s.adminSvc.ForcePasswordReset(ctx, req.GetAccessToken(), req.GetUserId()); resetErr != nil {
+		return nil, adminStatusError(resetErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AdminServer) DeleteUser(ctx context.Context, req *auth.AdminUserRequest) (*emptypb.Empty, error) {
+	if deleteErr := s.adminSvc.DeleteUser(ctx, req.GetAccessToken(), req.GetUserId()); deleteErr != nil {
+		return nil, adminStatusError(deleteErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AdminServer) RevokeUserSessions(ctx context.Context, req *auth.AdminUserRequest) (*emptypb.Empty, error) {
+	if revokeErr := s.adminSvc.RevokeUserSessions(ctx, req.GetAccessToken(), req.GetUserId()); revokeErr != nil {
+		return nil, adminStatusError(revokeErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AdminServer) ListAuditEvents(ctx context.Context,
+	req *auth.ListAuditEventsRequest) (*auth.ListAuditEventsResponse, error) {
+	page, listErr := s.adminSvc.ListAuditEvents(ctx, req.GetAccessToken(), convertListAuditEventsRequest(req))
+	if listErr != nil {
+		return nil, adminStatusError(listErr)
+	}
+
+	return &auth.ListAuditEventsResponse{Events: convertAuditEvents(page.Events), NextAfterId: page.NextAfterID}, nil
+}
+
+func (s *AdminServer) VerifyAuditLog(ctx context.Context,
+	req *auth.VerifyAuditLogRequest) (*auth.VerifyAuditLogResponse, error) {
+	verification, verifyErr := s.adminSvc.VerifyAuditLog(ctx, req.GetAccessToken())
+	if verifyErr != nil {
+		return nil, adminStatusError(verifyErr)
+	}
+
+	return &auth.VerifyAuditLogResponse{
+		Valid:         verification.Valid,
+		CheckedEvents: verification.CheckedEvents,
+		BrokenEventId: verification.BrokenEventID,
+	}, nil
+}
+
+func (s *AdminServer) CreateOAuthClient(ctx context.Context, req *auth.CreateOAuthClientRequest) (*auth.RegisteredClient, error) {
+	client, secret, createErr := s.adminSvc.CreateOAuthClient(ctx, req.GetAccessToken(), entity.OAuthClient{
+		Name:         req.GetName(),
+		RedirectURIs: req.GetRedirectUris(),
+		Scope:        req.GetScope(),
+	}, req.GetConfidential())
+	if autherrors.Is(createErr, autherrors.InvalidOAuthClient) {
+		return nil, status.Error(codes.InvalidArgument, createErr.Error())
+	}
+	if createErr != nil {
+		return nil, adminStatusError(createErr)
+	}
+
+	convertedClient := convertOAuthClient(client)
+	convertedClient.ClientSecret = secret
+
+	return convertedClient, nil
+}
+
+func (s *AdminServer) DeleteOAuthClient(ctx context.Context, req *auth.DeleteOAuthClientRequest) (*emptypb.Empty, error) {
+	deleteErr := s.adminSvc.DeleteOAuthClient(ctx, req.GetAccessToken(), req.GetClientId())
+	if autherrors.Is(deleteErr, autherrors.OAuthClientNotExists) {
+		return nil, status.Error(codes.NotFound, deleteErr.Error())
+	}
+	if deleteErr != nil {
+		return nil, adminStatusError(deleteErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// adminStatusError maps errors of admin actions: they all fail the same ways.
+func adminStatusError(err error) error {
+	if autherrors.OneOf(err, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	if autherrors.Is(err, autherrors.UserNotExists) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	if autherrors.Is(err, autherrors.InvalidUserStatus) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if autherrors.Is(err, autherrors.InvalidStatusTransition) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return internalStatusError(err)
+}
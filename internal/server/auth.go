@@ -33,7 +33,7 @@ func (s *AuthServer) Register(ctx context.Context, req *auth.RegisterRequest) (*
 		return nil, status.Error(codes.InvalidArgument, registerErr.Error())
 	}
 	if registerErr != nil {
-		return nil, status.Error(codes.Internal, registerErr.Error())
+		return nil, internalStatusError(registerErr)
 	}
 
 	return &emptypb.Empty{}, nil
@@ -41,35 +41,55 @@ func (s *AuthServer) Register(ctx context.Context, req *auth.RegisterRequest) (*
 
 func (s *AuthServer) Login(ctx context.Context, req *auth.LoginRequest) (*auth.LoginResponse, error) {
 	loginRequest := convertLoginRequest(req)
-	tokenPair, loginErr := s.authSvc.Login(ctx, loginRequest, req.GetFingerprint())
+	loginResult, loginErr := s.authSvc.Login(ctx, loginRequest, req.GetFingerprint())
 	if autherrors.Is(loginErr, autherrors.UserNotExists) {
 		return nil, status.Error(codes.NotFound, loginErr.Error())
 	}
 	if autherrors.Is(loginErr, autherrors.UserInvalidPassword) {
 		return nil, status.Error(codes.PermissionDenied, loginErr.Error())
 	}
+	if autherrors.Is(loginErr, autherrors.InvalidCredentials) {
+		return nil, status.Error(codes.Unauthenticated, loginErr.Error())
+	}
 	if autherrors.Is(loginErr, autherrors.InvalidFingerprint) {
 		return nil, status.Error(codes.InvalidArgument, loginErr.Error())
 	}
+	if autherrors.Is(loginErr, autherrors.EmailNotVerified) {
+		return nil, status.Error(codes.FailedPrecondition, loginErr.Error())
+	}
+	if autherrors.Is(loginErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, loginErr)
+	}
 
 	if loginErr != nil {
-		return nil, status.Error(codes.Internal, loginErr.Error())
+		return nil, internalStatusError(loginErr)
 	}
 
-	return &auth.LoginResponse{AccessToken: tokenPair.Access.Token, RefreshToken: tokenPair.Refresh.Token}, nil
+	return convertLoginResult(loginResult), nil
 }
 
 func (s *AuthServer) Validate(ctx context.Context, req *auth.ValidateRequest) (*auth.ValidateResponse, error) {
-	userEmail, validateErr := s.authSvc.Validate(ctx, req.GetAccessToken())
+	claims, validateErr := s.authSvc.Validate(ctx, req.GetAccessToken())
 	if autherrors.Is(validateErr, autherrors.InvalidToken) {
 		return nil, status.Error(codes.PermissionDenied, validateErr.Error())
 	}
 
 	if validateErr != nil {
-		return nil, status.Error(codes.Internal, validateErr.Error())
+		return nil, internalStatusError(validateErr)
 	}
 
-	return &auth.ValidateResponse{UserEmail: userEmail}, nil
+	return &auth.ValidateResponse{
+		UserEmail:     claims.Email,
+		SessionId:     claims.SessionID.String(),
+		TokenId:       claims.TokenID.String(),
+		EmailVerified: claims.EmailVerified,
+		Roles:         claims.Roles,
+		Permissions:   claims.Permissions,
+		OrgId:         claims.OrgID,
+		ClientId:      claims.ClientID,
+		PrincipalType: claims.Principal,
+		ApiKey:        claims.APIKey,
+	}, nil
 }
 
 func (s *AuthServer) Refresh(ctx context.Context, req *auth.RefreshRequest) (*auth.RefreshResponse, error) {
@@ -77,7 +97,8 @@ func (s *AuthServer) Refresh(ctx context.Context, req *auth.RefreshRequest) (*au
 	if autherrors.Is(refreshErr, autherrors.InvalidFingerprint) {
 		return nil, status.Error(codes.InvalidArgument, refreshErr.Error())
 	}
-	if autherrors.OneOf(refreshErr, autherrors.InvalidSession, autherrors.InvalidToken) {
+	if autherrors.OneOf(refreshErr, autherrors.InvalidSession, autherrors.InvalidToken, autherrors.RefreshTokenReused,
+		autherrors.NotOrganizationMember) {
 		return nil, status.Error(codes.PermissionDenied, refreshErr.Error())
 	}
 	if autherrors.OneOf(refreshErr, autherrors.UserExists, autherrors.SessionNotExists) {
@@ -85,8 +106,542 @@ func (s *AuthServer) Refresh(ctx context.Context, req *auth.RefreshRequest) (*au
 	}
 
 	if refreshErr != nil {
-		return nil, status.Error(codes.Internal, refreshErr.Error())
+		return nil, internalStatusError(refreshErr)
+	}
+
+	return &auth.RefreshResponse{AccessToken: tokenPair.Access.Token, RefreshToken: tokenPair.Refresh.Token}, nil
+}
+
+func (s *AuthServer) Logout(ctx context.Context, req *auth.LogoutRequest) (*emptypb.Empty, error) {
+	logoutErr := s.authSvc.Logout(ctx, req.GetRefreshToken())
+	if autherrors.Is(logoutErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, logoutErr.Error())
+	}
+	if autherrors.Is(logoutErr, autherrors.SessionNotExists) {
+		return nil, status.Error(codes.NotFound, logoutErr.Error())
+	}
+
+	if logoutErr != nil {
+		return nil, internalStatusError(logoutErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) LogoutAll(ctx context.Context, req *auth.LogoutAllRequest) (*emptypb.Empty, error) {
+	logoutErr := s.authSvc.LogoutAll(ctx, req.GetAccessToken())
+	if autherrors.Is(logoutErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, logoutErr.Error())
+	}
+	if autherrors.Is(logoutErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, logoutErr.Error())
+	}
+
+	if logoutErr != nil {
+		return nil, internalStatusError(logoutErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ListSessions(ctx context.Context, req *auth.ListSessionsRequest) (*auth.ListSessionsResponse, error) {
+	sessions, listErr := s.authSvc.ListSessions(ctx, req.GetAccessToken())
+	if autherrors.Is(listErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, listErr.Error())
+	}
+	if autherrors.Is(listErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, listErr.Error())
+	}
+
+	if listErr != nil {
+		return nil, internalStatusError(listErr)
+	}
+
+	return &auth.ListSessionsResponse{Sessions: convertSessions(sessions)}, nil
+}
+
+func (s *AuthServer) RevokeSession(ctx context.Context, req *auth.RevokeSessionRequest) (*emptypb.Empty, error) {
+	revokeErr := s.authSvc.RevokeSession(ctx, req.GetAccessToken(), req.GetSessionId())
+	if autherrors.Is(revokeErr, autherrors.InvalidSessionID) {
+		return nil, status.Error(codes.InvalidArgument, revokeErr.Error())
+	}
+	if autherrors.Is(revokeErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, revokeErr.Error())
+	}
+	if autherrors.OneOf(revokeErr, autherrors.UserNotExists, autherrors.SessionNotExists) {
+		return nil, status.Error(codes.NotFound, revokeErr.Error())
+	}
+
+	if revokeErr != nil {
+		return nil, internalStatusError(revokeErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) CreateAPIKey(ctx context.Context, req *auth.CreateAPIKeyRequest) (*auth.CreateAPIKeyResponse, error) {
+	key, plainKey, createErr := s.authSvc.CreateAPIKey(ctx, req.GetAccessToken(), convertCreateAPIKeyRequest(req))
+	if autherrors.Is(createErr, autherrors.InvalidAPIKey) {
+		return nil, status.Error(codes.InvalidArgument, createErr.Error())
+	}
+	if autherrors.OneOf(createErr, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, createErr.Error())
+	}
+	if autherrors.Is(createErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, createErr.Error())
+	}
+
+	if createErr != nil {
+		return nil, internalStatusError(createErr)
+	}
+
+	return &auth.CreateAPIKeyResponse{ApiKey: convertAPIKey(key), Key: plainKey}, nil
+}
+
+func (s *AuthServer) ListAPIKeys(ctx context.Context, req *auth.ListAPIKeysRequest) (*auth.ListAPIKeysResponse, error) {
+	keys, listErr := s.authSvc.ListAPIKeys(ctx, req.GetAccessToken())
+	if autherrors.OneOf(listErr, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, listErr.Error())
+	}
+	if autherrors.Is(listErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, listErr.Error())
+	}
+
+	if listErr != nil {
+		return nil, internalStatusError(listErr)
+	}
+
+	return &auth.ListAPIKeysResponse{ApiKeys: convertAPIKeys(keys)}, nil
+}
+
+func (s *AuthServer) RevokeAPIKey(ctx context.Context, req *auth.RevokeAPIKeyRequest) (*emptypb.Empty, error) {
+	revokeErr := s.authSvc.RevokeAPIKey(ctx, req.GetAccessToken(), req.GetApiKeyId())
+	if autherrors.OneOf(revokeErr, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, revokeErr.Error())
+	}
+	if autherrors.OneOf(revokeErr, autherrors.UserNotExists, autherrors.APIKeyNotExists) {
+		return nil, status.Error(codes.NotFound, revokeErr.Error())
+	}
+
+	if revokeErr != nil {
+		return nil, internalStatusError(revokeErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ChangePassword(ctx context.Context, req *auth.ChangePasswordRequest) (*emptypb.Empty, error) {
+	changeErr := s.authSvc.ChangePassword(ctx, req.GetAccessToken(), convertChangePasswordRequest(req))
+	if autherrors.OneOf(changeErr, autherrors.InvalidToken, autherrors.UserInvalidPassword) {
+		return nil, status.Error(codes.PermissionDenied, changeErr.Error())
+	}
+	if autherrors.Is(changeErr, autherrors.WeakPassword) {
+		return nil, status.Error(codes.InvalidArgument, changeErr.Error())
+	}
+	if autherrors.Is(changeErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, changeErr.Error())
+	}
+	if autherrors.Is(changeErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, changeErr)
+	}
+
+	if changeErr != nil {
+		return nil, internalStatusError(changeErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) RequestPasswordReset(ctx context.Context,
+	req *auth.RequestPasswordResetRequest) (*emptypb.Empty, error) {
+	requestErr := s.authSvc.RequestPasswordReset(ctx, req.GetEmail())
+	if autherrors.Is(requestErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, requestErr)
+	}
+	if requestErr != nil {
+		return nil, internalStatusError(requestErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ConfirmPasswordReset(ctx context.Context,
+	req *auth.ConfirmPasswordResetRequest) (*emptypb.Empty, error) {
+	confirmErr := s.authSvc.ConfirmPasswordReset(ctx, req.GetResetToken(), req.GetNewPassword())
+	if autherrors.Is(confirmErr, autherrors.WeakPassword) {
+		return nil, status.Error(codes.InvalidArgument, confirmErr.Error())
+	}
+	if autherrors.Is(confirmErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, confirmErr.Error())
+	}
+
+	if confirmErr != nil {
+		return nil, internalStatusError(confirmErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) VerifyEmail(ctx context.Context, req *auth.VerifyEmailRequest) (*emptypb.Empty, error) {
+	verifyErr := s.authSvc.VerifyEmail(ctx, req.GetVerificationToken())
+	if autherrors.Is(verifyErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, verifyErr.Error())
+	}
+
+	if verifyErr != nil {
+		return nil, internalStatusError(verifyErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ResendVerification(ctx context.Context,
+	req *auth.ResendVerificationRequest) (*emptypb.Empty, error) {
+	resendErr := s.authSvc.ResendVerification(ctx, req.GetEmail())
+	if autherrors.Is(resendErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, resendErr)
+	}
+	if resendErr != nil {
+		return nil, internalStatusError(resendErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) EnrollTOTP(ctx context.Context, req *auth.EnrollTOTPRequest) (*auth.EnrollTOTPResponse, error) {
+	enrollment, enrollErr := s.authSvc.EnrollTOTP(ctx, req.GetAccessToken())
+	if autherrors.Is(enrollErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, enrollErr.Error())
+	}
+	if autherrors.Is(enrollErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, enrollErr.Error())
+	}
+	if autherrors.Is(enrollErr, autherrors.MFAAlreadyEnabled) {
+		return nil, status.Error(codes.FailedPrecondition, enrollErr.Error())
+	}
+
+	if enrollErr != nil {
+		return nil, internalStatusError(enrollErr)
+	}
+
+	return &auth.EnrollTOTPResponse{Secret: enrollment.Secret, Uri: enrollment.URI}, nil
+}
+
+func (s *AuthServer) ConfirmTOTP(ctx context.Context, req *auth.ConfirmTOTPRequest) (*auth.ConfirmTOTPResponse, error) {
+	recoveryCodes, confirmErr := s.authSvc.ConfirmTOTP(ctx, req.GetAccessToken(), req.GetCode())
+	if autherrors.OneOf(confirmErr, autherrors.InvalidToken, autherrors.InvalidMFACode) {
+		return nil, status.Error(codes.PermissionDenied, confirmErr.Error())
+	}
+	if autherrors.Is(confirmErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, confirmErr.Error())
+	}
+	if autherrors.OneOf(confirmErr, autherrors.MFANotEnrolled, autherrors.MFAAlreadyEnabled) {
+		return nil, status.Error(codes.FailedPrecondition, confirmErr.Error())
+	}
+
+	if confirmErr != nil {
+		return nil, internalStatusError(confirmErr)
+	}
+
+	return &auth.ConfirmTOTPResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *AuthServer) VerifyMFA(ctx context.Context, req *auth.VerifyMFARequest) (*auth.VerifyMFAResponse, error) {
+	tokenPair, verifyErr := s.authSvc.VerifyMFA(ctx, req.GetMfaToken(), req.GetCode(), req.GetFingerprint())
+	if autherrors.Is(verifyErr, autherrors.InvalidFingerprint) {
+		return nil, status.Error(codes.InvalidArgument, verifyErr.Error())
+	}
+	if autherrors.OneOf(verifyErr, autherrors.InvalidToken, autherrors.InvalidMFACode) {
+		return nil, status.Error(codes.PermissionDenied, verifyErr.Error())
+	}
+	if autherrors.Is(verifyErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, verifyErr.Error())
+	}
+	if autherrors.Is(verifyErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, verifyErr)
+	}
+
+	if verifyErr != nil {
+		return nil, internalStatusError(verifyErr)
+	}
+
+	return &auth.VerifyMFAResponse{AccessToken: tokenPair.Access.Token, RefreshToken: tokenPair.Refresh.Token}, nil
+}
+
+func (s *AuthServer) BeginPasskeyRegistration(ctx context.Context,
+	req *auth.BeginPasskeyRegistrationRequest) (*auth.PasskeyChallenge, error) {
+	challenge, beginErr := s.authSvc.BeginPasskeyRegistration(ctx, req.GetAccessToken())
+	if autherrors.Is(beginErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, beginErr.Error())
+	}
+	if autherrors.Is(beginErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, beginErr.Error())
+	}
+
+	if beginErr != nil {
+		return nil, internalStatusError(beginErr)
+	}
+
+	return convertPasskeyChallenge(challenge), nil
+}
+
+func (s *AuthServer) FinishPasskeyRegistration(ctx context.Context,
+	req *auth.FinishPasskeyRegistrationRequest) (*emptypb.Empty, error) {
+	finishErr := s.authSvc.FinishPasskeyRegistration(ctx, req.GetAccessToken(), req.GetCeremonyId(), []byte(req.GetCredential()))
+	if autherrors.Is(finishErr, autherrors.InvalidCeremonyID) {
+		return nil, status.Error(codes.InvalidArgument, finishErr.Error())
+	}
+	if autherrors.OneOf(finishErr, autherrors.InvalidToken, autherrors.InvalidPasskey) {
+		return nil, status.Error(codes.PermissionDenied, finishErr.Error())
+	}
+	if autherrors.OneOf(finishErr, autherrors.UserNotExists, autherrors.CeremonyNotExists) {
+		return nil, status.Error(codes.NotFound, finishErr.Error())
+	}
+
+	if finishErr != nil {
+		return nil, internalStatusError(finishErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) BeginPasskeyLogin(ctx context.Context, _ *emptypb.Empty) (*auth.PasskeyChallenge, error) {
+	challenge, beginErr := s.authSvc.BeginPasskeyLogin(ctx)
+	if beginErr != nil {
+		return nil, internalStatusError(beginErr)
+	}
+
+	return convertPasskeyChallenge(challenge), nil
+}
+
+func (s *AuthServer) FinishPasskeyLogin(ctx context.Context,
+	req *auth.FinishPasskeyLoginRequest) (*auth.FinishPasskeyLoginResponse, error) {
+	tokenPair, finishErr := s.authSvc.FinishPasskeyLogin(ctx, req.GetCeremonyId(), []byte(req.GetCredential()), req.GetFingerprint())
+	if autherrors.OneOf(finishErr, autherrors.InvalidFingerprint, autherrors.InvalidCeremonyID) {
+		return nil, status.Error(codes.InvalidArgument, finishErr.Error())
+	}
+	if autherrors.OneOf(finishErr, autherrors.InvalidPasskey, autherrors.PasskeyCloneSuspected) {
+		return nil, status.Error(codes.PermissionDenied, finishErr.Error())
+	}
+	if autherrors.Is(finishErr, autherrors.CeremonyNotExists) {
+		return nil, status.Error(codes.NotFound, finishErr.Error())
+	}
+	if autherrors.Is(finishErr, autherrors.EmailNotVerified) {
+		return nil, status.Error(codes.FailedPrecondition, finishErr.Error())
+	}
+
+	if finishErr != nil {
+		return nil, internalStatusError(finishErr)
+	}
+
+	return &auth.FinishPasskeyLoginResponse{AccessToken: tokenPair.Access.Token, RefreshToken: tokenPair.Refresh.Token}, nil
+}
+
+func (s *AuthServer) StartEmailLogin(ctx context.Context, req *auth.StartEmailLoginRequest) (*emptypb.Empty, error) {
+	startErr := s.authSvc.StartEmailLogin(ctx, req.GetEmail(), req.GetFingerprint())
+	if autherrors.Is(startErr, autherrors.InvalidFingerprint) {
+		return nil, status.Error(codes.InvalidArgument, startErr.Error())
+	}
+	if autherrors.Is(startErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, startErr)
+	}
+
+	if startErr != nil {
+		return nil, internalStatusError(startErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) CompleteEmailLogin(ctx context.Context, req *auth.CompleteEmailLoginRequest) (*auth.LoginResponse, error) {
+	loginResult, completeErr := s.authSvc.CompleteEmailLogin(ctx, convertCompleteEmailLoginRequest(req), req.GetFingerprint())
+	if autherrors.Is(completeErr, autherrors.InvalidFingerprint) {
+		return nil, status.Error(codes.InvalidArgument, completeErr.Error())
+	}
+	if autherrors.OneOf(completeErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, completeErr.Error())
+	}
+	if autherrors.Is(completeErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, completeErr.Error())
+	}
+	if autherrors.Is(completeErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, completeErr)
+	}
+
+	if completeErr != nil {
+		return nil, internalStatusError(completeErr)
+	}
+
+	return convertLoginResult(loginResult), nil
+}
+
+func (s *AuthServer) SaveRole(ctx context.Context, req *auth.SaveRoleRequest) (*emptypb.Empty, error) {
+	saveErr := s.authSvc.SaveRole(ctx, req.GetAccessToken(), convertSaveRoleRequest(req))
+	if autherrors.OneOf(saveErr, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, saveErr.Error())
+	}
+	if autherrors.OneOf(saveErr, autherrors.InvalidRoleName, autherrors.InvalidPermission) {
+		return nil, status.Error(codes.InvalidArgument, saveErr.Error())
+	}
+	if autherrors.Is(saveErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, saveErr.Error())
+	}
+	if autherrors.Is(saveErr, autherrors.AdminRoleLocked) {
+		return nil, status.Error(codes.FailedPrecondition, saveErr.Error())
+	}
+
+	if saveErr != nil {
+		return nil, internalStatusError(saveErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) ListRoles(ctx context.Context, req *auth.ListRolesRequest) (*auth.ListRolesResponse, error) {
+	roles, listErr := s.authSvc.ListRoles(ctx, req.GetAccessToken())
+	if autherrors.OneOf(listErr, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, listErr.Error())
+	}
+	if autherrors.Is(listErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, listErr.Error())
+	}
+
+	if listErr != nil {
+		return nil, internalStatusError(listErr)
+	}
+
+	return &auth.ListRolesResponse{Roles: convertRoles(roles)}, nil
+}
+
+func (s *AuthServer) AssignRole(ctx context.Context, req *auth.AssignRoleRequest) (*emptypb.Empty, error) {
+	assignErr := s.authSvc.AssignRole(ctx, req.GetAccessToken(), req.GetUserEmail(), req.GetRole())
+	if autherrors.OneOf(assignErr, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, assignErr.Error())
+	}
+	if autherrors.OneOf(assignErr, autherrors.UserNotExists, autherrors.RoleNotExists) {
+		return nil, status.Error(codes.NotFound, assignErr.Error())
+	}
+
+	if assignErr != nil {
+		return nil, internalStatusError(assignErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) RevokeRole(ctx context.Context, req *auth.RevokeRoleRequest) (*emptypb.Empty, error) {
+	revokeErr := s.authSvc.RevokeRole(ctx, req.GetAccessToken(), req.GetUserEmail(), req.GetRole())
+	if autherrors.OneOf(revokeErr, autherrors.InvalidToken, autherrors.AccessDenied) {
+		return nil, status.Error(codes.PermissionDenied, revokeErr.Error())
+	}
+	if autherrors.OneOf(revokeErr, autherrors.UserNotExists, autherrors.RoleNotAssigned) {
+		return nil, status.Error(codes.NotFound, revokeErr.Error())
+	}
+	if autherrors.Is(revokeErr, autherrors.AdminRoleLocked) {
+		return nil, status.Error(codes.FailedPrecondition, revokeErr.Error())
+	}
+
+	if revokeErr != nil {
+		return nil, internalStatusError(revokeErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) CreateOrganization(ctx context.Context,
+	req *auth.CreateOrganizationRequest) (*auth.Organization, error) {
+	org, createErr := s.authSvc.CreateOrganization(ctx, req.GetAccessToken(), req.GetName())
+	if autherrors.Is(createErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, createErr.Error())
+	}
+	if autherrors.Is(createErr, autherrors.InvalidOrganizationName) {
+		return nil, status.Error(codes.InvalidArgument, createErr.Error())
+	}
+	if autherrors.Is(createErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, createErr.Error())
+	}
+
+	if createErr != nil {
+		return nil, internalStatusError(createErr)
+	}
+
+	return &auth.Organization{Id: org.ID, Name: org.Name}, nil
+}
+
+func (s *AuthServer) ListOrganizations(ctx context.Context,
+	req *auth.ListOrganizationsRequest) (*auth.ListOrganizationsResponse, error) {
+	memberships, listErr := s.authSvc.ListOrganizations(ctx, req.GetAccessToken())
+	if autherrors.Is(listErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, listErr.Error())
+	}
+	if autherrors.Is(listErr, autherrors.UserNotExists) {
+		return nil, status.Error(codes.NotFound, listErr.Error())
+	}
+
+	if listErr != nil {
+		return nil, internalStatusError(listErr)
+	}
+
+	return &auth.ListOrganizationsResponse{Memberships: convertMemberships(memberships)}, nil
+}
+
+func (s *AuthServer) InviteMember(ctx context.Context, req *auth.InviteMemberRequest) (*emptypb.Empty, error) {
+	inviteErr := s.authSvc.InviteMember(ctx, req.GetAccessToken(), convertInviteMemberRequest(req))
+	if autherrors.OneOf(inviteErr, autherrors.InvalidToken, autherrors.AccessDenied, autherrors.NotOrganizationMember) {
+		return nil, status.Error(codes.PermissionDenied, inviteErr.Error())
+	}
+	if autherrors.Is(inviteErr, autherrors.InvalidEmail) {
+		return nil, status.Error(codes.InvalidArgument, inviteErr.Error())
+	}
+	if autherrors.OneOf(inviteErr, autherrors.UserNotExists, autherrors.RoleNotExists) {
+		return nil, status.Error(codes.NotFound, inviteErr.Error())
+	}
+
+	if inviteErr != nil {
+		return nil, internalStatusError(inviteErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *AuthServer) AcceptInvitation(ctx context.Context, req *auth.AcceptInvitationRequest) (*auth.Membership, error) {
+	membership, acceptErr := s.authSvc.AcceptInvitation(ctx, req.GetAccessToken(), req.GetInvitationToken())
+	if autherrors.Is(acceptErr, autherrors.InvalidToken) {
+		return nil, status.Error(codes.PermissionDenied, acceptErr.Error())
+	}
+	if autherrors.OneOf(acceptErr, autherrors.UserNotExists, autherrors.InvitationNotExists) {
+		return nil, status.Error(codes.NotFound, acceptErr.Error())
+	}
+
+	if acceptErr != nil {
+		return nil, internalStatusError(acceptErr)
+	}
+
+	return convertMembership(membership), nil
+}
+
+func (s *AuthServer) SwitchOrganization(ctx context.Context,
+	req *auth.SwitchOrganizationRequest) (*auth.RefreshResponse, error) {
+	tokenPair, switchErr := s.authSvc.SwitchOrganization(ctx, req.GetRefreshToken(), req.GetFingerprint(),
+		req.GetOrganizationId())
+	if autherrors.Is(switchErr, autherrors.InvalidFingerprint) {
+		return nil, status.Error(codes.InvalidArgument, switchErr.Error())
+	}
+	if autherrors.OneOf(switchErr, autherrors.InvalidSession, autherrors.InvalidToken, autherrors.RefreshTokenReused,
+		autherrors.NotOrganizationMember) {
+		return nil, status.Error(codes.PermissionDenied, switchErr.Error())
+	}
+	if autherrors.OneOf(switchErr, autherrors.UserNotExists, autherrors.SessionNotExists) {
+		return nil, status.Error(codes.NotFound, switchErr.Error())
+	}
+
+	if switchErr != nil {
+		return nil, internalStatusError(switchErr)
 	}
 
 	return &auth.RefreshResponse{AccessToken: tokenPair.Access.Token, RefreshToken: tokenPair.Refresh.Token}, nil
 }
+
+func (s *AuthServer) GetJWKS(ctx context.Context, _ *emptypb.Empty) (*auth.JWKSResponse, error) {
+	return &auth.JWKSResponse{Keys: convertJSONWebKeys(s.authSvc.GetJWKS(ctx))}, nil
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/DmitySH/go-auth-service/internal/entity"
 	"github.com/DmitySH/go-auth-service/pkg/api/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func convertRegisterRequest(req *auth.RegisterRequest) entity.AuthUser {
@@ -18,3 +19,280 @@ func convertLoginRequest(req *auth.LoginRequest) entity.AuthUser {
 		Password: req.GetPassword(),
 	}
 }
+
+func convertCompleteEmailLoginRequest(req *auth.CompleteEmailLoginRequest) entity.EmailLogin {
+	return entity.EmailLogin{
+		Token: req.GetToken(),
+		Email: req.GetEmail(),
+		Code:  req.GetCode(),
+	}
+}
+
+func convertLoginResult(result entity.LoginResult) *auth.LoginResponse {
+	if result.MFARequired {
+		return &auth.LoginResponse{MfaRequired: true, MfaToken: result.MFAToken.Token}
+	}
+
+	return &auth.LoginResponse{AccessToken: result.Tokens.Access.Token, RefreshToken: result.Tokens.Refresh.Token}
+}
+
+func convertChangePasswordRequest(req *auth.ChangePasswordRequest) entity.PasswordChange {
+	return entity.PasswordChange{
+		CurrentPassword:      req.GetCurrentPassword(),
+		NewPassword:          req.GetNewPassword(),
+		RevokeOtherSessions:  req.GetRevokeOtherSessions(),
+		RevokeCurrentSession: req.GetRevokeCurrentSession(),
+	}
+}
+
+func convertPasskeyChallenge(challenge entity.PasskeyChallenge) *auth.PasskeyChallenge {
+	return &auth.PasskeyChallenge{
+		CeremonyId: challenge.CeremonyID.String(),
+		Options:    string(challenge.Options),
+	}
+}
+
+func convertSaveRoleRequest(req *auth.SaveRoleRequest) entity.Role {
+	return entity.Role{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Permissions: req.GetPermissions(),
+	}
+}
+
+func convertRoles(roles []entity.Role) []*auth.Role {
+	converted := make([]*auth.Role, 0, len(roles))
+	for _, role := range roles {
+		converted = append(converted, &auth.Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+		})
+	}
+
+	return converted
+}
+
+func convertInviteMemberRequest(req *auth.InviteMemberRequest) entity.OrganizationInvitation {
+	return entity.OrganizationInvitation{
+		OrgID: req.GetOrganizationId(),
+		Email: req.GetEmail(),
+		Role:  req.GetRole(),
+	}
+}
+
+func convertMembership(membership entity.Membership) *auth.Membership {
+	return &auth.Membership{
+		OrganizationId:   membership.OrgID,
+		OrganizationName: membership.OrgName,
+		Role:             membership.Role,
+	}
+}
+
+func convertMemberships(memberships []entity.Membership) []*auth.Membership {
+	converted := make([]*auth.Membership, 0, len(memberships))
+	for _, membership := range memberships {
+		converted = append(converted, convertMembership(membership))
+	}
+
+	return converted
+}
+
+func convertUser(user entity.AuthUser) *auth.User {
+	converted := &auth.User{
+		Id:            user.ID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Status:        string(user.Status),
+	}
+	if user.StatusChangedAt != nil {
+		converted.StatusChangedAt = timestamppb.New(*user.StatusChangedAt)
+	}
+
+	return converted
+}
+
+func convertUsers(users []entity.AuthUser) []*auth.User {
+	converted := make([]*auth.User, 0, len(users))
+	for _, user := range users {
+		converted = append(converted, convertUser(user))
+	}
+
+	return converted
+}
+
+func convertListAuditEventsRequest(req *auth.ListAuditEventsRequest) entity.AuditFilter {
+	filter := entity.AuditFilter{
+		SubjectUserID: req.GetUserId(),
+		AfterID:       req.GetAfterId(),
+		Limit:         int(req.GetPageSize()),
+	}
+	if req.GetFrom() != nil {
+		from := req.GetFrom().AsTime()
+		filter.From = &from
+	}
+	if req.GetTo() != nil {
+		to := req.GetTo().AsTime()
+		filter.To = &to
+	}
+
+	return filter
+}
+
+func convertAuditEvents(events []entity.AuditEvent) []*auth.AuditEvent {
+	converted := make([]*auth.AuditEvent, 0, len(events))
+	for _, event := range events {
+		converted = append(converted, &auth.AuditEvent{
+			Id:            event.ID,
+			Type:          event.Type,
+			Actor:         event.Actor,
+			SubjectUserId: event.SubjectUserID,
+			Details:       event.Details,
+			IpAddress:     event.IPAddress,
+			UserAgent:     event.UserAgent,
+			RequestId:     event.RequestID,
+			Outcome:       event.Outcome,
+			CreatedAt:     timestamppb.New(event.CreatedAt),
+			PrevHash:      event.PrevHash,
+			Hash:          event.Hash,
+		})
+	}
+
+	return converted
+}
+
+func convertOAuthClient(client entity.OAuthClient) *auth.RegisteredClient {
+	return &auth.RegisteredClient{
+		ClientId:     client.ID,
+		Name:         client.Name,
+		RedirectUris: client.RedirectURIs,
+		Confidential: client.IsConfidential(),
+		CreatedAt:    timestamppb.New(client.CreatedAt),
+		Scope:        client.Scope,
+	}
+}
+
+func convertAuthorizationParams(params *auth.AuthorizationParams) entity.AuthorizationRequest {
+	return entity.AuthorizationRequest{
+		ClientID:            params.GetClientId(),
+		RedirectURI:         params.GetRedirectUri(),
+		ResponseType:        params.GetResponseType(),
+		Scope:               params.GetScope(),
+		State:               params.GetState(),
+		CodeChallenge:       params.GetCodeChallenge(),
+		CodeChallengeMethod: params.GetCodeChallengeMethod(),
+		Nonce:               params.GetNonce(),
+	}
+}
+
+func convertTokenRequest(req *auth.TokenRequest) entity.OAuthTokenRequest {
+	return entity.OAuthTokenRequest{
+		GrantType:    req.GetGrantType(),
+		ClientID:     req.GetClientId(),
+		ClientSecret: req.GetClientSecret(),
+		Code:         req.GetCode(),
+		RedirectURI:  req.GetRedirectUri(),
+		CodeVerifier: req.GetCodeVerifier(),
+		RefreshToken: req.GetRefreshToken(),
+		Scope:        req.GetScope(),
+		DeviceCode:   req.GetDeviceCode(),
+	}
+}
+
+func convertJSONWebKeys(keys []entity.JSONWebKey) []*auth.JSONWebKey {
+	converted := make([]*auth.JSONWebKey, 0, len(keys))
+	for _, key := range keys {
+		converted = append(converted, &auth.JSONWebKey{
+			Kty: key.Kty,
+			Use: key.Use,
+			Kid: key.Kid,
+			Alg: key.Alg,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+
+	return converted
+}
+
+func convertSessions(sessions []entity.Session) []*auth.Session {
+	converted := make([]*auth.Session, 0, len(sessions))
+	for _, session := range sessions {
+		convertedSession := &auth.Session{
+			Id:        session.FamilyID.String(),
+			IpAddress: session.IPAddress,
+			UserAgent: session.UserAgent,
+			CreatedAt: timestamppb.New(session.CreatedAt),
+			ExpiresAt: timestamppb.New(session.ExpiresAt),
+		}
+		if session.LastRefreshedAt != nil {
+			convertedSession.LastRefreshedAt = timestamppb.New(*session.LastRefreshedAt)
+		}
+
+		converted = append(converted, convertedSession)
+	}
+
+	return converted
+}
+
+func convertCreateAPIKeyRequest(req *auth.CreateAPIKeyRequest) entity.APIKey {
+	key := entity.APIKey{
+		Name:  req.GetName(),
+		Scope: req.GetScope(),
+	}
+	if req.GetExpiresAt() != nil {
+		expiresAt := req.GetExpiresAt().AsTime()
+		key.ExpiresAt = &expiresAt
+	}
+
+	return key
+}
+
+func convertAPIKey(key entity.APIKey) *auth.APIKey {
+	converted := &auth.APIKey{
+		Id:        key.ID.String(),
+		Name:      key.Name,
+		Scope:     key.Scope,
+		CreatedAt: timestamppb.New(key.CreatedAt),
+	}
+	if key.ExpiresAt != nil {
+		converted.ExpiresAt = timestamppb.New(*key.ExpiresAt)
+	}
+	if key.LastUsedAt != nil {
+		converted.LastUsedAt = timestamppb.New(*key.LastUsedAt)
+	}
+
+	return converted
+}
+
+func convertAPIKeys(keys []entity.APIKey) []*auth.APIKey {
+	converted := make([]*auth.APIKey, 0, len(keys))
+	for _, key := range keys {
+		converted = append(converted, convertAPIKey(key))
+	}
+
+	return converted
+}
+
+func convertOpenIDConfiguration(cfg entity.OpenIDConfiguration) *auth.OpenIDConfiguration {
+	return &auth.OpenIDConfiguration{
+		Issuer:                            cfg.Issuer,
+		AuthorizationEndpoint:             cfg.AuthorizationEndpoint,
+		TokenEndpoint:                     cfg.TokenEndpoint,
+		UserinfoEndpoint:                  cfg.UserInfoEndpoint,
+		JwksUri:                           cfg.JWKSURI,
+		ScopesSupported:                   cfg.ScopesSupported,
+		ResponseTypesSupported:            cfg.ResponseTypesSupported,
+		GrantTypesSupported:               cfg.GrantTypesSupported,
+		SubjectTypesSupported:             cfg.SubjectTypesSupported,
+		IdTokenSigningAlgValuesSupported:  cfg.IDTokenSigningAlgValuesSupported,
+		TokenEndpointAuthMethodsSupported: cfg.TokenEndpointAuthMethodsSupported,
+		CodeChallengeMethodsSupported:     cfg.CodeChallengeMethodsSupported,
+		ClaimsSupported:                   cfg.ClaimsSupported,
+		AcrValuesSupported:                cfg.ACRValuesSupported,
+		DeviceAuthorizationEndpoint:       cfg.DeviceAuthorizationEndpoint,
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"strconv"
+)
+
+const retryAfterHeader = "retry-after"
+
+// retryableStatusError attaches RetryInfo detail and retry-after header (in seconds),
+// which gateway passes to HTTP clients as Grpc-Metadata-Retry-After.
+func retryableStatusError(ctx context.Context, code codes.Code, err error) error {
+	st := status.New(code, err.Error())
+
+	retryAfter, ok := autherrors.RetryAfter(err)
+	if !ok {
+		return st.Err()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfterHeader, strconv.Itoa(int(retryAfter.Seconds()))))
+
+	detailed, detailsErr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if detailsErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
+
+// internalStatusError maps errors left after method specific checks. Account status
+// errors can come from any method that accepts token, so they are handled here.
+func internalStatusError(err error) error {
+	if autherrors.OneOf(err, autherrors.UserSuspended, autherrors.UserDeleted) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	if autherrors.Is(err, autherrors.UserPending) {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
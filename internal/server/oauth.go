@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/pkg/api/auth"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	oauthErrorDomain      = "oauth"
+	redirectableErrorKey  = "redirectable"
+	bearerTokenType       = "Bearer"
+	serverOAuthError      = "server_error"
+	invalidRequestError   = "invalid_request"
+	invalidClientError    = "invalid_client"
+	invalidGrantError     = "invalid_grant"
+	invalidScopeError     = "invalid_scope"
+	unsupportedGrantError = "unsupported_grant_type"
+	unsupportedTypeError  = "unsupported_response_type"
+	unauthorizedClient    = "unauthorized_client"
+	authorizationPending  = "authorization_pending"
+	slowDownError         = "slow_down"
+	expiredTokenError     = "expired_token"
+	accessDeniedError     = "access_denied"
+)
+
+type OAuthServer struct {
+	auth.UnimplementedOAuthServer
+	oauthSvc service.OAuthAuthorization
+}
+
+func NewOAuthServer(service service.OAuthAuthorization) *OAuthServer {
+	return &OAuthServer{
+		oauthSvc: service,
+	}
+}
+
+func (s *OAuthServer) ValidateAuthorization(ctx context.Context,
+	req *auth.ValidateAuthorizationRequest) (*auth.ValidateAuthorizationResponse, error) {
+	prompt, validateErr := s.oauthSvc.ValidateAuthorizationRequest(ctx, req.GetAccessToken(),
+		convertAuthorizationParams(req.GetParams()))
+	if validateErr != nil {
+		return nil, oauthStatusError(validateErr)
+	}
+
+	return &auth.ValidateAuthorizationResponse{
+		ClientName:     prompt.ClientName,
+		Scope:          prompt.Scope,
+		ConsentGranted: prompt.ConsentGranted,
+	}, nil
+}
+
+func (s *OAuthServer) Authorize(ctx context.Context, req *auth.AuthorizeRequest) (*auth.AuthorizeResponse, error) {
+	redirectURI, authorizeErr := s.oauthSvc.Authorize(ctx, req.GetAccessToken(),
+		convertAuthorizationParams(req.GetParams()), req.GetApproved())
+	if authorizeErr != nil {
+		return nil, oauthStatusError(authorizeErr)
+	}
+
+	return &auth.AuthorizeResponse{RedirectUri: redirectURI}, nil
+}
+
+func (s *OAuthServer) Token(ctx context.Context, req *auth.TokenRequest) (*auth.TokenResponse, error) {
+	tokens, exchangeErr := s.oauthSvc.ExchangeOAuthToken(ctx, convertTokenRequest(req))
+	if exchangeErr != nil {
+		return nil, oauthStatusError(exchangeErr)
+	}
+
+	return &auth.TokenResponse{
+		AccessToken:  tokens.Access.Token,
+		TokenType:    bearerTokenType,
+		ExpiresIn:    int64(time.Until(tokens.Access.ExpiresAt).Seconds()),
+		RefreshToken: tokens.Refresh.Token,
+		Scope:        tokens.Scope,
+		IdToken:      tokens.IDToken,
+	}, nil
+}
+
+func (s *OAuthServer) StartDeviceAuthorization(ctx context.Context,
+	req *auth.StartDeviceAuthorizationRequest) (*auth.StartDeviceAuthorizationResponse, error) {
+	start, startErr := s.oauthSvc.StartDeviceAuthorization(ctx, req.GetClientId(), req.GetClientSecret(), req.GetScope())
+	if startErr != nil {
+		return nil, oauthStatusError(startErr)
+	}
+
+	return &auth.StartDeviceAuthorizationResponse{
+		DeviceCode:              start.DeviceCode,
+		UserCode:                start.UserCode,
+		VerificationUri:         start.VerificationURI,
+		VerificationUriComplete: start.VerificationURIComplete,
+		ExpiresIn:               int64(time.Until(start.ExpiresAt).Seconds()),
+		Interval:                int64(start.PollInterval.Seconds()),
+	}, nil
+}
+
+func (s *OAuthServer) LookupDeviceAuthorization(ctx context.Context,
+	req *auth.LookupDeviceAuthorizationRequest) (*auth.LookupDeviceAuthorizationResponse, error) {
+	prompt, lookupErr := s.oauthSvc.LookupDeviceAuthorization(ctx, req.GetAccessToken(), req.GetUserCode())
+	if autherrors.Is(lookupErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, lookupErr)
+	}
+	if lookupErr != nil {
+		return nil, oauthStatusError(lookupErr)
+	}
+
+	return &auth.LookupDeviceAuthorizationResponse{
+		ClientName: prompt.ClientName,
+		Scope:      prompt.Scope,
+	}, nil
+}
+
+func (s *OAuthServer) ResolveDeviceAuthorization(ctx context.Context,
+	req *auth.ResolveDeviceAuthorizationRequest) (*emptypb.Empty, error) {
+	resolveErr := s.oauthSvc.ResolveDeviceAuthorization(ctx, req.GetAccessToken(), req.GetUserCode(), req.GetApproved())
+	if autherrors.Is(resolveErr, autherrors.TooManyAttempts) {
+		return nil, retryableStatusError(ctx, codes.ResourceExhausted, resolveErr)
+	}
+	if resolveErr != nil {
+		return nil, oauthStatusError(resolveErr)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *OAuthServer) UserInfo(ctx context.Context, req *auth.UserInfoRequest) (*auth.UserInfoResponse, error) {
+	accessToken := req.GetAccessToken()
+	if accessToken == "" {
+		accessToken = bearerToken(ctx)
+	}
+
+	info, userInfoErr := s.oauthSvc.UserInfo(ctx, accessToken)
+	if autherrors.OneOf(userInfoErr, autherrors.InvalidToken, autherrors.UserNotExists) {
+		return nil, status.Error(codes.Unauthenticated, userInfoErr.Error())
+	}
+	if autherrors.Is(userInfoErr, autherrors.InsufficientScope) {
+		return nil, status.Error(codes.PermissionDenied, userInfoErr.Error())
+	}
+	if userInfoErr != nil {
+		return nil, internalStatusError(userInfoErr)
+	}
+
+	return &auth.UserInfoResponse{
+		Sub:           info.Subject,
+		Email:         info.Email,
+		EmailVerified: info.EmailVerified,
+	}, nil
+}
+
+func (s *OAuthServer) GetOpenIDConfiguration(ctx context.Context, _ *emptypb.Empty) (*auth.OpenIDConfiguration, error) {
+	return convertOpenIDConfiguration(s.oauthSvc.OpenIDConfiguration(ctx)), nil
+}
+
+// bearerToken reads access token from authorization metadata, forwarded by gateway from header.
+func bearerToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		scheme, token, found := strings.Cut(value, " ")
+		if found && strings.EqualFold(scheme, bearerTokenType) {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	return ""
+}
+
+// oauthStatusError attaches ErrorInfo with OAuth 2.0 error code, so http proxy can answer
+// in protocol format. Errors of authorization request found before redirect URI was checked
+// aren't redirectable: they must be shown to user instead of being sent to unverified URI.
+func oauthStatusError(err error) error {
+	code, oauthError, redirectable := codes.Internal, serverOAuthError, false
+	switch {
+	case autherrors.Is(err, autherrors.OAuthClientNotExists):
+		code, oauthError = codes.NotFound, invalidClientError
+	case autherrors.Is(err, autherrors.InvalidRedirectURI):
+		code, oauthError = codes.InvalidArgument, invalidRequestError
+	case autherrors.Is(err, autherrors.UnsupportedResponseType):
+		code, oauthError, redirectable = codes.InvalidArgument, unsupportedTypeError, true
+	case autherrors.Is(err, autherrors.InvalidCodeChallenge):
+		code, oauthError, redirectable = codes.InvalidArgument, invalidRequestError, true
+	case autherrors.Is(err, autherrors.InvalidNonce):
+		code, oauthError, redirectable = codes.InvalidArgument, invalidRequestError, true
+	case autherrors.Is(err, autherrors.InvalidScope):
+		code, oauthError, redirectable = codes.InvalidArgument, invalidScopeError, true
+	case autherrors.Is(err, autherrors.InvalidClientCredentials):
+		code, oauthError = codes.Unauthenticated, invalidClientError
+	case autherrors.Is(err, autherrors.UnsupportedGrantType):
+		code, oauthError = codes.InvalidArgument, unsupportedGrantError
+	case autherrors.Is(err, autherrors.UnauthorizedClient):
+		code, oauthError = codes.PermissionDenied, unauthorizedClient
+	case autherrors.Is(err, autherrors.InvalidUserCode):
+		code, oauthError = codes.NotFound, invalidRequestError
+	case autherrors.Is(err, autherrors.AuthorizationPending):
+		code, oauthError = codes.FailedPrecondition, authorizationPending
+	case autherrors.Is(err, autherrors.SlowDown):
+		code, oauthError = codes.ResourceExhausted, slowDownError
+	case autherrors.Is(err, autherrors.ExpiredDeviceCode):
+		code, oauthError = codes.PermissionDenied, expiredTokenError
+	case autherrors.Is(err, autherrors.DeviceAccessDenied):
+		code, oauthError = codes.PermissionDenied, accessDeniedError
+	case autherrors.OneOf(err, autherrors.InvalidGrant, autherrors.InvalidToken, autherrors.InvalidSession,
+		autherrors.SessionNotExists, autherrors.RefreshTokenReused, autherrors.UserNotExists,
+		autherrors.UserPending, autherrors.UserSuspended, autherrors.UserDeleted):
+		code, oauthError = codes.PermissionDenied, invalidGrantError
+	case autherrors.Is(err, autherrors.AccessDenied):
+		code, oauthError = codes.PermissionDenied, invalidRequestError
+	}
+
+	st := status.New(code, err.Error())
+	detailed, detailsErr := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   oauthError,
+		Domain:   oauthErrorDomain,
+		Metadata: map[string]string{redirectableErrorKey: strconv.FormatBool(redirectable)},
+	})
+	if detailsErr != nil {
+		return st.Err()
+	}
+
+	return detailed.Err()
+}
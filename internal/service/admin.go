@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	defaultUsersPageSize = 50
+	maxUsersPageSize     = 200
+)
+
+const (
+	staticAdminActor    = "static_admin_key"
+	anonymousAdminActor = "anonymous"
+)
+
+func (s *AuthService) ListUsers(ctx context.Context, credential string, filter entity.UserFilter) (entity.UserPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUsersPageSize
+	}
+	if filter.Limit > maxUsersPageSize {
+		filter.Limit = maxUsersPageSize
+	}
+
+	var page entity.UserPage
+	details := fmt.Sprintf("users after %d matching %q", filter.AfterID, filter.EmailQuery)
+	actionErr := s.runAdminAction(ctx, credential, entity.ManageUsersPermission, listUsersMethod, 0, details, func() error {
+		pageFilter := filter
+		pageFilter.Limit++
+		users, searchUsersErr := s.repo.SearchUsers(ctx, pageFilter)
+		if searchUsersErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), listUsersMethod, searchUsersErr)
+			return fmt.Errorf("can't search users: %w", searchUsersErr)
+		}
+
+		if len(users) > filter.Limit {
+			users = users[:filter.Limit]
+			page.NextAfterID = users[len(users)-1].ID
+		}
+		page.Users = users
+
+		return nil
+	})
+
+	return page, actionErr
+}
+
+func (s *AuthService) GetUser(ctx context.Context, credential string,
+	userID int64) (entity.AuthUser, []entity.Session, error) {
+	var user entity.AuthUser
+	var sessions []entity.Session
+	actionErr := s.runAdminAction(ctx, credential, entity.ManageUsersPermission, getUserMethod, userID, "", func() error {
+		var getUserErr error
+		user, getUserErr = s.getUserByID(ctx, userID, getUserMethod)
+		if getUserErr != nil {
+			return getUserErr
+		}
+
+		var getSessionsErr error
+		sessions, getSessionsErr = s.repo.GetActiveSessionsByUserID(ctx, userID)
+		if getSessionsErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), getUserMethod, getSessionsErr)
+			return fmt.Errorf("can't get user's sessions: %w", getSessionsErr)
+		}
+
+		return nil
+	})
+
+	return user, sessions, actionErr
+}
+
+// SetUserStatus moves user through status state machine. Leaving active status
+// revokes sessions at once.
+func (s *AuthService) SetUserStatus(ctx context.Context, credential string, userID int64,
+	status entity.UserStatus) error {
+	return s.runAdminAction(ctx, credential, entity.ManageUsersPermission, setUserStatusMethod,
+		userID, "status "+string(status), func() error {
+			return s.changeUserStatus(ctx, userID, status, setUserStatusMethod)
+		})
+}
+
+// ForcePasswordReset makes current password unusable, revokes sessions
+// and mails user a link to set new password.
+func (s *AuthService) ForcePasswordReset(ctx context.Context, credential string, userID int64) error {
+	return s.runAdminAction(ctx, credential, entity.ManageUsersPermission, forcePasswordResetMethod, userID, "", func() error {
+		user, getUserErr := s.getUserByID(ctx, userID, forcePasswordResetMethod)
+		if getUserErr != nil {
+			return getUserErr
+		}
+
+		unusablePassword, generatePasswordErr := securetoken.Generate(securetoken.DefaultSize)
+		if generatePasswordErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), forcePasswordResetMethod, generatePasswordErr)
+			return fmt.Errorf("can't generate password: %w", generatePasswordErr)
+		}
+		hashedPassword, hashPwErr := s.hasher.Hash(unusablePassword)
+		if hashPwErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), forcePasswordResetMethod, hashPwErr)
+			return fmt.Errorf("can't create password hash: %w", hashPwErr)
+		}
+		if updatePwErr := s.repo.UpdateUserPassword(ctx, user.ID, hashedPassword); updatePwErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), forcePasswordResetMethod, updatePwErr)
+			return fmt.Errorf("can't update password: %w", updatePwErr)
+		}
+
+		if revokeErr := s.revokeUserSessions(ctx, user.ID, forcePasswordResetMethod); revokeErr != nil {
+			return revokeErr
+		}
+
+		resetToken, issueTokenErr := s.issueOneTimeToken(ctx, user.ID, entity.PasswordResetPurpose, s.cfg.PasswordReset.TokenTTL)
+		if issueTokenErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), forcePasswordResetMethod, issueTokenErr)
+			return fmt.Errorf("can't issue reset token: %w", issueTokenErr)
+		}
+
+		s.sendMail(ctx, forcePasswordResetMethod, passwordResetMail(user.Email,
+			linkWithToken(s.cfg.PasswordReset.URL, resetToken), s.cfg.PasswordReset.TokenTTL))
+
+		return nil
+	})
+}
+
+// DeleteUser marks user deleted. The account stays in database, so its email can't be taken over.
+func (s *AuthService) DeleteUser(ctx context.Context, credential string, userID int64) error {
+	return s.runAdminAction(ctx, credential, entity.ManageUsersPermission, deleteUserMethod, userID, "", func() error {
+		return s.changeUserStatus(ctx, userID, entity.UserDeleted, deleteUserMethod)
+	})
+}
+
+func (s *AuthService) RevokeUserSessions(ctx context.Context, credential string, userID int64) error {
+	return s.runAdminAction(ctx, credential, entity.ManageUsersPermission, revokeUserSessionsMethod, userID, "", func() error {
+		if _, getUserErr := s.getUserByID(ctx, userID, revokeUserSessionsMethod); getUserErr != nil {
+			return getUserErr
+		}
+
+		return s.revokeUserSessions(ctx, userID, revokeUserSessionsMethod)
+	})
+}
+
+// runAdminAction checks admin credential, performs action and writes the outcome to audit log,
+// whether it succeeded or not.
+func (s *AuthService) runAdminAction(ctx context.Context, credential string, permission string, method string,
+	subjectUserID int64, details string, action func() error) error {
+	actor, authErr := s.authorizeAdmin(ctx, credential, permission, method)
+	if authErr != nil {
+		s.recordAudit(ctx, method, actor, subjectUserID, details, authErr)
+		return authErr
+	}
+
+	actionErr := action()
+	s.recordAudit(ctx, method, actor, subjectUserID, details, actionErr)
+
+	return actionErr
+}
+
+func (s *AuthService) authorizeAdmin(ctx context.Context, credential string, permission string,
+	method string) (string, error) {
+	if s.cfg.Admin.StaticKey != "" &&
+		subtle.ConstantTimeCompare([]byte(credential), []byte(s.cfg.Admin.StaticKey)) == 1 {
+		return staticAdminActor, nil
+	}
+
+	admin, authErr := s.authorize(ctx, credential, permission, method)
+	if authErr != nil {
+		return anonymousAdminActor, authErr
+	}
+
+	return admin.Email, nil
+}
+
+func (s *AuthService) getUserByID(ctx context.Context, userID int64, method string) (entity.AuthUser, error) {
+	user, getUserErr := s.repo.GetUserByID(ctx, userID)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.UserNotExists)
+		return entity.AuthUser{}, autherrors.NewStatusError(autherrors.UserNotExists, nil)
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getUserErr)
+		return entity.AuthUser{}, fmt.Errorf("can't get user: %w", getUserErr)
+	}
+
+	return user, nil
+}
+
+func (s *AuthService) changeUserStatus(ctx context.Context, userID int64, status entity.UserStatus, method string) error {
+	if !status.Valid() {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidUserStatus)
+		return autherrors.NewStatusError(autherrors.InvalidUserStatus, fmt.Errorf("unknown status %q", status))
+	}
+
+	user, getUserErr := s.getUserByID(ctx, userID, method)
+	if getUserErr != nil {
+		return getUserErr
+	}
+	if user.Status == status {
+		return nil
+	}
+	if !user.Status.CanTransitionTo(status) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidStatusTransition)
+		return autherrors.NewStatusError(autherrors.InvalidStatusTransition,
+			fmt.Errorf("%s -> %s", user.Status, status))
+	}
+
+	updateStatusErr := s.repo.UpdateUserStatus(ctx, userID, user.Status, status, time.Now())
+	if errors.Is(updateStatusErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidStatusTransition)
+		return autherrors.NewStatusError(autherrors.InvalidStatusTransition, errors.New("status was changed concurrently"))
+	}
+	if updateStatusErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, updateStatusErr)
+		return fmt.Errorf("can't update user's status: %w", updateStatusErr)
+	}
+
+	if status != entity.UserActive {
+		return s.revokeUserSessions(ctx, userID, method)
+	}
+
+	return nil
+}
+
+// revokeUserSessions deletes all sessions of user and forgets them in validation cache,
+// so strict Validate rejects their access tokens at once.
+func (s *AuthService) revokeUserSessions(ctx context.Context, userID int64, method string) error {
+	sessions, getSessionsErr := s.repo.GetActiveSessionsByUserID(ctx, userID)
+	if getSessionsErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getSessionsErr)
+		return fmt.Errorf("can't get user's sessions: %w", getSessionsErr)
+	}
+
+	if deleteSessionsErr := s.repo.DeleteSessionsByUserID(ctx, userID); deleteSessionsErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, deleteSessionsErr)
+		return fmt.Errorf("can't delete user's sessions: %w", deleteSessionsErr)
+	}
+	for _, session := range sessions {
+		s.sessionCache.Delete(session.FamilyID)
+	}
+
+	return nil
+}
+
+// revokeOtherUserSessions is revokeUserSessions that keeps session family the user acts from.
+func (s *AuthService) revokeOtherUserSessions(ctx context.Context, userID int64, familyID uuid.UUID,
+	method string) error {
+	sessions, getSessionsErr := s.repo.GetActiveSessionsByUserID(ctx, userID)
+	if getSessionsErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getSessionsErr)
+		return fmt.Errorf("can't get user's sessions: %w", getSessionsErr)
+	}
+
+	if deleteSessionsErr := s.repo.DeleteSessionsByUserIDExceptFamily(ctx, userID, familyID); deleteSessionsErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, deleteSessionsErr)
+		return fmt.Errorf("can't delete other sessions: %w", deleteSessionsErr)
+	}
+	for _, session := range sessions {
+		if session.FamilyID != familyID {
+			s.sessionCache.Delete(session.FamilyID)
+		}
+	}
+
+	return nil
+}
+
+// checkUserStatus lets only active user get or use tokens.
+func (s *AuthService) checkUserStatus(ctx context.Context, user entity.AuthUser, method string) error {
+	var status autherrors.Status
+	switch user.Status {
+	case entity.UserActive:
+		return nil
+	case entity.UserPending:
+		status = autherrors.UserPending
+	case entity.UserSuspended:
+		status = autherrors.UserSuspended
+	default:
+		status = autherrors.UserDeleted
+	}
+
+	s.logger.Printf(logPattern, requestUUID(ctx), method, status)
+	return autherrors.NewStatusError(status, nil)
+}
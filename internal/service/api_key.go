@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxAPIKeyNameLength = 128
+
+// apiKeyTouchInterval is how precise last use time of API key is.
+const apiKeyTouchInterval = time.Minute
+
+// CreateAPIKey issues API key with scope out of user's own permissions and returns it with the key.
+// The key can't be seen again.
+func (s *AuthService) CreateAPIKey(ctx context.Context, accessToken string,
+	key entity.APIKey) (entity.APIKey, string, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, createAPIKeyMethod)
+	if authErr != nil {
+		return entity.APIKey{}, "", authErr
+	}
+
+	key.Name = strings.TrimSpace(key.Name)
+	now := time.Now()
+	if key.Name == "" || utf8.RuneCountInString(key.Name) > maxAPIKeyNameLength || key.IsExpired(now) {
+		s.logger.Printf(logPattern, requestUUID(ctx), createAPIKeyMethod, autherrors.InvalidAPIKey)
+		return entity.APIKey{}, "", autherrors.NewStatusError(autherrors.InvalidAPIKey, nil)
+	}
+	scope, normalizeErr := normalizePermissions(strings.Fields(key.Scope))
+	if normalizeErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), createAPIKeyMethod, autherrors.InvalidAPIKey)
+		return entity.APIKey{}, "", autherrors.NewStatusError(autherrors.InvalidAPIKey, normalizeErr)
+	}
+	key.Scope = strings.Join(scope, " ")
+
+	access, getAccessErr := s.repo.GetUserAccess(ctx, user.ID)
+	if getAccessErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), createAPIKeyMethod, getAccessErr)
+		return entity.APIKey{}, "", fmt.Errorf("can't get user's roles: %w", getAccessErr)
+	}
+	if !scopeCovers(strings.Join(access.Permissions, " "), key.Scope) {
+		s.logger.Printf(logPattern, requestUUID(ctx), createAPIKeyMethod, autherrors.InvalidAPIKey)
+		return entity.APIKey{}, "", autherrors.NewStatusError(autherrors.InvalidAPIKey,
+			errors.New("scope exceeds user's permissions"))
+	}
+
+	secret, generateKeyErr := securetoken.Generate(securetoken.DefaultSize)
+	if generateKeyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), createAPIKeyMethod, generateKeyErr)
+		return entity.APIKey{}, "", fmt.Errorf("can't generate api key: %w", generateKeyErr)
+	}
+	plainKey := entity.APIKeyPrefix + secret
+
+	key.ID = uuid.New()
+	key.UserID = user.ID
+	key.KeyHash = securetoken.Hash(plainKey)
+	key.CreatedAt = now
+	key.LastUsedAt = nil
+	if createKeyErr := s.repo.CreateAPIKey(ctx, key); createKeyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), createAPIKeyMethod, createKeyErr)
+		return entity.APIKey{}, "", fmt.Errorf("can't create api key: %w", createKeyErr)
+	}
+	s.recordAudit(ctx, entity.AuditAPIKeyCreate, user.Email, user.ID, "api key "+key.ID.String()+" scope "+key.Scope, nil)
+
+	return key, plainKey, nil
+}
+
+func (s *AuthService) ListAPIKeys(ctx context.Context, accessToken string) ([]entity.APIKey, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, listAPIKeysMethod)
+	if authErr != nil {
+		return nil, authErr
+	}
+
+	keys, getKeysErr := s.repo.GetUserAPIKeys(ctx, user.ID)
+	if getKeysErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), listAPIKeysMethod, getKeysErr)
+		return nil, fmt.Errorf("can't get user's api keys: %w", getKeysErr)
+	}
+
+	return keys, nil
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, accessToken string, keyID string) error {
+	keyUUID, parseKeyIDErr := uuid.Parse(keyID)
+	if parseKeyIDErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), revokeAPIKeyMethod, autherrors.APIKeyNotExists)
+		return autherrors.NewStatusError(autherrors.APIKeyNotExists, nil)
+	}
+
+	user, _, authErr := s.authenticate(ctx, accessToken, revokeAPIKeyMethod)
+	if authErr != nil {
+		return authErr
+	}
+
+	deleteKeyErr := s.repo.DeleteUserAPIKey(ctx, user.ID, keyUUID)
+	if errors.Is(deleteKeyErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), revokeAPIKeyMethod, autherrors.APIKeyNotExists)
+		return autherrors.NewStatusError(autherrors.APIKeyNotExists, nil)
+	}
+	if deleteKeyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), revokeAPIKeyMethod, deleteKeyErr)
+		return fmt.Errorf("can't delete api key: %w", deleteKeyErr)
+	}
+	s.recordAudit(ctx, entity.AuditAPIKeyRevoke, user.Email, user.ID, "api key "+keyID, nil)
+
+	return nil
+}
+
+// validateAPIKey is Validate for API key. Keys are always checked in database, as they are
+// revoked one by one, and carry permissions of the key scope the user still has.
+func (s *AuthService) validateAPIKey(ctx context.Context, plainKey string) (entity.AccessClaims, error) {
+	key, getKeyErr := s.repo.GetAPIKeyByHash(ctx, securetoken.Hash(plainKey))
+	if errors.Is(getKeyErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), validateMethod, autherrors.InvalidToken)
+		return entity.AccessClaims{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("api key doesn't exist"))
+	}
+	if getKeyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), validateMethod, getKeyErr)
+		return entity.AccessClaims{}, fmt.Errorf("can't get api key: %w", getKeyErr)
+	}
+
+	now := time.Now()
+	if key.IsExpired(now) {
+		s.logger.Printf(logPattern, requestUUID(ctx), validateMethod, autherrors.InvalidToken)
+		return entity.AccessClaims{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("api key has expired"))
+	}
+
+	user, getUserErr := s.getUserByID(ctx, key.UserID, validateMethod)
+	if getUserErr != nil {
+		return entity.AccessClaims{}, getUserErr
+	}
+	if checkStatusErr := s.checkUserStatus(ctx, user, validateMethod); checkStatusErr != nil {
+		return entity.AccessClaims{}, checkStatusErr
+	}
+
+	access, getAccessErr := s.repo.GetUserAccess(ctx, user.ID)
+	if getAccessErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), validateMethod, getAccessErr)
+		return entity.AccessClaims{}, fmt.Errorf("can't get user's roles: %w", getAccessErr)
+	}
+
+	if touchKeyErr := s.repo.TouchAPIKey(ctx, key.ID, now, now.Add(-apiKeyTouchInterval)); touchKeyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), validateMethod, touchKeyErr)
+	}
+
+	claims := entity.AccessClaims{
+		TokenID:       key.ID,
+		Principal:     entity.UserPrincipal,
+		APIKey:        true,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Permissions:   scopedAccess(access, key.Scope).Permissions,
+	}
+	if key.ExpiresAt != nil {
+		claims.ExpiresAt = *key.ExpiresAt
+	}
+
+	return claims, nil
+}
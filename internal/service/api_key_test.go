@@ -0,0 +1,86 @@
+package service_test
+
+import (
+	"context"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyValidation(t *testing.T) {
+	ctx := context.Background()
+	user := entity.AuthUser{ID: 7, Email: "ci@example.com", Status: entity.UserActive}
+	repo := newMemoryRepository(user)
+	repo.accesses[user.ID] = entity.UserAccess{Permissions: []string{"builds:read", "builds:write", "deploy:run"}}
+	svc, tokenGenerator := newTestService(t, repo, tokengen.NewHMACSigningKey("", "access-secret"), nil, service.Config{})
+	accessToken := loginAs(t, tokenGenerator, repo, user)
+
+	if _, _, createErr := svc.CreateAPIKey(ctx, accessToken, entity.APIKey{Name: "ci", Scope: "admin:all"}); !autherrors.Is(createErr, autherrors.InvalidAPIKey) {
+		t.Fatalf("key with scope beyond user's permissions is created: %v", createErr)
+	}
+
+	key, plainKey, createErr := svc.CreateAPIKey(ctx, accessToken, entity.APIKey{Name: " ci ", Scope: "builds:write builds:read"})
+	if createErr != nil {
+		t.Fatalf("CreateAPIKey: %v", createErr)
+	}
+	if !strings.HasPrefix(plainKey, entity.APIKeyPrefix) || strings.Contains(repo.apiKeys[key.ID].KeyHash, plainKey) {
+		t.Fatalf("unexpected key %q stored as %q", plainKey, repo.apiKeys[key.ID].KeyHash)
+	}
+
+	claims, validateErr := svc.Validate(ctx, plainKey)
+	if validateErr != nil {
+		t.Fatalf("Validate: %v", validateErr)
+	}
+	if !claims.APIKey || claims.TokenID != key.ID || claims.Email != user.Email ||
+		strings.Join(claims.Permissions, " ") != "builds:read builds:write" {
+		t.Fatalf("unexpected claims of api key: %+v", claims)
+	}
+	if repo.apiKeys[key.ID].LastUsedAt == nil {
+		t.Fatalf("use of api key isn't recorded")
+	}
+
+	// Key is found by prefix: without it the secret is parsed as JWT, with wrong secret it's unknown.
+	for _, invalidKey := range []string{strings.TrimPrefix(plainKey, entity.APIKeyPrefix), plainKey + "x", entity.APIKeyPrefix} {
+		if _, validateErr := svc.Validate(ctx, invalidKey); !autherrors.Is(validateErr, autherrors.InvalidToken) {
+			t.Fatalf("key %q is accepted: %v", invalidKey, validateErr)
+		}
+	}
+
+	// Key carries only permissions user still has.
+	repo.accesses[user.ID] = entity.UserAccess{Permissions: []string{"builds:read"}}
+	if claims, _ := svc.Validate(ctx, plainKey); strings.Join(claims.Permissions, " ") != "builds:read" {
+		t.Fatalf("key keeps permissions user has lost: %v", claims.Permissions)
+	}
+
+	if revokeErr := svc.RevokeAPIKey(ctx, accessToken, key.ID.String()); revokeErr != nil {
+		t.Fatalf("RevokeAPIKey: %v", revokeErr)
+	}
+	if _, validateErr := svc.Validate(ctx, plainKey); !autherrors.Is(validateErr, autherrors.InvalidToken) {
+		t.Fatalf("revoked key is accepted: %v", validateErr)
+	}
+}
+
+func TestExpiredAPIKeyIsRejected(t *testing.T) {
+	ctx := context.Background()
+	user := entity.AuthUser{ID: 7, Email: "ci@example.com", Status: entity.UserActive}
+	repo := newMemoryRepository(user)
+	svc, tokenGenerator := newTestService(t, repo, tokengen.NewHMACSigningKey("", "access-secret"), nil, service.Config{})
+	accessToken := loginAs(t, tokenGenerator, repo, user)
+
+	expiresAt := time.Now().Add(time.Hour)
+	key, plainKey, createErr := svc.CreateAPIKey(ctx, accessToken, entity.APIKey{Name: "ci", ExpiresAt: &expiresAt})
+	if createErr != nil {
+		t.Fatalf("CreateAPIKey: %v", createErr)
+	}
+
+	expiredAt := time.Now().Add(-time.Second)
+	key.ExpiresAt = &expiredAt
+	repo.apiKeys[key.ID] = key
+	if _, validateErr := svc.Validate(ctx, plainKey); !autherrors.Is(validateErr, autherrors.InvalidToken) {
+		t.Fatalf("expired key is accepted: %v", validateErr)
+	}
+}
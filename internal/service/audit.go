@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+	auditVerifyBatchSize = 1000
+)
+
+// ListAuditEvents returns events ordered by id, optionally of one user and in time range [From, To).
+func (s *AuthService) ListAuditEvents(ctx context.Context, credential string,
+	filter entity.AuditFilter) (entity.AuditPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit > maxAuditPageSize {
+		filter.Limit = maxAuditPageSize
+	}
+	if filter.From != nil {
+		from := filter.From.UTC()
+		filter.From = &from
+	}
+	if filter.To != nil {
+		to := filter.To.UTC()
+		filter.To = &to
+	}
+
+	var page entity.AuditPage
+	actionErr := s.runAdminAction(ctx, credential, entity.ReadAuditPermission, listAuditEventsMethod,
+		filter.SubjectUserID, fmt.Sprintf("events after %d", filter.AfterID), func() error {
+			pageFilter := filter
+			pageFilter.Limit++
+			events, getEventsErr := s.repo.GetAuditEvents(ctx, pageFilter)
+			if getEventsErr != nil {
+				s.logger.Warnf(logPattern, requestUUID(ctx), listAuditEventsMethod, getEventsErr)
+				return fmt.Errorf("can't get audit events: %w", getEventsErr)
+			}
+
+			if len(events) > filter.Limit {
+				events = events[:filter.Limit]
+				page.NextAfterID = events[len(events)-1].ID
+			}
+			page.Events = events
+
+			return nil
+		})
+
+	return page, actionErr
+}
+
+// VerifyAuditLog walks the whole hash chain and reports the first event
+// that was changed, or follows removed or reordered one.
+func (s *AuthService) VerifyAuditLog(ctx context.Context, credential string) (entity.AuditVerification, error) {
+	var verification entity.AuditVerification
+	actionErr := s.runAdminAction(ctx, credential, entity.ReadAuditPermission, verifyAuditLogMethod, 0, "", func() error {
+		prevHash := entity.AuditGenesisHash
+		filter := entity.AuditFilter{Limit: auditVerifyBatchSize}
+		for {
+			events, getEventsErr := s.repo.GetAuditEvents(ctx, filter)
+			if getEventsErr != nil {
+				s.logger.Warnf(logPattern, requestUUID(ctx), verifyAuditLogMethod, getEventsErr)
+				return fmt.Errorf("can't get audit events: %w", getEventsErr)
+			}
+
+			for _, event := range events {
+				if event.PrevHash != prevHash || event.ChainHash() != event.Hash {
+					verification.BrokenEventID = event.ID
+					return nil
+				}
+				prevHash = event.Hash
+				verification.CheckedEvents++
+			}
+
+			if len(events) < filter.Limit {
+				verification.Valid = true
+				return nil
+			}
+			filter.AfterID = events[len(events)-1].ID
+		}
+	})
+
+	return verification, actionErr
+}
+
+// recordAudit appends event to audit log. Failure to write it doesn't fail the action
+// that is audited, but is logged.
+func (s *AuthService) recordAudit(ctx context.Context, eventType string, actor string,
+	subjectUserID int64, details string, outcome error) {
+	event := entity.AuditEvent{
+		Type:          eventType,
+		Actor:         actor,
+		SubjectUserID: subjectUserID,
+		Details:       details,
+		IPAddress:     clientIP(ctx),
+		UserAgent:     userAgent(ctx),
+		RequestID:     requestUUID(ctx).String(),
+		Outcome:       entity.AuditSuccess,
+		CreatedAt:     time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if outcome != nil {
+		event.Outcome = outcome.Error()
+	}
+
+	if _, appendErr := s.repo.AppendAuditEvent(ctx, event); appendErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), eventType, fmt.Errorf("can't record audit event: %w", appendErr))
+	}
+}
+
+// userActor names user by id when their email isn't at hand.
+func userActor(userID int64) string {
+	return "user " + strconv.FormatInt(userID, 10)
+}
+
+func clientActor(clientID string) string {
+	return "client " + clientID
+}
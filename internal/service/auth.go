@@ -6,19 +6,71 @@ import (
 	"fmt"
 	"github.com/DmitySH/go-auth-service/internal/autherrors"
 	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/ttlcache"
 	"github.com/google/uuid"
 	"net/mail"
+	"strings"
+	"sync"
 	"time"
 	"unicode"
 )
 
 const logPattern = "Request UUID: %s | Method: %s | Error: %v"
 
+const registrationTimeout = time.Minute
+
 const (
-	registerMethod = "register"
-	loginMethod    = "login"
-	validateMethod = "validate"
-	refreshMethod  = "refresh"
+	registerMethod                   = "register"
+	loginMethod                      = "login"
+	validateMethod                   = "validate"
+	refreshMethod                    = "refresh"
+	logoutMethod                     = "logout"
+	logoutAllMethod                  = "logout_all"
+	listSessionsMethod               = "list_sessions"
+	revokeSessionMethod              = "revoke_session"
+	changePasswordMethod             = "change_password"
+	requestPasswordResetMethod       = "request_password_reset"
+	confirmPasswordResetMethod       = "confirm_password_reset"
+	verifyEmailMethod                = "verify_email"
+	resendVerificationMethod         = "resend_verification"
+	enrollTOTPMethod                 = "enroll_totp"
+	confirmTOTPMethod                = "confirm_totp"
+	verifyMFAMethod                  = "verify_mfa"
+	beginPasskeyRegistrationMethod   = "begin_passkey_registration"
+	finishPasskeyRegistrationMethod  = "finish_passkey_registration"
+	beginPasskeyLoginMethod          = "begin_passkey_login"
+	finishPasskeyLoginMethod         = "finish_passkey_login"
+	startEmailLoginMethod            = "start_email_login"
+	completeEmailLoginMethod         = "complete_email_login"
+	saveRoleMethod                   = "save_role"
+	listRolesMethod                  = "list_roles"
+	assignRoleMethod                 = "assign_role"
+	revokeRoleMethod                 = "revoke_role"
+	createOrganizationMethod         = "create_organization"
+	listOrganizationsMethod          = "list_organizations"
+	inviteMemberMethod               = "invite_member"
+	acceptInvitationMethod           = "accept_invitation"
+	switchOrganizationMethod         = "switch_organization"
+	listUsersMethod                  = "list_users"
+	getUserMethod                    = "get_user"
+	setUserStatusMethod              = "set_user_status"
+	forcePasswordResetMethod         = "force_password_reset"
+	deleteUserMethod                 = "delete_user"
+	revokeUserSessionsMethod         = "revoke_user_sessions"
+	listAuditEventsMethod            = "list_audit_events"
+	verifyAuditLogMethod             = "verify_audit_log"
+	createOAuthClientMethod          = "create_oauth_client"
+	deleteOAuthClientMethod          = "delete_oauth_client"
+	validateAuthorizationMethod      = "validate_authorization"
+	authorizeMethod                  = "authorize"
+	exchangeOAuthTokenMethod         = "exchange_oauth_token"
+	userInfoMethod                   = "user_info"
+	createAPIKeyMethod               = "create_api_key"
+	listAPIKeysMethod                = "list_api_keys"
+	revokeAPIKeyMethod               = "revoke_api_key"
+	startDeviceAuthorizationMethod   = "start_device_authorization"
+	lookupDeviceAuthorizationMethod  = "lookup_device_authorization"
+	resolveDeviceAuthorizationMethod = "resolve_device_authorization"
 )
 
 const (
@@ -29,36 +81,36 @@ const (
 )
 
 type AuthService struct {
-	logger               Logger
-	repo                 AuthRepository
-	hasher               Hasher
-	tokenGenerator       TokenGenerator
-	sessionClearInterval time.Duration
+	logger         Logger
+	repo           AuthRepository
+	hasher         Hasher
+	tokenGenerator TokenGenerator
+	mailer         Mailer
+	passkeys       PasskeyProvider
+	cfg            Config
+	sessionCache   *ttlcache.Cache[uuid.UUID, bool]
+	clientCache    *ttlcache.Cache[string, bool]
+
+	dummyHashMu sync.Mutex
+	dummyHash   string
 }
 
 func NewAuthService(logger Logger, repo AuthRepository,
-	hasher Hasher, tokenGenerator TokenGenerator, sessionClearInterval time.Duration) *AuthService {
+	hasher Hasher, tokenGenerator TokenGenerator, mailer Mailer, passkeys PasskeyProvider, cfg Config) *AuthService {
 	return &AuthService{
-		logger:               logger,
-		repo:                 repo,
-		hasher:               hasher,
-		tokenGenerator:       tokenGenerator,
-		sessionClearInterval: sessionClearInterval,
+		logger:         logger,
+		repo:           repo,
+		hasher:         hasher,
+		tokenGenerator: tokenGenerator,
+		mailer:         mailer,
+		passkeys:       passkeys,
+		cfg:            cfg,
+		sessionCache:   ttlcache.New[uuid.UUID, bool](cfg.Validation.CacheTTL),
+		clientCache:    ttlcache.New[string, bool](cfg.Validation.CacheTTL),
 	}
 }
 
 func (s *AuthService) Register(ctx context.Context, user entity.AuthUser) error {
-	_, getUserErr := s.repo.GetUserByEmail(ctx, user.Email)
-
-	if getUserErr == nil {
-		s.logger.Printf(logPattern, requestUUID(ctx), registerMethod, autherrors.UserExists)
-		return autherrors.NewStatusError(autherrors.UserExists, nil)
-	}
-	if !errors.Is(getUserErr, ErrEntityNotFound) {
-		s.logger.Warnf(logPattern, requestUUID(ctx), registerMethod, getUserErr)
-		return fmt.Errorf("can't check if user exists: %w", getUserErr)
-	}
-
 	if validateEmailErr := validateEmail(user.Email); validateEmailErr != nil {
 		s.logger.Printf(logPattern, requestUUID(ctx), registerMethod, autherrors.InvalidEmail)
 		return autherrors.NewStatusError(autherrors.InvalidEmail, nil)
@@ -75,138 +127,512 @@ func (s *AuthService) Register(ctx context.Context, user entity.AuthUser) error
 		return fmt.Errorf("can't create password hash: %w", hashPwErr)
 	}
 	user.Password = hashedPassword
+	user.Status = entity.UserActive
+	if s.cfg.EmailVerification.Required {
+		user.Status = entity.UserPending
+	}
 
-	if createUserErr := s.repo.CreateUser(ctx, user); createUserErr != nil {
+	existingUser, getUserErr := s.repo.GetUserByEmail(ctx, user.Email)
+	if getUserErr != nil && !errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Warnf(logPattern, requestUUID(ctx), registerMethod, getUserErr)
+		return fmt.Errorf("can't check if user exists: %w", getUserErr)
+	}
+	userExists := getUserErr == nil
+
+	if s.cfg.EnumerationProtection {
+		// Creating account takes longer than refusing, so both finish in background
+		// and response time doesn't tell whether email is registered.
+		go func() {
+			registerCtx, cancel := context.WithTimeout(detachedContext{ctx}, registrationTimeout)
+			defer cancel()
+
+			if userExists {
+				s.refuseRegistration(registerCtx, user, existingUser)
+				s.sendMail(registerCtx, registerMethod, accountExistsMail(existingUser.Email))
+				return
+			}
+			_ = s.createAccount(registerCtx, user)
+		}()
+
+		return nil
+	}
+
+	if userExists {
+		s.refuseRegistration(ctx, user, existingUser)
+		return autherrors.NewStatusError(autherrors.UserExists, nil)
+	}
+
+	return s.createAccount(ctx, user)
+}
+
+func (s *AuthService) refuseRegistration(ctx context.Context, user entity.AuthUser, existingUser entity.AuthUser) {
+	s.logger.Printf(logPattern, requestUUID(ctx), registerMethod, autherrors.UserExists)
+	s.recordAudit(ctx, entity.AuditRegister, user.Email, existingUser.ID, "",
+		autherrors.NewStatusError(autherrors.UserExists, nil))
+}
+
+func (s *AuthService) createAccount(ctx context.Context, user entity.AuthUser) error {
+	userID, createUserErr := s.repo.CreateUser(ctx, user)
+	if createUserErr != nil {
 		s.logger.Warnf(logPattern, requestUUID(ctx), registerMethod, createUserErr)
 		return fmt.Errorf("can't create user: %w", createUserErr)
 	}
+	user.ID = userID
+	s.recordAudit(ctx, entity.AuditRegister, user.Email, user.ID, "", nil)
+
+	// Account already exists, so failing here would only make retry fail with UserExists.
+	// sendVerification has logged the error, and the user can ask for mail again with ResendVerification.
+	_ = s.sendVerification(ctx, user, registerMethod)
 
 	return nil
 }
 
-func (s *AuthService) Login(ctx context.Context, user entity.AuthUser, fingerprint string) (entity.TokenPair, error) {
+func (s *AuthService) Login(ctx context.Context, user entity.AuthUser, fingerprint string) (entity.LoginResult, error) {
 	fingerprintUUID, parseFingerprintErr := uuid.Parse(fingerprint)
 	if parseFingerprintErr != nil {
 		s.logger.Printf(logPattern, requestUUID(ctx), loginMethod, autherrors.InvalidFingerprint)
-		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidFingerprint, nil)
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.InvalidFingerprint, nil)
+	}
+
+	attempt, throttleErr := s.checkLoginThrottle(ctx, user.Email)
+	if throttleErr != nil {
+		return entity.LoginResult{}, throttleErr
 	}
 
 	existingUser, getUserErr := s.repo.GetUserByEmail(ctx, user.Email)
 	if errors.Is(getUserErr, ErrEntityNotFound) {
 		s.logger.Printf(logPattern, requestUUID(ctx), loginMethod, autherrors.UserNotExists)
-		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.UserNotExists, nil)
+		s.recordAudit(ctx, entity.AuditLoginFailure, user.Email, 0, loginMethod,
+			autherrors.NewStatusError(autherrors.UserNotExists, nil))
+		if s.cfg.EnumerationProtection {
+			dummyHash, dummyHashErr := s.dummyPasswordHash()
+			if dummyHashErr != nil {
+				s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, dummyHashErr)
+				return entity.LoginResult{}, fmt.Errorf("can't create dummy password hash: %w", dummyHashErr)
+			}
+			s.hasher.CompareHashes(user.Password, dummyHash)
+			return entity.LoginResult{}, autherrors.NewStatusError(autherrors.InvalidCredentials, nil)
+		}
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.UserNotExists, nil)
 	}
 	if getUserErr != nil {
 		s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, getUserErr)
-		return entity.TokenPair{}, fmt.Errorf("can't check if user exists: %w", getUserErr)
+		return entity.LoginResult{}, fmt.Errorf("can't check if user exists: %w", getUserErr)
 	}
 	if !s.hasher.CompareHashes(user.Password, existingUser.Password) {
 		s.logger.Printf(logPattern, requestUUID(ctx), loginMethod, autherrors.UserInvalidPassword)
-		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.UserInvalidPassword, nil)
+		s.recordAudit(ctx, entity.AuditLoginFailure, user.Email, existingUser.ID, loginMethod,
+			autherrors.NewStatusError(autherrors.UserInvalidPassword, nil))
+		if s.cfg.EnumerationProtection {
+			return entity.LoginResult{}, autherrors.NewStatusError(autherrors.InvalidCredentials, nil)
+		}
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.UserInvalidPassword, nil)
 	}
-
-	sessionUUID := uuid.New()
-
-	tokenPair, generateTokensErr := s.tokenGenerator.GenerateTokenPair(user.Email, sessionUUID)
-	if generateTokensErr != nil {
-		s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, generateTokensErr)
-		return entity.TokenPair{}, fmt.Errorf("can't generate token pair: %w", generateTokensErr)
+	s.passLoginAttempt(ctx, user.Email, attempt)
+	if s.cfg.EmailVerification.Required && !existingUser.EmailVerified {
+		s.logger.Printf(logPattern, requestUUID(ctx), loginMethod, autherrors.EmailNotVerified)
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.EmailNotVerified, nil)
 	}
 
-	session := entity.Session{
-		ID:          sessionUUID,
-		UserID:      existingUser.ID,
-		Fingerprint: fingerprintUUID,
-		ExpiresAt:   tokenPair.Refresh.ExpiresAt,
-	}
+	return s.completeFirstFactor(ctx, existingUser, fingerprintUUID, loginMethod)
+}
 
-	if createSessionErr := s.repo.CreateSession(ctx, session); createSessionErr != nil {
-		s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, createSessionErr)
-		return entity.TokenPair{}, fmt.Errorf("can't create user's session: %w", createSessionErr)
+func (s *AuthService) Validate(ctx context.Context, accessToken string) (entity.AccessClaims, error) {
+	if strings.HasPrefix(accessToken, entity.APIKeyPrefix) {
+		return s.validateAPIKey(ctx, accessToken)
 	}
 
-	return tokenPair, nil
-}
-
-func (s *AuthService) Validate(ctx context.Context, accessToken string) (string, error) {
-	userEmail, validateErr := s.tokenGenerator.ValidateAccessTokenAndGetEmail(accessToken)
+	claims, validateErr := s.tokenGenerator.ValidateAccessToken(accessToken)
 	if validateErr != nil {
 		s.logger.Printf(logPattern, requestUUID(ctx), validateMethod, autherrors.InvalidToken)
-		return "", autherrors.NewStatusError(autherrors.InvalidToken, validateErr)
+		return entity.AccessClaims{}, autherrors.NewStatusError(autherrors.InvalidToken, validateErr)
+	}
+
+	if s.cfg.Validation.Strict && claims.Principal == entity.ClientPrincipal {
+		active, checkClientErr := s.isClientActive(ctx, claims.ClientID)
+		if checkClientErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), validateMethod, checkClientErr)
+			return entity.AccessClaims{}, fmt.Errorf("can't check oauth client: %w", checkClientErr)
+		}
+		if !active {
+			s.logger.Printf(logPattern, requestUUID(ctx), validateMethod, autherrors.InvalidToken)
+			return entity.AccessClaims{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("client was deleted"))
+		}
+	}
+	if s.cfg.Validation.Strict && claims.Principal == entity.UserPrincipal {
+		active, checkSessionErr := s.isSessionActive(ctx, claims.SessionID)
+		if checkSessionErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), validateMethod, checkSessionErr)
+			return entity.AccessClaims{}, fmt.Errorf("can't check user's session: %w", checkSessionErr)
+		}
+		if !active {
+			if checkStatusErr := s.checkRevokedUserStatus(ctx, claims.Email); checkStatusErr != nil {
+				return entity.AccessClaims{}, checkStatusErr
+			}
+			s.logger.Printf(logPattern, requestUUID(ctx), validateMethod, autherrors.InvalidToken)
+			return entity.AccessClaims{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("session was revoked"))
+		}
 	}
 
-	return userEmail, nil
+	return claims, nil
 }
 
 func (s *AuthService) Refresh(ctx context.Context, refreshToken string, fingerprint string) (entity.TokenPair, error) {
+	return s.rotateSession(ctx, refreshToken, fingerprint, nil, "", refreshMethod)
+}
+
+// rotateSession exchanges refresh token for new token pair. Session keeps its organization
+// unless orgID is passed: then it moves there, or to personal context if orgID is 0.
+// clientID is OAuth client that presents refresh token, empty for first-party clients.
+func (s *AuthService) rotateSession(ctx context.Context, refreshToken string, fingerprint string,
+	orgID *int64, clientID string, method string) (entity.TokenPair, error) {
 	fingerprintUUID, parseFingerprintErr := uuid.Parse(fingerprint)
 	if parseFingerprintErr != nil {
-		s.logger.Printf(logPattern, requestUUID(ctx), refreshMethod, autherrors.InvalidFingerprint)
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidFingerprint)
 		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidFingerprint, nil)
 	}
 
 	currentSessionUUID, validateErr := s.tokenGenerator.ValidateRefreshTokenAndGetSessionUUID(refreshToken)
 	if validateErr != nil {
-		s.logger.Printf(logPattern, requestUUID(ctx), refreshMethod, autherrors.InvalidToken)
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidToken)
 		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidToken, validateErr)
 	}
 
 	currentSession, getSessionErr := s.repo.GetSessionByUUID(ctx, currentSessionUUID)
 	if errors.Is(getSessionErr, ErrEntityNotFound) {
-		s.logger.Printf(logPattern, requestUUID(ctx), refreshMethod, autherrors.SessionNotExists)
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.SessionNotExists)
 		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.SessionNotExists, nil)
 	}
 	if getSessionErr != nil {
-		s.logger.Warnf(logPattern, requestUUID(ctx), refreshMethod, getSessionErr)
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getSessionErr)
 		return entity.TokenPair{}, fmt.Errorf("can't get user's session: %w", getSessionErr)
 	}
 
-	if deleteSessionErr := s.repo.DeleteSessionByUUID(ctx, currentSessionUUID); deleteSessionErr != nil {
-		s.logger.Printf(logPattern, requestUUID(ctx), refreshMethod, deleteSessionErr)
-		return entity.TokenPair{}, fmt.Errorf("can't delete session: %w", deleteSessionErr)
+	if currentSession.RotatedAt != nil {
+		return entity.TokenPair{}, s.revokeReusedFamily(ctx, currentSession)
+	}
+
+	newOrgID := currentSession.OrgID
+	if orgID != nil {
+		newOrgID = orgID
+		if *orgID == 0 {
+			newOrgID = nil
+		}
+	}
+	if newOrgID != nil {
+		if checkMemberErr := s.checkMembership(ctx, *newOrgID, currentSession.UserID, method); checkMemberErr != nil {
+			return entity.TokenPair{}, checkMemberErr
+		}
+	}
+
+	markRotatedErr := s.repo.MarkSessionRotated(ctx, currentSessionUUID, time.Now())
+	if errors.Is(markRotatedErr, ErrSessionRotated) {
+		return entity.TokenPair{}, s.revokeReusedFamily(ctx, currentSession)
+	}
+	if markRotatedErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, markRotatedErr)
+		return entity.TokenPair{}, fmt.Errorf("can't rotate session: %w", markRotatedErr)
 	}
 
 	if currentSession.Fingerprint != fingerprintUUID {
-		s.logger.Printf(logPattern, requestUUID(ctx), refreshMethod, autherrors.InvalidSession)
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidSession)
 		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidSession, errors.New("incorrect fingerprint"))
 	}
+	if sessionClientID(currentSession) != clientID {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidSession)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidSession, errors.New("session belongs to another client"))
+	}
 
 	user, getUserErr := s.repo.GetUserByID(ctx, currentSession.UserID)
 	if errors.Is(getUserErr, ErrEntityNotFound) {
-		s.logger.Printf(logPattern, requestUUID(ctx), refreshMethod, autherrors.UserNotExists)
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.UserNotExists)
 		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.UserNotExists, nil)
 	}
 	if getUserErr != nil {
-		s.logger.Warnf(logPattern, requestUUID(ctx), refreshMethod, getUserErr)
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getUserErr)
 		return entity.TokenPair{}, fmt.Errorf("can't check if user exists: %w", getUserErr)
 	}
+	if checkStatusErr := s.checkUserStatus(ctx, user, method); checkStatusErr != nil {
+		return entity.TokenPair{}, checkStatusErr
+	}
 
-	newSessionUUID := uuid.New()
-
-	tokenPair, generateTokensErr := s.tokenGenerator.GenerateTokenPair(user.Email, newSessionUUID)
+	refreshedAt := time.Now()
+	newSession := entity.Session{
+		ID:              uuid.New(),
+		UserID:          user.ID,
+		Fingerprint:     fingerprintUUID,
+		FamilyID:        currentSession.FamilyID,
+		CreatedAt:       currentSession.CreatedAt,
+		LastRefreshedAt: &refreshedAt,
+		IPAddress:       clientIP(ctx),
+		UserAgent:       userAgent(ctx),
+		OrgID:           newOrgID,
+		ClientID:        currentSession.ClientID,
+		Scope:           currentSession.Scope,
+		AuthTime:        currentSession.AuthTime,
+		AMR:             currentSession.AMR,
+	}
+
+	tokenPair, generateTokensErr := s.generateTokenPair(ctx, user, newSession)
 	if generateTokensErr != nil {
-		s.logger.Warnf(logPattern, requestUUID(ctx), refreshMethod, generateTokensErr)
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, generateTokensErr)
 		return entity.TokenPair{}, fmt.Errorf("can't generate token pair: %w", generateTokensErr)
 	}
+	newSession.ExpiresAt = tokenPair.Refresh.ExpiresAt
 
-	newSession := entity.Session{
-		ID:          newSessionUUID,
-		UserID:      user.ID,
-		Fingerprint: fingerprintUUID,
-		ExpiresAt:   tokenPair.Refresh.ExpiresAt,
+	if createSessionErr := s.repo.CreateSession(ctx, newSession); createSessionErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, createSessionErr)
+		return entity.TokenPair{}, fmt.Errorf("can't create user's session: %w", createSessionErr)
 	}
+	s.recordAudit(ctx, entity.AuditRefresh, user.Email, user.ID, method, nil)
 
-	if createSessionErr := s.repo.CreateSession(ctx, newSession); createSessionErr != nil {
-		s.logger.Warnf(logPattern, requestUUID(ctx), refreshMethod, createSessionErr)
+	return tokenPair, nil
+}
+
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	sessionUUID, validateErr := s.tokenGenerator.ValidateRefreshTokenAndGetSessionUUID(refreshToken)
+	if validateErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), logoutMethod, autherrors.InvalidToken)
+		return autherrors.NewStatusError(autherrors.InvalidToken, validateErr)
+	}
+
+	session, getSessionErr := s.repo.GetSessionByUUID(ctx, sessionUUID)
+	if errors.Is(getSessionErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), logoutMethod, autherrors.SessionNotExists)
+		return autherrors.NewStatusError(autherrors.SessionNotExists, nil)
+	}
+	if getSessionErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), logoutMethod, getSessionErr)
+		return fmt.Errorf("can't get user's session: %w", getSessionErr)
+	}
+
+	if deleteFamilyErr := s.repo.DeleteSessionsByFamily(ctx, session.FamilyID); deleteFamilyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), logoutMethod, deleteFamilyErr)
+		return fmt.Errorf("can't delete session: %w", deleteFamilyErr)
+	}
+	s.sessionCache.Delete(session.FamilyID)
+	s.recordAudit(ctx, entity.AuditLogout, userActor(session.UserID), session.UserID,
+		"session family "+session.FamilyID.String(), nil)
+
+	return nil
+}
+
+func (s *AuthService) LogoutAll(ctx context.Context, accessToken string) error {
+	user, _, authErr := s.authenticate(ctx, accessToken, logoutAllMethod)
+	if authErr != nil {
+		return authErr
+	}
+
+	if revokeErr := s.revokeUserSessions(ctx, user.ID, logoutAllMethod); revokeErr != nil {
+		return revokeErr
+	}
+	s.recordAudit(ctx, entity.AuditLogout, user.Email, user.ID, "all sessions", nil)
+
+	return nil
+}
+
+func (s *AuthService) ListSessions(ctx context.Context, accessToken string) ([]entity.Session, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, listSessionsMethod)
+	if authErr != nil {
+		return nil, authErr
+	}
+
+	sessions, getSessionsErr := s.repo.GetActiveSessionsByUserID(ctx, user.ID)
+	if getSessionsErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), listSessionsMethod, getSessionsErr)
+		return nil, fmt.Errorf("can't get user's sessions: %w", getSessionsErr)
+	}
+
+	return sessions, nil
+}
+
+func (s *AuthService) RevokeSession(ctx context.Context, accessToken string, sessionID string) error {
+	familyID, parseSessionIDErr := uuid.Parse(sessionID)
+	if parseSessionIDErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), revokeSessionMethod, autherrors.InvalidSessionID)
+		return autherrors.NewStatusError(autherrors.InvalidSessionID, nil)
+	}
+
+	user, _, authErr := s.authenticate(ctx, accessToken, revokeSessionMethod)
+	if authErr != nil {
+		return authErr
+	}
+
+	deleteSessionErr := s.repo.DeleteUserSessionsByFamily(ctx, user.ID, familyID)
+	if errors.Is(deleteSessionErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), revokeSessionMethod, autherrors.SessionNotExists)
+		return autherrors.NewStatusError(autherrors.SessionNotExists, nil)
+	}
+	if deleteSessionErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), revokeSessionMethod, deleteSessionErr)
+		return fmt.Errorf("can't delete session: %w", deleteSessionErr)
+	}
+	s.sessionCache.Delete(familyID)
+
+	return nil
+}
+
+// startSession issues token pair for authenticated user and starts new token family.
+func (s *AuthService) startSession(ctx context.Context, user entity.AuthUser,
+	fingerprint uuid.UUID, method string) (entity.TokenPair, error) {
+	return s.openSession(ctx, user, entity.Session{Fingerprint: fingerprint, AMR: sessionAMR(method)}, method)
+}
+
+// openSession is startSession for session prefilled with fingerprint and authentication methods and,
+// for OAuth, client, scope and time user authenticated at.
+func (s *AuthService) openSession(ctx context.Context, user entity.AuthUser,
+	session entity.Session, method string) (entity.TokenPair, error) {
+	if checkStatusErr := s.checkUserStatus(ctx, user, method); checkStatusErr != nil {
+		s.recordAudit(ctx, entity.AuditLoginFailure, user.Email, user.ID, method, checkStatusErr)
+		return entity.TokenPair{}, checkStatusErr
+	}
+
+	session.ID = uuid.New()
+	session.UserID = user.ID
+	session.FamilyID = uuid.New()
+	session.CreatedAt = time.Now()
+	if session.AuthTime.IsZero() {
+		session.AuthTime = session.CreatedAt
+	}
+	session.IPAddress = clientIP(ctx)
+	session.UserAgent = userAgent(ctx)
+
+	tokenPair, generateTokensErr := s.generateTokenPair(ctx, user, session)
+	if generateTokensErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, generateTokensErr)
+		return entity.TokenPair{}, fmt.Errorf("can't generate token pair: %w", generateTokensErr)
+	}
+	session.ExpiresAt = tokenPair.Refresh.ExpiresAt
+
+	if createSessionErr := s.repo.CreateSession(ctx, session); createSessionErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, createSessionErr)
 		return entity.TokenPair{}, fmt.Errorf("can't create user's session: %w", createSessionErr)
 	}
+	s.recordAudit(ctx, entity.AuditLoginSuccess, user.Email, user.ID, method, nil)
 
 	return tokenPair, nil
 }
 
+// generateTokenPair issues tokens that carry user's current roles and permissions.
+// Tokens of OAuth client carry only permissions of granted scope and no roles.
+func (s *AuthService) generateTokenPair(ctx context.Context, user entity.AuthUser,
+	session entity.Session) (entity.TokenPair, error) {
+	var access entity.UserAccess
+	var getAccessErr error
+	if session.OrgID != nil {
+		access, getAccessErr = s.repo.GetMemberAccess(ctx, *session.OrgID, user.ID)
+	} else {
+		access, getAccessErr = s.repo.GetUserAccess(ctx, user.ID)
+	}
+	if getAccessErr != nil {
+		return entity.TokenPair{}, fmt.Errorf("can't get user's roles: %w", getAccessErr)
+	}
+	if session.ClientID != nil {
+		access = scopedAccess(access, session.Scope)
+	}
+
+	return s.tokenGenerator.GenerateTokenPair(user, session, access)
+}
+
+// checkRevokedUserStatus explains why session of user was revoked, if it was done by status change.
+func (s *AuthService) checkRevokedUserStatus(ctx context.Context, email string) error {
+	user, getUserErr := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		return nil
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), validateMethod, getUserErr)
+		return fmt.Errorf("can't get user: %w", getUserErr)
+	}
+
+	return s.checkUserStatus(ctx, user, validateMethod)
+}
+
+func (s *AuthService) isSessionActive(ctx context.Context, sessionID uuid.UUID) (bool, error) {
+	if active, cached := s.sessionCache.Get(sessionID); cached {
+		return active, nil
+	}
+
+	active, checkSessionErr := s.repo.HasActiveSession(ctx, sessionID)
+	if checkSessionErr != nil {
+		return false, checkSessionErr
+	}
+	s.sessionCache.Set(sessionID, active)
+
+	return active, nil
+}
+
+// authenticate resolves the user that owns access token. Tokens issued to OAuth clients
+// are rejected: their scope doesn't cover account API. Session is checked even without
+// strict validation, so revoked ones stop working at once.
+func (s *AuthService) authenticate(ctx context.Context, accessToken string, method string) (entity.AuthUser, entity.AccessClaims, error) {
+	claims, validateErr := s.tokenGenerator.ValidateAccessToken(accessToken)
+	if validateErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidToken)
+		return entity.AuthUser{}, entity.AccessClaims{}, autherrors.NewStatusError(autherrors.InvalidToken, validateErr)
+	}
+	if claims.ClientID != "" {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.AccessDenied)
+		return entity.AuthUser{}, entity.AccessClaims{}, autherrors.NewStatusError(autherrors.AccessDenied,
+			errors.New("token of OAuth client can't be used for account API"))
+	}
+	if checkSessionErr := s.checkSessionActive(ctx, claims, method); checkSessionErr != nil {
+		return entity.AuthUser{}, entity.AccessClaims{}, checkSessionErr
+	}
+
+	user, getUserErr := s.repo.GetUserByEmail(ctx, claims.Email)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.UserNotExists)
+		return entity.AuthUser{}, entity.AccessClaims{}, autherrors.NewStatusError(autherrors.UserNotExists, nil)
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getUserErr)
+		return entity.AuthUser{}, entity.AccessClaims{}, fmt.Errorf("can't get user: %w", getUserErr)
+	}
+	if checkStatusErr := s.checkUserStatus(ctx, user, method); checkStatusErr != nil {
+		return entity.AuthUser{}, entity.AccessClaims{}, checkStatusErr
+	}
+
+	return user, claims, nil
+}
+
+// checkSessionActive rejects access token whose session was revoked.
+func (s *AuthService) checkSessionActive(ctx context.Context, claims entity.AccessClaims, method string) error {
+	active, checkSessionErr := s.isSessionActive(ctx, claims.SessionID)
+	if checkSessionErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, checkSessionErr)
+		return fmt.Errorf("can't check user's session: %w", checkSessionErr)
+	}
+	if !active {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidToken)
+		return autherrors.NewStatusError(autherrors.InvalidToken, errors.New("session was revoked"))
+	}
+
+	return nil
+}
+
+// revokeReusedFamily handles replay of already rotated refresh token: either the legitimate
+// client or an attacker holds a stolen token, so every session of the family is revoked.
+func (s *AuthService) revokeReusedFamily(ctx context.Context, session entity.Session) error {
+	s.recordAudit(ctx, entity.AuditRefreshTokenReuse, userActor(session.UserID), session.UserID,
+		"session family "+session.FamilyID.String(), autherrors.NewStatusError(autherrors.RefreshTokenReused, nil))
+
+	if deleteFamilyErr := s.repo.DeleteSessionsByFamily(ctx, session.FamilyID); deleteFamilyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), refreshMethod, deleteFamilyErr)
+		return fmt.Errorf("can't revoke session family: %w", deleteFamilyErr)
+	}
+	s.sessionCache.Delete(session.FamilyID)
+
+	return autherrors.NewStatusError(autherrors.RefreshTokenReused, nil)
+}
+
+func (s *AuthService) GetJWKS(_ context.Context) []entity.JSONWebKey {
+	return s.tokenGenerator.JWKS()
+}
+
 func (s *AuthService) StartClearingExpiredSessions(ctx context.Context) {
 	go func() {
-		ticker := time.NewTicker(s.sessionClearInterval)
+		ticker := time.NewTicker(s.cfg.SessionClearInterval)
 		defer func() {
 			ticker.Stop()
 			s.logger.Printf("stop clearing old sessions")
@@ -265,6 +691,42 @@ func validateEmail(email string) error {
 	return nil
 }
 
+// dummyPasswordHash is compared against when user doesn't exist, so Login takes
+// the same time whether account exists or not. Failed hashing is retried on next call:
+// comparing with empty hash would be fast and reveal that account doesn't exist.
+func (s *AuthService) dummyPasswordHash() (string, error) {
+	s.dummyHashMu.Lock()
+	defer s.dummyHashMu.Unlock()
+
+	if s.dummyHash == "" {
+		hash, hashErr := s.hasher.Hash(uuid.NewString())
+		if hashErr != nil {
+			return "", hashErr
+		}
+		s.dummyHash = hash
+	}
+
+	return s.dummyHash, nil
+}
+
+// detachedContext keeps values of request context, but not its cancellation,
+// for work that continues after response is sent.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
 func requestUUID(ctx context.Context) uuid.UUID {
 	reqUUIDValue := ctx.Value("request_id")
 	if reqUUIDValue == nil {
@@ -279,10 +741,38 @@ func requestUUID(ctx context.Context) uuid.UUID {
 	return reqUUID
 }
 
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value("client_ip").(string)
+
+	return ip
+}
+
+func userAgent(ctx context.Context) string {
+	ua, _ := ctx.Value("user_agent").(string)
+
+	return ua
+}
+
 func (s *AuthService) clearExpiredSessions(ctx context.Context) {
 	s.logger.Printf("clearing old sessions...")
 
 	if deleteSessionsErr := s.repo.DeleteExpiredSessions(ctx, time.Now()); deleteSessionsErr != nil {
 		s.logger.Fatal("can't clear old sessions: %w", deleteSessionsErr)
 	}
+	if deleteTokensErr := s.repo.DeleteExpiredOneTimeTokens(ctx, time.Now()); deleteTokensErr != nil {
+		s.logger.Warnf("can't clear expired one-time tokens: %v", deleteTokensErr)
+	}
+	if deleteCeremoniesErr := s.repo.DeleteExpiredPasskeyCeremonies(ctx, time.Now()); deleteCeremoniesErr != nil {
+		s.logger.Warnf("can't clear expired passkey ceremonies: %v", deleteCeremoniesErr)
+	}
+	if deleteInvitationsErr := s.repo.DeleteExpiredInvitations(ctx, time.Now()); deleteInvitationsErr != nil {
+		s.logger.Warnf("can't clear expired invitations: %v", deleteInvitationsErr)
+	}
+	if deleteCodesErr := s.repo.DeleteExpiredAuthorizationCodes(ctx, time.Now()); deleteCodesErr != nil {
+		s.logger.Warnf("can't clear expired authorization codes: %v", deleteCodesErr)
+	}
+	if deleteDeviceErr := s.repo.DeleteExpiredDeviceAuthorizations(ctx, time.Now()); deleteDeviceErr != nil {
+		s.logger.Warnf("can't clear expired device authorizations: %v", deleteDeviceErr)
+	}
+	s.clearStaleLoginAttempts(ctx)
 }
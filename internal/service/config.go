@@ -0,0 +1,117 @@
+package service
+
+import "time"
+
+type Config struct {
+	// EnumerationProtection hides whether account exists from Login and Register responses.
+	EnumerationProtection bool
+	SessionClearInterval  time.Duration
+	Validation            ValidationConfig
+	PasswordReset         PasswordResetConfig
+	EmailVerification     EmailVerificationConfig
+	LoginThrottle         LoginThrottleConfig
+	MFA                   MFAConfig
+	Passkey               PasskeyConfig
+	EmailLogin            EmailLoginConfig
+	Organization          OrganizationConfig
+	Admin                 AdminConfig
+	OAuth                 OAuthConfig
+	OIDC                  OIDCConfig
+}
+
+type ValidationConfig struct {
+	// Strict makes Validate check that token's session wasn't revoked.
+	Strict   bool
+	CacheTTL time.Duration
+}
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration
+	// URL of the page where user sets new password. Token is passed in query parameter.
+	URL string
+	// MaxSendsPerEmail and MaxSendsPerIP limit reset mail within login attempt window. Zero disables limit.
+	MaxSendsPerEmail int
+	MaxSendsPerIP    int
+}
+
+type EmailVerificationConfig struct {
+	// Required makes Login refuse unverified accounts. Otherwise the status is only reported in token claims.
+	Required bool
+	TokenTTL time.Duration
+	URL      string
+	// MaxSendsPerEmail and MaxSendsPerIP limit resent mail within login attempt window. Zero disables limit.
+	MaxSendsPerEmail int
+	MaxSendsPerIP    int
+}
+
+type LoginThrottleConfig struct {
+	// Window is how long failed attempts are remembered.
+	Window time.Duration
+	// BackoffAfter is the number of failures after which each next attempt is delayed
+	// by BackoffBase doubled per failure, up to BackoffMax. Client IP doesn't back off:
+	// users behind shared address would block each other with typos.
+	BackoffAfter int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	// LockoutThreshold is the number of failures per email that locks it for LockoutDuration.
+	LockoutThreshold   int
+	IPLockoutThreshold int
+	LockoutDuration    time.Duration
+}
+
+type MFAConfig struct {
+	// Issuer is shown in authenticator app next to account email.
+	Issuer string
+	// ChallengeTTL is how long client has to enter code after password was accepted.
+	ChallengeTTL       time.Duration
+	RecoveryCodesCount int
+	// SecretEncryptionKey encrypts TOTP secrets at rest.
+	SecretEncryptionKey []byte
+}
+
+type PasskeyConfig struct {
+	// CeremonyTTL is how long challenge of begin step can be answered.
+	CeremonyTTL time.Duration
+}
+
+type EmailLoginConfig struct {
+	TokenTTL time.Duration
+	// URL of the page that completes login. Token is passed in query parameter.
+	URL string
+	// MaxSendsPerEmail and MaxSendsPerIP limit login mail within login attempt window. Zero disables limit.
+	MaxSendsPerEmail int
+	MaxSendsPerIP    int
+}
+
+type OrganizationConfig struct {
+	InvitationTTL time.Duration
+	// InvitationURL of the page that accepts invitation. Token is passed in query parameter.
+	InvitationURL string
+}
+
+type AdminConfig struct {
+	// StaticKey grants access to admin API without user account. Empty disables it.
+	StaticKey string
+}
+
+type OAuthConfig struct {
+	// CodeTTL is how long authorization code can be exchanged for tokens.
+	CodeTTL time.Duration
+	// ClientTokenTTL is lifetime of access token of machine client, it has no refresh token.
+	ClientTokenTTL time.Duration
+	// DeviceCodeTTL is how long user has to enter user code of device authorization.
+	DeviceCodeTTL      time.Duration
+	DevicePollInterval time.Duration
+	// DeviceVerificationURL of the page where user enters user code. Code is also passed in query parameter.
+	DeviceVerificationURL string
+	// UserCodeLockoutThreshold is the number of wrong user codes entered by user that locks them out of
+	// device authorization as LoginThrottle does for passwords.
+	UserCodeLockoutThreshold int
+}
+
+type OIDCConfig struct {
+	// Issuer is public URL of the provider: ID tokens and discovery document are issued for it,
+	// endpoints are advertised under it.
+	Issuer     string
+	IDTokenTTL time.Duration
+}
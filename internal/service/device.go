@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"strings"
+	"time"
+)
+
+const (
+	// userCodeAlphabet has no vowels and similar looking characters, so codes are easy to type
+	// and don't form words, as RFC 8628 recommends.
+	userCodeAlphabet   = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength     = 8
+	userCodeGroupSize  = 4
+	userCodeSeparator  = "-"
+	slowDownIncrement  = 5 * time.Second
+	userCodeQueryParam = "user_code"
+)
+
+// StartDeviceAuthorization is device authorization endpoint of RFC 8628: it gives device code to poll
+// token endpoint with and user code that user enters on verification page.
+func (s *AuthService) StartDeviceAuthorization(ctx context.Context, clientID string, clientSecret string,
+	scope string) (entity.DeviceAuthorizationStart, error) {
+	client, authClientErr := s.authenticateOAuthClient(ctx, clientID, clientSecret, startDeviceAuthorizationMethod)
+	if authClientErr != nil {
+		return entity.DeviceAuthorizationStart{}, authClientErr
+	}
+
+	normalizedScope, normalizeErr := normalizePermissions(strings.Fields(scope))
+	if normalizeErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), startDeviceAuthorizationMethod, autherrors.InvalidScope)
+		return entity.DeviceAuthorizationStart{}, autherrors.NewStatusError(autherrors.InvalidScope, normalizeErr)
+	}
+	checkScopeErr := s.checkOpenIDScope(ctx, strings.Join(normalizedScope, " "), startDeviceAuthorizationMethod)
+	if checkScopeErr != nil {
+		return entity.DeviceAuthorizationStart{}, checkScopeErr
+	}
+
+	deviceCode, generateDeviceCodeErr := securetoken.Generate(securetoken.DefaultSize)
+	if generateDeviceCodeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startDeviceAuthorizationMethod, generateDeviceCodeErr)
+		return entity.DeviceAuthorizationStart{}, fmt.Errorf("can't generate device code: %w", generateDeviceCodeErr)
+	}
+	userCode, generateUserCodeErr := securetoken.GenerateCode(userCodeAlphabet, userCodeLength)
+	if generateUserCodeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startDeviceAuthorizationMethod, generateUserCodeErr)
+		return entity.DeviceAuthorizationStart{}, fmt.Errorf("can't generate user code: %w", generateUserCodeErr)
+	}
+
+	expiresAt := time.Now().Add(s.cfg.OAuth.DeviceCodeTTL)
+	createAuthorizationErr := s.repo.CreateDeviceAuthorization(ctx, entity.DeviceAuthorization{
+		DeviceCodeHash: securetoken.Hash(deviceCode),
+		UserCode:       userCode,
+		ClientID:       client.ID,
+		Scope:          strings.Join(normalizedScope, " "),
+		Status:         entity.DevicePending,
+		PollInterval:   int(s.cfg.OAuth.DevicePollInterval.Seconds()),
+		ExpiresAt:      expiresAt,
+	})
+	if createAuthorizationErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startDeviceAuthorizationMethod, createAuthorizationErr)
+		return entity.DeviceAuthorizationStart{}, fmt.Errorf("can't save device authorization: %w", createAuthorizationErr)
+	}
+
+	displayCode := userCode[:userCodeGroupSize] + userCodeSeparator + userCode[userCodeGroupSize:]
+	verificationURIComplete, buildURIErr := redirectWithQuery(s.cfg.OAuth.DeviceVerificationURL,
+		map[string]string{userCodeQueryParam: displayCode})
+	if buildURIErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startDeviceAuthorizationMethod, buildURIErr)
+		return entity.DeviceAuthorizationStart{}, fmt.Errorf("can't build verification uri: %w", buildURIErr)
+	}
+
+	return entity.DeviceAuthorizationStart{
+		DeviceCode:              deviceCode,
+		UserCode:                displayCode,
+		VerificationURI:         s.cfg.OAuth.DeviceVerificationURL,
+		VerificationURIComplete: verificationURIComplete,
+		ExpiresAt:               expiresAt,
+		PollInterval:            s.cfg.OAuth.DevicePollInterval,
+	}, nil
+}
+
+// LookupDeviceAuthorization tells user which client asks for what scope with user code, so they can
+// recognize request they didn't start on their device (RFC 8628, section 5.4). ConsentGranted isn't
+// filled: device requests are always confirmed by user.
+func (s *AuthService) LookupDeviceAuthorization(ctx context.Context, accessToken string,
+	userCode string) (entity.AuthorizationPrompt, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, lookupDeviceAuthorizationMethod)
+	if authErr != nil {
+		return entity.AuthorizationPrompt{}, authErr
+	}
+
+	attempt, throttleErr := s.reserveUserAttempt(ctx, userCodePrefix, user.ID,
+		s.cfg.OAuth.UserCodeLockoutThreshold, lookupDeviceAuthorizationMethod)
+	if throttleErr != nil {
+		return entity.AuthorizationPrompt{}, throttleErr
+	}
+
+	authorization, getAuthorizationErr := s.repo.GetPendingDeviceAuthorization(ctx, normalizeUserCode(userCode), time.Now())
+	if errors.Is(getAuthorizationErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), lookupDeviceAuthorizationMethod, autherrors.InvalidUserCode)
+		return entity.AuthorizationPrompt{}, autherrors.NewStatusError(autherrors.InvalidUserCode, nil)
+	}
+	if getAuthorizationErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), lookupDeviceAuthorizationMethod, getAuthorizationErr)
+		return entity.AuthorizationPrompt{}, fmt.Errorf("can't get device authorization: %w", getAuthorizationErr)
+	}
+	s.releaseLoginAttempt(ctx, attempt)
+
+	client, getClientErr := s.repo.GetOAuthClient(ctx, authorization.ClientID)
+	if errors.Is(getClientErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), lookupDeviceAuthorizationMethod, autherrors.OAuthClientNotExists)
+		return entity.AuthorizationPrompt{}, autherrors.NewStatusError(autherrors.OAuthClientNotExists, nil)
+	}
+	if getClientErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), lookupDeviceAuthorizationMethod, getClientErr)
+		return entity.AuthorizationPrompt{}, fmt.Errorf("can't get oauth client: %w", getClientErr)
+	}
+
+	return entity.AuthorizationPrompt{ClientName: client.Name, Scope: authorization.Scope}, nil
+}
+
+// ResolveDeviceAuthorization records decision of authenticated user on request with user code.
+// Approval is consent, so client gets the scope the same way as with authorization code.
+// Wrong user codes are throttled per user as in LookupDeviceAuthorization.
+func (s *AuthService) ResolveDeviceAuthorization(ctx context.Context, accessToken string, userCode string,
+	approved bool) error {
+	user, claims, authErr := s.authenticate(ctx, accessToken, resolveDeviceAuthorizationMethod)
+	if authErr != nil {
+		return authErr
+	}
+
+	attempt, throttleErr := s.reserveUserAttempt(ctx, userCodePrefix, user.ID,
+		s.cfg.OAuth.UserCodeLockoutThreshold, resolveDeviceAuthorizationMethod)
+	if throttleErr != nil {
+		return throttleErr
+	}
+
+	resolution := entity.DeviceAuthorization{
+		Status:   entity.DeviceDenied,
+		UserID:   &user.ID,
+		AuthTime: &claims.AuthTime,
+		AMR:      strings.Join(claims.AMR, " "),
+	}
+	if approved {
+		resolution.Status = entity.DeviceApproved
+	}
+
+	authorization, resolveErr := s.repo.ResolveDeviceAuthorization(ctx, normalizeUserCode(userCode), resolution, time.Now())
+	if errors.Is(resolveErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), resolveDeviceAuthorizationMethod, autherrors.InvalidUserCode)
+		return autherrors.NewStatusError(autherrors.InvalidUserCode, nil)
+	}
+	if resolveErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), resolveDeviceAuthorizationMethod, resolveErr)
+		return fmt.Errorf("can't resolve device authorization: %w", resolveErr)
+	}
+	s.releaseLoginAttempt(ctx, attempt)
+	if !approved {
+		return nil
+	}
+
+	grantConsentErr := s.grantConsent(ctx, user.ID, authorization.ClientID, authorization.Scope,
+		resolveDeviceAuthorizationMethod)
+	if grantConsentErr != nil {
+		return grantConsentErr
+	}
+	s.recordAudit(ctx, entity.AuditOAuthAuthorize, user.Email, user.ID,
+		"device of client "+authorization.ClientID+" scope "+authorization.Scope, nil)
+
+	return nil
+}
+
+// exchangeDeviceCode answers poll of device: authorization_pending until user resolves request
+// and slow_down, with longer interval, if device polls more often than it was told.
+func (s *AuthService) exchangeDeviceCode(ctx context.Context, client entity.OAuthClient,
+	deviceCode string) (entity.OAuthTokens, error) {
+	deviceCodeHash := securetoken.Hash(deviceCode)
+	authorization, getAuthorizationErr := s.repo.GetDeviceAuthorization(ctx, deviceCodeHash)
+	if errors.Is(getAuthorizationErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.InvalidGrant)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.InvalidGrant, nil)
+	}
+	if getAuthorizationErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, getAuthorizationErr)
+		return entity.OAuthTokens{}, fmt.Errorf("can't get device authorization: %w", getAuthorizationErr)
+	}
+	if authorization.ClientID != client.ID {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.InvalidGrant)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.InvalidGrant,
+			errors.New("device code was issued to another client"))
+	}
+
+	now := time.Now()
+	if !authorization.ExpiresAt.After(now) {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.ExpiredDeviceCode)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.ExpiredDeviceCode, nil)
+	}
+
+	interval := time.Duration(authorization.PollInterval) * time.Second
+	tooFast := authorization.LastPolledAt != nil && now.Sub(*authorization.LastPolledAt) < interval
+	if tooFast {
+		interval += slowDownIncrement
+	}
+	if recordPollErr := s.repo.RecordDevicePoll(ctx, deviceCodeHash, now, int(interval.Seconds())); recordPollErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, recordPollErr)
+		return entity.OAuthTokens{}, fmt.Errorf("can't record device poll: %w", recordPollErr)
+	}
+	if tooFast {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.SlowDown)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.SlowDown, nil)
+	}
+	if authorization.Status == entity.DevicePending {
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.AuthorizationPending, nil)
+	}
+
+	authorization, consumeErr := s.repo.ConsumeDeviceAuthorization(ctx, deviceCodeHash)
+	if errors.Is(consumeErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.InvalidGrant)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.InvalidGrant, nil)
+	}
+	if consumeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, consumeErr)
+		return entity.OAuthTokens{}, fmt.Errorf("can't consume device authorization: %w", consumeErr)
+	}
+	if authorization.Status == entity.DeviceDenied || authorization.UserID == nil || authorization.AuthTime == nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.DeviceAccessDenied)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.DeviceAccessDenied, nil)
+	}
+
+	user, getUserErr := s.getUserByID(ctx, *authorization.UserID, exchangeOAuthTokenMethod)
+	if getUserErr != nil {
+		return entity.OAuthTokens{}, getUserErr
+	}
+
+	clientID := client.ID
+	tokenPair, openSessionErr := s.openSession(ctx, user, entity.Session{
+		Fingerprint: clientFingerprint(client.ID),
+		ClientID:    &clientID,
+		Scope:       authorization.Scope,
+		AuthTime:    *authorization.AuthTime,
+		AMR:         authorization.AMR,
+	}, exchangeOAuthTokenMethod)
+	if openSessionErr != nil {
+		return entity.OAuthTokens{}, openSessionErr
+	}
+
+	tokens := entity.OAuthTokens{TokenPair: tokenPair, Scope: authorization.Scope}
+	if scopeCovers(authorization.Scope, entity.OpenIDScope) {
+		idToken, generateIDTokenErr := s.generateIDToken(user, client.ID, "", *authorization.AuthTime, authorization.AMR)
+		if generateIDTokenErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, generateIDTokenErr)
+			return entity.OAuthTokens{}, fmt.Errorf("can't generate id token: %w", generateIDTokenErr)
+		}
+		tokens.IDToken = idToken
+	}
+
+	return tokens, nil
+}
+
+// normalizeUserCode lets user type code in any case, with or without separator.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+
+		return r
+	}, strings.ToUpper(userCode))
+}
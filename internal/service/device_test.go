@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"context"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"strings"
+	"testing"
+	"time"
+)
+
+const deviceClientID = "smart-tv"
+
+var testDeviceOAuth = service.OAuthConfig{
+	DeviceCodeTTL:            time.Minute * 10,
+	DevicePollInterval:       time.Second * 5,
+	DeviceVerificationURL:    "https://auth.example.com/device",
+	UserCodeLockoutThreshold: 3,
+}
+
+// deviceFixture is TV that polls token endpoint and its owner who enters user code on the phone.
+type deviceFixture struct {
+	ctx         context.Context
+	svc         *service.AuthService
+	repo        *memoryRepository
+	accessToken string
+	start       entity.DeviceAuthorizationStart
+}
+
+func newDeviceFixture(t *testing.T) deviceFixture {
+	t.Helper()
+
+	user := entity.AuthUser{ID: 1, Email: "owner@example.com", Status: entity.UserActive}
+	repo := newMemoryRepository(user)
+	repo.clients[deviceClientID] = entity.OAuthClient{ID: deviceClientID, Name: "Smart TV"}
+	svc, tokenGenerator := newTestService(t, repo, tokengen.NewHMACSigningKey("", "access-secret"), nil, service.Config{
+		LoginThrottle: testThrottle,
+		OAuth:         testDeviceOAuth,
+	})
+
+	ctx := context.Background()
+	start, startErr := svc.StartDeviceAuthorization(ctx, deviceClientID, "", "media:play")
+	if startErr != nil {
+		t.Fatalf("StartDeviceAuthorization: %v", startErr)
+	}
+
+	return deviceFixture{
+		ctx:         ctx,
+		svc:         svc,
+		repo:        repo,
+		accessToken: loginAs(t, tokenGenerator, repo, user),
+		start:       start,
+	}
+}
+
+func (f deviceFixture) poll() (entity.OAuthTokens, error) {
+	return f.svc.ExchangeOAuthToken(f.ctx, entity.OAuthTokenRequest{
+		GrantType:  entity.DeviceCodeGrant,
+		ClientID:   deviceClientID,
+		DeviceCode: f.start.DeviceCode,
+	})
+}
+
+func (f deviceFixture) pollInterval() int {
+	f.repo.mu.Lock()
+	defer f.repo.mu.Unlock()
+
+	return f.repo.devices[securetoken.Hash(f.start.DeviceCode)].PollInterval
+}
+
+func TestDevicePolling(t *testing.T) {
+	f := newDeviceFixture(t)
+
+	if _, pollErr := f.poll(); !autherrors.Is(pollErr, autherrors.AuthorizationPending) {
+		t.Fatalf("first poll: %v", pollErr)
+	}
+	if _, pollErr := f.poll(); !autherrors.Is(pollErr, autherrors.SlowDown) {
+		t.Fatalf("poll within interval: %v", pollErr)
+	}
+	if interval := f.pollInterval(); interval != 10 {
+		t.Fatalf("poll interval after slow_down = %ds, want 10s", interval)
+	}
+
+	// Device that waits the old interval is still too fast.
+	f.repo.rewindDevicePolls(testDeviceOAuth.DevicePollInterval + time.Second)
+	if _, pollErr := f.poll(); !autherrors.Is(pollErr, autherrors.SlowDown) {
+		t.Fatalf("poll within increased interval: %v", pollErr)
+	}
+	f.repo.rewindDevicePolls(time.Second * 16)
+	if _, pollErr := f.poll(); !autherrors.Is(pollErr, autherrors.AuthorizationPending) {
+		t.Fatalf("poll after increased interval: %v", pollErr)
+	}
+
+	prompt, lookupErr := f.svc.LookupDeviceAuthorization(f.ctx, f.accessToken, strings.ToLower(f.start.UserCode))
+	if lookupErr != nil {
+		t.Fatalf("LookupDeviceAuthorization: %v", lookupErr)
+	}
+	if prompt.ClientName != "Smart TV" || prompt.Scope != "media:play" {
+		t.Fatalf("unexpected prompt: %+v", prompt)
+	}
+	userCode := strings.ReplaceAll(f.start.UserCode, "-", "")
+	if resolveErr := f.svc.ResolveDeviceAuthorization(f.ctx, f.accessToken, userCode, true); resolveErr != nil {
+		t.Fatalf("ResolveDeviceAuthorization: %v", resolveErr)
+	}
+
+	f.repo.rewindDevicePolls(time.Second * 16)
+	tokens, pollErr := f.poll()
+	if pollErr != nil {
+		t.Fatalf("poll after approval: %v", pollErr)
+	}
+	if tokens.Access.Token == "" || tokens.Scope != "media:play" {
+		t.Fatalf("unexpected tokens: %+v", tokens)
+	}
+
+	f.repo.rewindDevicePolls(time.Second * 16)
+	if _, pollErr := f.poll(); !autherrors.Is(pollErr, autherrors.InvalidGrant) {
+		t.Fatalf("device code is exchanged twice: %v", pollErr)
+	}
+}
+
+func TestDeniedDeviceAuthorization(t *testing.T) {
+	f := newDeviceFixture(t)
+
+	if resolveErr := f.svc.ResolveDeviceAuthorization(f.ctx, f.accessToken, f.start.UserCode, false); resolveErr != nil {
+		t.Fatalf("ResolveDeviceAuthorization: %v", resolveErr)
+	}
+	if _, pollErr := f.poll(); !autherrors.Is(pollErr, autherrors.DeviceAccessDenied) {
+		t.Fatalf("poll after denial: %v", pollErr)
+	}
+}
+
+func TestWrongUserCodesAreThrottled(t *testing.T) {
+	f := newDeviceFixture(t)
+
+	for i := 1; i <= testDeviceOAuth.UserCodeLockoutThreshold; i++ {
+		resolveErr := f.svc.ResolveDeviceAuthorization(f.ctx, f.accessToken, "BCDF-GHJK", true)
+		if !autherrors.Is(resolveErr, autherrors.InvalidUserCode) {
+			t.Fatalf("wrong code %d: %v", i, resolveErr)
+		}
+	}
+	if failures := f.repo.failures("user-code:1"); failures != testDeviceOAuth.UserCodeLockoutThreshold {
+		t.Fatalf("%d wrong codes are counted, want %d", failures, testDeviceOAuth.UserCodeLockoutThreshold)
+	}
+
+	// Right code doesn't help once user is locked out, neither to look request up nor to approve it.
+	_, lookupErr := f.svc.LookupDeviceAuthorization(f.ctx, f.accessToken, f.start.UserCode)
+	requireThrottled(t, lookupErr, testThrottle.LockoutDuration-time.Second)
+	resolveErr := f.svc.ResolveDeviceAuthorization(f.ctx, f.accessToken, f.start.UserCode, true)
+	requireThrottled(t, resolveErr, testThrottle.LockoutDuration-time.Second)
+
+	// Password lockout is kept apart.
+	if failures := f.repo.failures("email:owner@example.com"); failures != 0 {
+		t.Fatalf("wrong user codes count as %d password failures", failures)
+	}
+
+	f.repo.liftBlocks()
+	if resolveErr := f.svc.ResolveDeviceAuthorization(f.ctx, f.accessToken, f.start.UserCode, true); resolveErr != nil {
+		t.Fatalf("ResolveDeviceAuthorization after lockout: %v", resolveErr)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"github.com/google/uuid"
+	"time"
+)
+
+const emailLoginCodeDigits = 6
+
+// StartEmailLogin mails link and code that let user log in without password.
+// Both are bound to fingerprint, so they are useless on another device.
+func (s *AuthService) StartEmailLogin(ctx context.Context, email string, fingerprint string) error {
+	fingerprintUUID, parseFingerprintErr := uuid.Parse(fingerprint)
+	if parseFingerprintErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), startEmailLoginMethod, autherrors.InvalidFingerprint)
+		return autherrors.NewStatusError(autherrors.InvalidFingerprint, nil)
+	}
+
+	if throttleErr := s.checkLoginBlocked(ctx, email); throttleErr != nil {
+		return throttleErr
+	}
+	reserveSendErr := s.reserveMailSend(ctx, entity.EmailLoginPurpose, email,
+		s.cfg.EmailLogin.MaxSendsPerEmail, s.cfg.EmailLogin.MaxSendsPerIP, startEmailLoginMethod)
+	if reserveSendErr != nil {
+		return reserveSendErr
+	}
+
+	user, getUserErr := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), startEmailLoginMethod, autherrors.UserNotExists)
+		return nil
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startEmailLoginMethod, getUserErr)
+		return fmt.Errorf("can't get user: %w", getUserErr)
+	}
+
+	if deleteTokensErr := s.repo.DeleteUserOneTimeTokens(ctx, user.ID, entity.EmailLoginPurpose); deleteTokensErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startEmailLoginMethod, deleteTokensErr)
+		return fmt.Errorf("can't delete previous login tokens: %w", deleteTokensErr)
+	}
+
+	linkToken, generateTokenErr := securetoken.Generate(securetoken.DefaultSize)
+	if generateTokenErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startEmailLoginMethod, generateTokenErr)
+		return fmt.Errorf("can't generate login token: %w", generateTokenErr)
+	}
+	code, generateCodeErr := securetoken.GenerateNumericCode(emailLoginCodeDigits)
+	if generateCodeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), startEmailLoginMethod, generateCodeErr)
+		return fmt.Errorf("can't generate login code: %w", generateCodeErr)
+	}
+
+	expiresAt := time.Now().Add(s.cfg.EmailLogin.TokenTTL)
+	for _, tokenHash := range []string{securetoken.Hash(linkToken), emailLoginCodeHash(email, code)} {
+		createTokenErr := s.repo.CreateOneTimeToken(ctx, entity.OneTimeToken{
+			TokenHash:   tokenHash,
+			UserID:      user.ID,
+			Purpose:     entity.EmailLoginPurpose,
+			ExpiresAt:   expiresAt,
+			Fingerprint: &fingerprintUUID,
+		})
+		if createTokenErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), startEmailLoginMethod, createTokenErr)
+			return fmt.Errorf("can't save login token: %w", createTokenErr)
+		}
+	}
+
+	s.sendMail(ctx, startEmailLoginMethod, emailLoginMail(user.Email,
+		linkWithToken(s.cfg.EmailLogin.URL, linkToken), code, s.cfg.EmailLogin.TokenTTL))
+
+	return nil
+}
+
+// CompleteEmailLogin exchanges link token or code for token pair, as Login does for password.
+func (s *AuthService) CompleteEmailLogin(ctx context.Context, login entity.EmailLogin,
+	fingerprint string) (entity.LoginResult, error) {
+	fingerprintUUID, parseFingerprintErr := uuid.Parse(fingerprint)
+	if parseFingerprintErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), completeEmailLoginMethod, autherrors.InvalidFingerprint)
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.InvalidFingerprint, nil)
+	}
+
+	byCode := login.Token == ""
+	tokenHash := securetoken.Hash(login.Token)
+	var attempt loginAttempt
+	if byCode {
+		if login.Email == "" || login.Code == "" {
+			s.logger.Printf(logPattern, requestUUID(ctx), completeEmailLoginMethod, autherrors.InvalidToken)
+			return entity.LoginResult{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("token or email with code is required"))
+		}
+		// Code is guessable, so its attempts are limited as password ones.
+		var throttleErr error
+		if attempt, throttleErr = s.checkLoginThrottle(ctx, login.Email); throttleErr != nil {
+			return entity.LoginResult{}, throttleErr
+		}
+		tokenHash = emailLoginCodeHash(login.Email, login.Code)
+	}
+
+	token, consumeErr := s.repo.ConsumeOneTimeToken(ctx, tokenHash, entity.EmailLoginPurpose, time.Now())
+	if errors.Is(consumeErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), completeEmailLoginMethod, autherrors.InvalidToken)
+		s.recordAudit(ctx, entity.AuditLoginFailure, login.Email, 0, completeEmailLoginMethod,
+			autherrors.NewStatusError(autherrors.InvalidToken, nil))
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("login token is unknown, used or expired"))
+	}
+	if consumeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), completeEmailLoginMethod, consumeErr)
+		return entity.LoginResult{}, fmt.Errorf("can't use login token: %w", consumeErr)
+	}
+
+	if deleteTokensErr := s.repo.DeleteUserOneTimeTokens(ctx, token.UserID, entity.EmailLoginPurpose); deleteTokensErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), completeEmailLoginMethod, deleteTokensErr)
+		return entity.LoginResult{}, fmt.Errorf("can't delete other login tokens: %w", deleteTokensErr)
+	}
+
+	if token.Fingerprint == nil || *token.Fingerprint != fingerprintUUID {
+		s.logger.Printf(logPattern, requestUUID(ctx), completeEmailLoginMethod, autherrors.InvalidToken)
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("login was requested from another device"))
+	}
+
+	user, getUserErr := s.repo.GetUserByID(ctx, token.UserID)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), completeEmailLoginMethod, autherrors.UserNotExists)
+		return entity.LoginResult{}, autherrors.NewStatusError(autherrors.UserNotExists, nil)
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), completeEmailLoginMethod, getUserErr)
+		return entity.LoginResult{}, fmt.Errorf("can't get user: %w", getUserErr)
+	}
+
+	// Following the mailed link proves ownership of the address.
+	if !user.EmailVerified {
+		if verifyErr := s.repo.SetUserEmailVerified(ctx, user.ID); verifyErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), completeEmailLoginMethod, verifyErr)
+			return entity.LoginResult{}, fmt.Errorf("can't mark email as verified: %w", verifyErr)
+		}
+		user.EmailVerified = true
+	}
+	if user.Status == entity.UserPending {
+		if activateErr := s.activatePendingUser(ctx, user.ID, completeEmailLoginMethod); activateErr != nil {
+			return entity.LoginResult{}, activateErr
+		}
+		user.Status = entity.UserActive
+	}
+	s.passLoginAttempt(ctx, user.Email, attempt)
+
+	return s.completeFirstFactor(ctx, user, fingerprintUUID, completeEmailLoginMethod)
+}
+
+// emailLoginCodeHash binds code to email: codes are short, so different users may get the same one.
+func emailLoginCodeHash(email string, code string) string {
+	return securetoken.Hash(normalizeEmail(email) + ":" + code)
+}
@@ -7,4 +7,9 @@ import (
 var (
 	ErrEntityNotFound = errors.New("entity was not found")
 	ErrSessionExpired = errors.New("session has expired")
+	ErrSessionRotated = errors.New("session was already rotated")
+	ErrTOTPConfirmed  = errors.New("totp is already confirmed")
+	ErrTOTPCodeUsed   = errors.New("totp code was already used")
+	ErrInvalidPasskey = errors.New("passkey response is invalid")
+	ErrLastRoleHolder = errors.New("role has no other holders")
 )
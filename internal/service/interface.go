@@ -17,13 +17,133 @@ type Logger interface {
 }
 
 type AuthRepository interface {
-	CreateUser(ctx context.Context, user entity.AuthUser) error
+	CreateUser(ctx context.Context, user entity.AuthUser) (int64, error)
 	CreateSession(ctx context.Context, session entity.Session) error
 	GetUserByEmail(ctx context.Context, email string) (entity.AuthUser, error)
 	GetUserByID(ctx context.Context, id int64) (entity.AuthUser, error)
+	UpdateUserPassword(ctx context.Context, userID int64, hashedPassword string) error
+	SetUserEmailVerified(ctx context.Context, userID int64) error
 	GetSessionByUUID(ctx context.Context, sessionUUID uuid.UUID) (entity.Session, error)
+	GetActiveSessionsByUserID(ctx context.Context, userID int64) ([]entity.Session, error)
+	HasActiveSession(ctx context.Context, familyID uuid.UUID) (bool, error)
 	DeleteSessionByUUID(ctx context.Context, sessionUUID uuid.UUID) error
+	MarkSessionRotated(ctx context.Context, sessionUUID uuid.UUID, rotatedAt time.Time) error
+	DeleteSessionsByFamily(ctx context.Context, familyID uuid.UUID) error
+	DeleteUserSessionsByFamily(ctx context.Context, userID int64, familyID uuid.UUID) error
+	DeleteSessionsByUserID(ctx context.Context, userID int64) error
+	DeleteSessionsByUserIDExceptFamily(ctx context.Context, userID int64, familyID uuid.UUID) error
 	DeleteExpiredSessions(ctx context.Context, olderThan time.Time) error
+	OneTimeTokenRepository
+	LoginAttemptRepository
+	TOTPRepository
+	PasskeyRepository
+	RoleRepository
+	OrganizationRepository
+	UserAdminRepository
+	AuditRepository
+	OAuthRepository
+	APIKeyRepository
+	DeviceAuthorizationRepository
+}
+
+type OneTimeTokenRepository interface {
+	CreateOneTimeToken(ctx context.Context, token entity.OneTimeToken) error
+	GetOneTimeToken(ctx context.Context, tokenHash string, purpose string, now time.Time) (entity.OneTimeToken, error)
+	ConsumeOneTimeToken(ctx context.Context, tokenHash string, purpose string, usedAt time.Time) (entity.OneTimeToken, error)
+	DeleteUserOneTimeTokens(ctx context.Context, userID int64, purpose string) error
+	DeleteExpiredOneTimeTokens(ctx context.Context, expiredAt time.Time) error
+}
+
+type LoginAttemptRepository interface {
+	GetLoginAttempts(ctx context.Context, keys []string) ([]entity.LoginAttempt, error)
+	ReserveLoginAttempt(ctx context.Context, key string, attemptAt time.Time, windowStart time.Time,
+		blockDelay func(failures int) time.Duration) (int, error)
+	ReleaseLoginAttempt(ctx context.Context, key string, liftBlock bool) error
+	DeleteLoginAttempts(ctx context.Context, key string) error
+	DeleteStaleLoginAttempts(ctx context.Context, lastFailureBefore time.Time) error
+}
+
+type TOTPRepository interface {
+	GetUserTOTP(ctx context.Context, userID int64) (entity.UserTOTP, error)
+	SaveUnconfirmedTOTP(ctx context.Context, userID int64, sealedSecret string) error
+	EncryptUserTOTP(ctx context.Context, userID int64, sealedSecret string) error
+	ConfirmUserTOTP(ctx context.Context, userID int64, step int64, confirmedAt time.Time) error
+	UseTOTPStep(ctx context.Context, userID int64, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string, usedAt time.Time) error
+}
+
+type PasskeyRepository interface {
+	GetUserPasskeys(ctx context.Context, userID int64) ([]entity.PasskeyCredential, error)
+	CreatePasskey(ctx context.Context, passkey entity.PasskeyCredential) error
+	UpdatePasskeyUsage(ctx context.Context, passkey entity.PasskeyCredential, usedAt time.Time) error
+	CreatePasskeyCeremony(ctx context.Context, ceremony entity.PasskeyCeremony) error
+	ConsumePasskeyCeremony(ctx context.Context, ceremonyID uuid.UUID, purpose string, now time.Time) (entity.PasskeyCeremony, error)
+	DeleteExpiredPasskeyCeremonies(ctx context.Context, expiredAt time.Time) error
+}
+
+type RoleRepository interface {
+	GetRole(ctx context.Context, name string) (entity.Role, error)
+	GetRoles(ctx context.Context) ([]entity.Role, error)
+	SaveRole(ctx context.Context, role entity.Role) error
+	GetUserAccess(ctx context.Context, userID int64) (entity.UserAccess, error)
+	AssignUserRole(ctx context.Context, userID int64, role string) error
+	RevokeUserRole(ctx context.Context, userID int64, role string, keepLastHolder bool) error
+}
+
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, name string, owner entity.Membership) (entity.Organization, error)
+	GetUserMemberships(ctx context.Context, userID int64) ([]entity.Membership, error)
+	GetMembership(ctx context.Context, orgID int64, userID int64) (entity.Membership, error)
+	GetMemberAccess(ctx context.Context, orgID int64, userID int64) (entity.UserAccess, error)
+	SaveMembership(ctx context.Context, membership entity.Membership) error
+	CreateInvitation(ctx context.Context, invitation entity.OrganizationInvitation) error
+	AcceptInvitation(ctx context.Context, tokenHash string, email string, acceptedAt time.Time) (entity.OrganizationInvitation, error)
+	DeleteExpiredInvitations(ctx context.Context, expiredAt time.Time) error
+}
+
+type UserAdminRepository interface {
+	SearchUsers(ctx context.Context, filter entity.UserFilter) ([]entity.AuthUser, error)
+	UpdateUserStatus(ctx context.Context, userID int64, from entity.UserStatus, to entity.UserStatus, changedAt time.Time) error
+}
+
+type AuditRepository interface {
+	AppendAuditEvent(ctx context.Context, event entity.AuditEvent) (entity.AuditEvent, error)
+	GetAuditEvents(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error)
+}
+
+type OAuthRepository interface {
+	CreateOAuthClient(ctx context.Context, client entity.OAuthClient) error
+	GetOAuthClient(ctx context.Context, clientID string) (entity.OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, clientID string) error
+	GetOAuthConsent(ctx context.Context, userID int64, clientID string) (entity.OAuthConsent, error)
+	SaveOAuthConsent(ctx context.Context, consent entity.OAuthConsent) error
+	CreateAuthorizationCode(ctx context.Context, code entity.AuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string, now time.Time) (entity.AuthorizationCode, error)
+	DeleteExpiredAuthorizationCodes(ctx context.Context, expiredAt time.Time) error
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key entity.APIKey) error
+	GetUserAPIKeys(ctx context.Context, userID int64) ([]entity.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (entity.APIKey, error)
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID, usedAt time.Time, usedBefore time.Time) error
+	DeleteUserAPIKey(ctx context.Context, userID int64, keyID uuid.UUID) error
+}
+
+type DeviceAuthorizationRepository interface {
+	CreateDeviceAuthorization(ctx context.Context, authorization entity.DeviceAuthorization) error
+	GetDeviceAuthorization(ctx context.Context, deviceCodeHash string) (entity.DeviceAuthorization, error)
+	GetPendingDeviceAuthorization(ctx context.Context, userCode string, now time.Time) (entity.DeviceAuthorization, error)
+	ResolveDeviceAuthorization(ctx context.Context, userCode string, resolution entity.DeviceAuthorization,
+		now time.Time) (entity.DeviceAuthorization, error)
+	RecordDevicePoll(ctx context.Context, deviceCodeHash string, polledAt time.Time, pollInterval int) error
+	ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash string) (entity.DeviceAuthorization, error)
+	DeleteExpiredDeviceAuthorizations(ctx context.Context, expiredAt time.Time) error
+}
+
+type Mailer interface {
+	Send(ctx context.Context, mail entity.Mail) error
 }
 
 type Hasher interface {
@@ -32,15 +152,91 @@ type Hasher interface {
 }
 
 type TokenGenerator interface {
-	GenerateTokenPair(userEmail string, sessionUUID uuid.UUID) (entity.TokenPair, error)
-	ValidateAccessTokenAndGetEmail(signedToken string) (string, error)
+	GenerateTokenPair(user entity.AuthUser, session entity.Session, access entity.UserAccess) (entity.TokenPair, error)
+	ValidateAccessToken(signedToken string) (entity.AccessClaims, error)
 	ValidateRefreshTokenAndGetSessionUUID(signedToken string) (uuid.UUID, error)
+	GenerateClientToken(clientID string, scope []string, ttl time.Duration) (entity.Token, error)
+	GenerateIDToken(claims entity.IDTokenClaims) (string, error)
+	JWKS() []entity.JSONWebKey
+	SigningAlgorithm() string
+}
+
+type PasskeyUserFinder func(userID int64) (entity.AuthUser, []entity.PasskeyCredential, error)
+
+type PasskeyProvider interface {
+	BeginRegistration(user entity.AuthUser, passkeys []entity.PasskeyCredential) (entity.PasskeyChallenge, error)
+	FinishRegistration(user entity.AuthUser, passkeys []entity.PasskeyCredential,
+		sessionData []byte, response []byte) (entity.PasskeyCredential, error)
+	BeginLogin() (entity.PasskeyChallenge, error)
+	FinishLogin(sessionData []byte, response []byte, findUser PasskeyUserFinder) (entity.AuthUser, entity.PasskeyCredential, error)
 }
 
 type Authorization interface {
 	Register(ctx context.Context, user entity.AuthUser) error
-	Login(ctx context.Context, user entity.AuthUser, fingerprint string) (entity.TokenPair, error)
-	Validate(ctx context.Context, accessToken string) (string, error)
+	Login(ctx context.Context, user entity.AuthUser, fingerprint string) (entity.LoginResult, error)
+	VerifyMFA(ctx context.Context, mfaToken string, code string, fingerprint string) (entity.TokenPair, error)
+	Validate(ctx context.Context, accessToken string) (entity.AccessClaims, error)
 	Refresh(ctx context.Context, refreshToken string, fingerprint string) (entity.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	LogoutAll(ctx context.Context, accessToken string) error
+	ListSessions(ctx context.Context, accessToken string) ([]entity.Session, error)
+	RevokeSession(ctx context.Context, accessToken string, sessionID string) error
+	CreateAPIKey(ctx context.Context, accessToken string, key entity.APIKey) (entity.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, accessToken string) ([]entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, accessToken string, keyID string) error
+	ChangePassword(ctx context.Context, accessToken string, passwordChange entity.PasswordChange) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ConfirmPasswordReset(ctx context.Context, resetToken string, newPassword string) error
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	ResendVerification(ctx context.Context, email string) error
+	EnrollTOTP(ctx context.Context, accessToken string) (entity.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, accessToken string, code string) ([]string, error)
+	BeginPasskeyRegistration(ctx context.Context, accessToken string) (entity.PasskeyChallenge, error)
+	FinishPasskeyRegistration(ctx context.Context, accessToken string, ceremonyID string, credential []byte) error
+	BeginPasskeyLogin(ctx context.Context) (entity.PasskeyChallenge, error)
+	FinishPasskeyLogin(ctx context.Context, ceremonyID string, credential []byte, fingerprint string) (entity.TokenPair, error)
+	StartEmailLogin(ctx context.Context, email string, fingerprint string) error
+	CompleteEmailLogin(ctx context.Context, login entity.EmailLogin, fingerprint string) (entity.LoginResult, error)
+	SaveRole(ctx context.Context, accessToken string, role entity.Role) error
+	ListRoles(ctx context.Context, accessToken string) ([]entity.Role, error)
+	AssignRole(ctx context.Context, accessToken string, email string, role string) error
+	RevokeRole(ctx context.Context, accessToken string, email string, role string) error
+	CreateOrganization(ctx context.Context, accessToken string, name string) (entity.Organization, error)
+	ListOrganizations(ctx context.Context, accessToken string) ([]entity.Membership, error)
+	InviteMember(ctx context.Context, accessToken string, invitation entity.OrganizationInvitation) error
+	AcceptInvitation(ctx context.Context, accessToken string, invitationToken string) (entity.Membership, error)
+	SwitchOrganization(ctx context.Context, refreshToken string, fingerprint string, orgID int64) (entity.TokenPair, error)
+	GetJWKS(ctx context.Context) []entity.JSONWebKey
 	StartClearingExpiredSessions(ctx context.Context)
 }
+
+// Administration is user, audit and OAuth client management for operators. Credential is access token
+// of user with auth:users:manage (auth:audit:read for audit, auth:clients:manage for clients)
+// permission or static admin key.
+type Administration interface {
+	ListUsers(ctx context.Context, credential string, filter entity.UserFilter) (entity.UserPage, error)
+	GetUser(ctx context.Context, credential string, userID int64) (entity.AuthUser, []entity.Session, error)
+	SetUserStatus(ctx context.Context, credential string, userID int64, status entity.UserStatus) error
+	ForcePasswordReset(ctx context.Context, credential string, userID int64) error
+	DeleteUser(ctx context.Context, credential string, userID int64) error
+	RevokeUserSessions(ctx context.Context, credential string, userID int64) error
+	ListAuditEvents(ctx context.Context, credential string, filter entity.AuditFilter) (entity.AuditPage, error)
+	VerifyAuditLog(ctx context.Context, credential string) (entity.AuditVerification, error)
+	CreateOAuthClient(ctx context.Context, credential string, client entity.OAuthClient,
+		confidential bool) (entity.OAuthClient, string, error)
+	DeleteOAuthClient(ctx context.Context, credential string, clientID string) error
+}
+
+// OAuthAuthorization is OAuth 2.0 authorization code flow with PKCE for registered clients.
+type OAuthAuthorization interface {
+	ValidateAuthorizationRequest(ctx context.Context, accessToken string,
+		req entity.AuthorizationRequest) (entity.AuthorizationPrompt, error)
+	Authorize(ctx context.Context, accessToken string, req entity.AuthorizationRequest, approved bool) (string, error)
+	ExchangeOAuthToken(ctx context.Context, req entity.OAuthTokenRequest) (entity.OAuthTokens, error)
+	UserInfo(ctx context.Context, accessToken string) (entity.UserInfo, error)
+	OpenIDConfiguration(ctx context.Context) entity.OpenIDConfiguration
+	StartDeviceAuthorization(ctx context.Context, clientID string, clientSecret string,
+		scope string) (entity.DeviceAuthorizationStart, error)
+	LookupDeviceAuthorization(ctx context.Context, accessToken string, userCode string) (entity.AuthorizationPrompt, error)
+	ResolveDeviceAuthorization(ctx context.Context, accessToken string, userCode string, approved bool) error
+}
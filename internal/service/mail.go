@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"net/url"
+	"time"
+)
+
+const mailSendTimeout = time.Minute
+
+// sendMail delivers mail in background: response time must not depend on mail delivery,
+// otherwise it reveals whether account exists.
+func (s *AuthService) sendMail(ctx context.Context, method string, mail entity.Mail) {
+	reqUUID := requestUUID(ctx)
+
+	go func() {
+		sendCtx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if sendErr := s.mailer.Send(sendCtx, mail); sendErr != nil {
+			s.logger.Warnf(logPattern, reqUUID, method, fmt.Errorf("can't send mail: %w", sendErr))
+		}
+	}()
+}
+
+func linkWithToken(link string, token string) string {
+	u, parseErr := url.Parse(link)
+	if parseErr != nil {
+		return link + "?token=" + url.QueryEscape(token)
+	}
+
+	query := u.Query()
+	query.Set("token", token)
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func passwordResetMail(to string, link string, ttl time.Duration) entity.Mail {
+	return entity.Mail{
+		To:      to,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Someone requested a password reset for your account.\n\n"+
+			"To set a new password, follow the link within %s:\n%s\n\n"+
+			"If it wasn't you, ignore this mail: your password stays the same.", ttl, link),
+	}
+}
+
+func emailVerificationMail(to string, link string, ttl time.Duration) entity.Mail {
+	return entity.Mail{
+		To:      to,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Welcome!\n\n"+
+			"To confirm your email address, follow the link within %s:\n%s\n\n"+
+			"If you didn't create an account, ignore this mail.", ttl, link),
+	}
+}
+
+func accountExistsMail(to string) entity.Mail {
+	return entity.Mail{
+		To:      to,
+		Subject: "Registration attempt",
+		Body: "Someone tried to create an account with your email address, but you already have one.\n\n" +
+			"If it was you, log in or reset your password.\n" +
+			"If it wasn't you, ignore this mail: your account is safe.",
+	}
+}
+
+func emailLoginMail(to string, link string, code string, ttl time.Duration) entity.Mail {
+	return entity.Mail{
+		To:      to,
+		Subject: "Log in to your account",
+		Body: fmt.Sprintf("Someone requested to log in to your account.\n\n"+
+			"To log in, follow the link within %s:\n%s\n\n"+
+			"Or enter the code: %s\n\n"+
+			"The link and the code work only on the device where login was requested.\n"+
+			"If it wasn't you, ignore this mail.", ttl, link, code),
+	}
+}
+
+func invitationMail(to string, orgName string, link string, ttl time.Duration) entity.Mail {
+	return entity.Mail{
+		To:      to,
+		Subject: "Invitation to " + orgName,
+		Body: fmt.Sprintf("You were invited to join organization %s.\n\n"+
+			"To accept the invitation, log in with this email and follow the link within %s:\n%s\n\n"+
+			"If you don't know this organization, ignore this mail.", orgName, ttl, link),
+	}
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/secretbox"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"github.com/DmitySH/go-auth-service/pkg/totp"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const totpSkewSteps = 1
+
+// Recovery code is 10 random bytes: 16 base32 characters split into groups of 4.
+const (
+	recoveryCodeSize     = 10
+	recoveryCodeGroupLen = 4
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+func (s *AuthService) EnrollTOTP(ctx context.Context, accessToken string) (entity.TOTPEnrollment, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, enrollTOTPMethod)
+	if authErr != nil {
+		return entity.TOTPEnrollment{}, authErr
+	}
+
+	secret, generateSecretErr := totp.GenerateSecret()
+	if generateSecretErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), enrollTOTPMethod, generateSecretErr)
+		return entity.TOTPEnrollment{}, fmt.Errorf("can't generate totp secret: %w", generateSecretErr)
+	}
+
+	sealedSecret, sealErr := secretbox.Seal(s.cfg.MFA.SecretEncryptionKey, totpSecretOwner(user.ID), secret)
+	if sealErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), enrollTOTPMethod, sealErr)
+		return entity.TOTPEnrollment{}, fmt.Errorf("can't encrypt totp secret: %w", sealErr)
+	}
+
+	saveTOTPErr := s.repo.SaveUnconfirmedTOTP(ctx, user.ID, sealedSecret)
+	if errors.Is(saveTOTPErr, ErrTOTPConfirmed) {
+		s.logger.Printf(logPattern, requestUUID(ctx), enrollTOTPMethod, autherrors.MFAAlreadyEnabled)
+		return entity.TOTPEnrollment{}, autherrors.NewStatusError(autherrors.MFAAlreadyEnabled, nil)
+	}
+	if saveTOTPErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), enrollTOTPMethod, saveTOTPErr)
+		return entity.TOTPEnrollment{}, fmt.Errorf("can't save totp secret: %w", saveTOTPErr)
+	}
+
+	return entity.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.cfg.MFA.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once user proved that authenticator app
+// produces correct codes. It returns recovery codes, which are shown to user only once.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, accessToken string, code string) ([]string, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, confirmTOTPMethod)
+	if authErr != nil {
+		return nil, authErr
+	}
+
+	userTOTP, getTOTPErr := s.repo.GetUserTOTP(ctx, user.ID)
+	if errors.Is(getTOTPErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), confirmTOTPMethod, autherrors.MFANotEnrolled)
+		return nil, autherrors.NewStatusError(autherrors.MFANotEnrolled, nil)
+	}
+	if getTOTPErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmTOTPMethod, getTOTPErr)
+		return nil, fmt.Errorf("can't get totp: %w", getTOTPErr)
+	}
+	if userTOTP.Enabled() {
+		s.logger.Printf(logPattern, requestUUID(ctx), confirmTOTPMethod, autherrors.MFAAlreadyEnabled)
+		return nil, autherrors.NewStatusError(autherrors.MFAAlreadyEnabled, nil)
+	}
+
+	secret, openSecretErr := s.openTOTPSecret(ctx, userTOTP)
+	if openSecretErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmTOTPMethod, openSecretErr)
+		return nil, fmt.Errorf("can't read totp secret: %w", openSecretErr)
+	}
+
+	step, valid := totp.Validate(secret, code, time.Now(), totpSkewSteps)
+	if !valid {
+		s.logger.Printf(logPattern, requestUUID(ctx), confirmTOTPMethod, autherrors.InvalidMFACode)
+		return nil, autherrors.NewStatusError(autherrors.InvalidMFACode, nil)
+	}
+
+	recoveryCodes, codeHashes, generateCodesErr := generateRecoveryCodes(s.cfg.MFA.RecoveryCodesCount)
+	if generateCodesErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmTOTPMethod, generateCodesErr)
+		return nil, fmt.Errorf("can't generate recovery codes: %w", generateCodesErr)
+	}
+	if replaceCodesErr := s.repo.ReplaceRecoveryCodes(ctx, user.ID, codeHashes); replaceCodesErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmTOTPMethod, replaceCodesErr)
+		return nil, fmt.Errorf("can't save recovery codes: %w", replaceCodesErr)
+	}
+
+	confirmErr := s.repo.ConfirmUserTOTP(ctx, user.ID, step, time.Now())
+	if errors.Is(confirmErr, ErrTOTPConfirmed) {
+		s.logger.Printf(logPattern, requestUUID(ctx), confirmTOTPMethod, autherrors.MFAAlreadyEnabled)
+		return nil, autherrors.NewStatusError(autherrors.MFAAlreadyEnabled, nil)
+	}
+	if confirmErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmTOTPMethod, confirmErr)
+		return nil, fmt.Errorf("can't confirm totp: %w", confirmErr)
+	}
+
+	return recoveryCodes, nil
+}
+
+// VerifyMFA exchanges challenge token issued by Login and second factor code for token pair.
+// Challenge token is single-use: after wrong code client has to log in again. Wrong codes are
+// counted per user apart from password failures, so passing password again doesn't reset them.
+func (s *AuthService) VerifyMFA(ctx context.Context, mfaToken string, code string, fingerprint string) (entity.TokenPair, error) {
+	fingerprintUUID, parseFingerprintErr := uuid.Parse(fingerprint)
+	if parseFingerprintErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), verifyMFAMethod, autherrors.InvalidFingerprint)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidFingerprint, nil)
+	}
+
+	// Challenge is looked up before throttle check and used after it, so refused attempt doesn't burn it.
+	challenge, getChallengeErr := s.repo.GetOneTimeToken(ctx, securetoken.Hash(mfaToken),
+		entity.MFAChallengePurpose, time.Now())
+	if errors.Is(getChallengeErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), verifyMFAMethod, autherrors.InvalidToken)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("mfa token is unknown, used or expired"))
+	}
+	if getChallengeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), verifyMFAMethod, getChallengeErr)
+		return entity.TokenPair{}, fmt.Errorf("can't get mfa token: %w", getChallengeErr)
+	}
+
+	attempt, throttleErr := s.reserveUserAttempt(ctx, mfaAttemptPrefix, challenge.UserID,
+		s.cfg.LoginThrottle.LockoutThreshold, verifyMFAMethod)
+	if throttleErr != nil {
+		return entity.TokenPair{}, throttleErr
+	}
+
+	_, consumeErr := s.repo.ConsumeOneTimeToken(ctx, securetoken.Hash(mfaToken), entity.MFAChallengePurpose, time.Now())
+	if errors.Is(consumeErr, ErrEntityNotFound) {
+		s.releaseLoginAttempt(ctx, attempt)
+		s.logger.Printf(logPattern, requestUUID(ctx), verifyMFAMethod, autherrors.InvalidToken)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("mfa token is unknown, used or expired"))
+	}
+	if consumeErr != nil {
+		s.releaseLoginAttempt(ctx, attempt)
+		s.logger.Warnf(logPattern, requestUUID(ctx), verifyMFAMethod, consumeErr)
+		return entity.TokenPair{}, fmt.Errorf("can't use mfa token: %w", consumeErr)
+	}
+
+	user, getUserErr := s.repo.GetUserByID(ctx, challenge.UserID)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), verifyMFAMethod, autherrors.UserNotExists)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.UserNotExists, nil)
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), verifyMFAMethod, getUserErr)
+		return entity.TokenPair{}, fmt.Errorf("can't get user: %w", getUserErr)
+	}
+
+	valid, checkCodeErr := s.checkSecondFactor(ctx, user.ID, code)
+	if checkCodeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), verifyMFAMethod, checkCodeErr)
+		return entity.TokenPair{}, fmt.Errorf("can't check mfa code: %w", checkCodeErr)
+	}
+	if !valid {
+		s.logger.Printf(logPattern, requestUUID(ctx), verifyMFAMethod, autherrors.InvalidMFACode)
+		s.recordAudit(ctx, entity.AuditLoginFailure, user.Email, user.ID, verifyMFAMethod,
+			autherrors.NewStatusError(autherrors.InvalidMFACode, nil))
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidMFACode, nil)
+	}
+	s.resetUserFailures(ctx, mfaAttemptPrefix, user.ID)
+
+	return s.startSession(ctx, user, fingerprintUUID, verifyMFAMethod)
+}
+
+func (s *AuthService) isMFAEnabled(ctx context.Context, userID int64) (bool, error) {
+	userTOTP, getTOTPErr := s.repo.GetUserTOTP(ctx, userID)
+	if errors.Is(getTOTPErr, ErrEntityNotFound) {
+		return false, nil
+	}
+	if getTOTPErr != nil {
+		return false, getTOTPErr
+	}
+
+	return userTOTP.Enabled(), nil
+}
+
+// checkSecondFactor accepts either current TOTP code or unused recovery code.
+func (s *AuthService) checkSecondFactor(ctx context.Context, userID int64, code string) (bool, error) {
+	userTOTP, getTOTPErr := s.repo.GetUserTOTP(ctx, userID)
+	if getTOTPErr != nil {
+		return false, getTOTPErr
+	}
+
+	secret, openSecretErr := s.openTOTPSecret(ctx, userTOTP)
+	if openSecretErr != nil {
+		return false, openSecretErr
+	}
+
+	if step, valid := totp.Validate(secret, code, time.Now(), totpSkewSteps); valid {
+		useStepErr := s.repo.UseTOTPStep(ctx, userID, step)
+		if errors.Is(useStepErr, ErrTOTPCodeUsed) {
+			return false, nil
+		}
+		if useStepErr != nil {
+			return false, useStepErr
+		}
+
+		return true, nil
+	}
+
+	useCodeErr := s.repo.UseRecoveryCode(ctx, userID, securetoken.Hash(normalizeRecoveryCode(code)), time.Now())
+	if errors.Is(useCodeErr, ErrEntityNotFound) {
+		return false, nil
+	}
+	if useCodeErr != nil {
+		return false, useCodeErr
+	}
+
+	return true, nil
+}
+
+// openTOTPSecret decrypts user's secret. Secret stored before encryption is encrypted
+// on the way, as KeyRotator does with signing keys.
+func (s *AuthService) openTOTPSecret(ctx context.Context, userTOTP entity.UserTOTP) (string, error) {
+	if userTOTP.Encrypted {
+		return secretbox.Open(s.cfg.MFA.SecretEncryptionKey, totpSecretOwner(userTOTP.UserID), userTOTP.Secret)
+	}
+
+	sealedSecret, sealErr := secretbox.Seal(s.cfg.MFA.SecretEncryptionKey, totpSecretOwner(userTOTP.UserID), userTOTP.Secret)
+	if sealErr != nil {
+		return "", fmt.Errorf("can't encrypt totp secret: %w", sealErr)
+	}
+	if encryptErr := s.repo.EncryptUserTOTP(ctx, userTOTP.UserID, sealedSecret); encryptErr != nil {
+		return "", fmt.Errorf("can't save encrypted totp secret: %w", encryptErr)
+	}
+
+	return userTOTP.Secret, nil
+}
+
+// totpSecretOwner binds sealed secret to user, so it can't be copied to another account.
+func totpSecretOwner(userID int64) string {
+	return "totp:" + strconv.FormatInt(userID, 10)
+}
+
+// generateRecoveryCodes returns readable codes for user and their hashes for storage.
+func generateRecoveryCodes(count int) ([]string, []string, error) {
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		b := make([]byte, recoveryCodeSize)
+		if _, readErr := rand.Read(b); readErr != nil {
+			return nil, nil, fmt.Errorf("can't read random bytes: %w", readErr)
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+
+		groups := make([]string, 0, len(code)/recoveryCodeGroupLen)
+		for start := 0; start < len(code); start += recoveryCodeGroupLen {
+			groups = append(groups, code[start:start+recoveryCodeGroupLen])
+		}
+
+		codes = append(codes, strings.Join(groups, "-"))
+		hashes = append(hashes, securetoken.Hash(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// completeFirstFactor starts session after user passed the first factor, or issues
+// MFA challenge token when user has two-factor authentication enabled.
+func (s *AuthService) completeFirstFactor(ctx context.Context, user entity.AuthUser,
+	fingerprint uuid.UUID, method string) (entity.LoginResult, error) {
+	if checkStatusErr := s.checkUserStatus(ctx, user, method); checkStatusErr != nil {
+		s.recordAudit(ctx, entity.AuditLoginFailure, user.Email, user.ID, method, checkStatusErr)
+		return entity.LoginResult{}, checkStatusErr
+	}
+
+	mfaRequired, checkMFAErr := s.isMFAEnabled(ctx, user.ID)
+	if checkMFAErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, checkMFAErr)
+		return entity.LoginResult{}, fmt.Errorf("can't check two-factor authentication: %w", checkMFAErr)
+	}
+	if mfaRequired {
+		mfaToken, issueTokenErr := s.issueOneTimeToken(ctx, user.ID, entity.MFAChallengePurpose, s.cfg.MFA.ChallengeTTL)
+		if issueTokenErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), method, issueTokenErr)
+			return entity.LoginResult{}, fmt.Errorf("can't issue mfa challenge token: %w", issueTokenErr)
+		}
+
+		return entity.LoginResult{
+			MFARequired: true,
+			MFAToken:    entity.Token{Token: mfaToken, ExpiresAt: time.Now().Add(s.cfg.MFA.ChallengeTTL)},
+		}, nil
+	}
+
+	tokenPair, startSessionErr := s.startSession(ctx, user, fingerprint, method)
+	if startSessionErr != nil {
+		return entity.LoginResult{}, startSessionErr
+	}
+
+	return entity.LoginResult{Tokens: tokenPair}, nil
+}
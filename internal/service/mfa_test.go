@@ -0,0 +1,163 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/pkg/totp"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testMFA = service.MFAConfig{
+	Issuer:              testIssuer,
+	ChallengeTTL:        time.Minute,
+	RecoveryCodesCount:  2,
+	SecretEncryptionKey: bytes.Repeat([]byte{7}, 32),
+}
+
+// enableTOTP enrolls authenticator app of user and returns its secret and recovery codes.
+func (f throttleFixture) enableTOTP(t *testing.T, user entity.AuthUser) (string, []string) {
+	t.Helper()
+
+	ctx := context.Background()
+	accessToken := loginAs(t, f.tokenGenerator, f.repo, user)
+	enrollment, enrollErr := f.svc.EnrollTOTP(ctx, accessToken)
+	if enrollErr != nil {
+		t.Fatalf("EnrollTOTP: %v", enrollErr)
+	}
+	recoveryCodes, confirmErr := f.svc.ConfirmTOTP(ctx, accessToken, totpCode(t, enrollment.Secret, 0))
+	if confirmErr != nil {
+		t.Fatalf("ConfirmTOTP: %v", confirmErr)
+	}
+
+	return enrollment.Secret, recoveryCodes
+}
+
+// totpCode returns code of time step that is shift steps away from now.
+func totpCode(t *testing.T, secret string, shift int64) string {
+	t.Helper()
+
+	code, codeErr := totp.Code(secret, totp.Step(time.Now())+shift)
+	if codeErr != nil {
+		t.Fatalf("can't generate totp code: %v", codeErr)
+	}
+
+	return code
+}
+
+// wrongTOTPCode returns six digits that no step within skew accepts.
+func wrongTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	valid := map[string]bool{}
+	for shift := int64(-2); shift <= 2; shift++ {
+		valid[totpCode(t, secret, shift)] = true
+	}
+	for _, code := range []string{"000000", "111111", "222222", "333333", "444444", "555555"} {
+		if !valid[code] {
+			return code
+		}
+	}
+	t.Fatalf("can't pick wrong totp code")
+
+	return ""
+}
+
+func (f throttleFixture) mfaChallenge(t *testing.T, email string) string {
+	t.Helper()
+
+	result, loginErr := f.login(fromIP("10.0.0.1"), email, testPassword)
+	if loginErr != nil {
+		t.Fatalf("Login: %v", loginErr)
+	}
+	if !result.MFARequired {
+		t.Fatalf("login doesn't ask for second factor")
+	}
+
+	return result.MFAToken.Token
+}
+
+func (f throttleFixture) verifyMFA(t *testing.T, email string, code string) error {
+	t.Helper()
+
+	_, verifyErr := f.svc.VerifyMFA(fromIP("10.0.0.1"), f.mfaChallenge(t, email), code, uuid.NewString())
+
+	return verifyErr
+}
+
+func TestVerifyMFARejectsReplayedStep(t *testing.T) {
+	user := entity.AuthUser{ID: 1, Email: "user@example.com"}
+	f := newThrottleFixture(t, testThrottle, user)
+	secret, _ := f.enableTOTP(t, user)
+
+	if verifyErr := f.verifyMFA(t, user.Email, totpCode(t, secret, 0)); !autherrors.Is(verifyErr, autherrors.InvalidMFACode) {
+		t.Fatalf("code used to confirm enrollment is accepted again: %v", verifyErr)
+	}
+
+	code := totpCode(t, secret, 1)
+	if verifyErr := f.verifyMFA(t, user.Email, code); verifyErr != nil {
+		t.Fatalf("VerifyMFA: %v", verifyErr)
+	}
+	if verifyErr := f.verifyMFA(t, user.Email, code); !autherrors.Is(verifyErr, autherrors.InvalidMFACode) {
+		t.Fatalf("replayed code is accepted: %v", verifyErr)
+	}
+}
+
+func TestRecoveryCodeIsSingleUse(t *testing.T) {
+	user := entity.AuthUser{ID: 1, Email: "user@example.com"}
+	f := newThrottleFixture(t, testThrottle, user)
+	_, recoveryCodes := f.enableTOTP(t, user)
+	if len(recoveryCodes) != testMFA.RecoveryCodesCount {
+		t.Fatalf("got %d recovery codes, want %d", len(recoveryCodes), testMFA.RecoveryCodesCount)
+	}
+
+	// Code is typed as user read it: case and separators don't matter.
+	typed := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", " "))
+	if verifyErr := f.verifyMFA(t, user.Email, typed); verifyErr != nil {
+		t.Fatalf("VerifyMFA with recovery code: %v", verifyErr)
+	}
+	if verifyErr := f.verifyMFA(t, user.Email, recoveryCodes[0]); !autherrors.Is(verifyErr, autherrors.InvalidMFACode) {
+		t.Fatalf("recovery code is accepted twice: %v", verifyErr)
+	}
+	if verifyErr := f.verifyMFA(t, user.Email, recoveryCodes[1]); verifyErr != nil {
+		t.Fatalf("other recovery code is used up: %v", verifyErr)
+	}
+}
+
+func TestTOTPSecretIsEncrypted(t *testing.T) {
+	user := entity.AuthUser{ID: 1, Email: "user@example.com"}
+	legacyUser := entity.AuthUser{ID: 2, Email: "legacy@example.com"}
+	f := newThrottleFixture(t, testThrottle, user, legacyUser)
+	secret, _ := f.enableTOTP(t, user)
+
+	stored, _ := f.repo.GetUserTOTP(context.Background(), user.ID)
+	if !stored.Encrypted || strings.Contains(stored.Secret, secret) {
+		t.Fatalf("totp secret is stored in plaintext: %+v", stored)
+	}
+
+	// Secret stored before encryption still works and is encrypted once read.
+	legacySecret, _ := totp.GenerateSecret()
+	confirmedAt := time.Now()
+	f.repo.totps[legacyUser.ID] = entity.UserTOTP{UserID: legacyUser.ID, Secret: legacySecret, ConfirmedAt: &confirmedAt}
+	if verifyErr := f.verifyMFA(t, legacyUser.Email, totpCode(t, legacySecret, 0)); verifyErr != nil {
+		t.Fatalf("VerifyMFA with plaintext secret: %v", verifyErr)
+	}
+	stored, _ = f.repo.GetUserTOTP(context.Background(), legacyUser.ID)
+	if !stored.Encrypted || stored.Secret == legacySecret {
+		t.Fatalf("plaintext secret isn't encrypted after use: %+v", stored)
+	}
+	if verifyErr := f.verifyMFA(t, legacyUser.Email, totpCode(t, legacySecret, 1)); verifyErr != nil {
+		t.Fatalf("VerifyMFA with encrypted legacy secret: %v", verifyErr)
+	}
+
+	// Sealed secret is bound to its user.
+	f.repo.totps[user.ID] = entity.UserTOTP{UserID: user.ID, Secret: stored.Secret, Encrypted: true, ConfirmedAt: &confirmedAt}
+	if verifyErr := f.verifyMFA(t, user.Email, totpCode(t, legacySecret, 1)); verifyErr == nil {
+		t.Fatalf("secret sealed for another user is accepted")
+	}
+}
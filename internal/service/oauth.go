@@ -0,0 +1,540 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"github.com/google/uuid"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxOAuthClientNameLength = 128
+
+const (
+	minPKCEValueLength = 43
+	maxPKCEValueLength = 128
+)
+
+const maxNonceLength = 512
+
+const accessDeniedError = "access_denied"
+
+// oauthFingerprintNamespace derives session fingerprints of OAuth clients, which don't send their own.
+var oauthFingerprintNamespace = uuid.MustParse("d4d7ba3b-45c2-429c-a24e-b7e0edfb40e7")
+
+// CreateOAuthClient registers client and returns it with secret. Secret is generated only
+// for confidential clients and can't be seen again. Client with scope is machine client:
+// it may get tokens for itself with client credentials grant, so it must be confidential.
+func (s *AuthService) CreateOAuthClient(ctx context.Context, credential string, client entity.OAuthClient,
+	confidential bool) (entity.OAuthClient, string, error) {
+	var secret string
+	details := fmt.Sprintf("client %q", client.Name)
+	actionErr := s.runAdminAction(ctx, credential, entity.ManageClientsPermission, createOAuthClientMethod, 0, details, func() error {
+		client.Name = strings.TrimSpace(client.Name)
+		if client.Name == "" || utf8.RuneCountInString(client.Name) > maxOAuthClientNameLength ||
+			len(client.RedirectURIs) == 0 && client.Scope == "" || client.Scope != "" && !confidential {
+			s.logger.Printf(logPattern, requestUUID(ctx), createOAuthClientMethod, autherrors.InvalidOAuthClient)
+			return autherrors.NewStatusError(autherrors.InvalidOAuthClient, nil)
+		}
+		scope, normalizeErr := normalizePermissions(strings.Fields(client.Scope))
+		if normalizeErr != nil {
+			s.logger.Printf(logPattern, requestUUID(ctx), createOAuthClientMethod, autherrors.InvalidOAuthClient)
+			return autherrors.NewStatusError(autherrors.InvalidOAuthClient, normalizeErr)
+		}
+		client.Scope = strings.Join(scope, " ")
+		for _, uri := range client.RedirectURIs {
+			if validateURIErr := validateRedirectURI(uri); validateURIErr != nil {
+				s.logger.Printf(logPattern, requestUUID(ctx), createOAuthClientMethod, autherrors.InvalidOAuthClient)
+				return autherrors.NewStatusError(autherrors.InvalidOAuthClient, validateURIErr)
+			}
+		}
+
+		client.ID = uuid.NewString()
+		client.CreatedAt = time.Now()
+		if confidential {
+			var generateSecretErr error
+			secret, generateSecretErr = securetoken.Generate(securetoken.DefaultSize)
+			if generateSecretErr != nil {
+				s.logger.Warnf(logPattern, requestUUID(ctx), createOAuthClientMethod, generateSecretErr)
+				return fmt.Errorf("can't generate client secret: %w", generateSecretErr)
+			}
+			secretHash := securetoken.Hash(secret)
+			client.SecretHash = &secretHash
+		}
+
+		if createClientErr := s.repo.CreateOAuthClient(ctx, client); createClientErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), createOAuthClientMethod, createClientErr)
+			return fmt.Errorf("can't create oauth client: %w", createClientErr)
+		}
+
+		return nil
+	})
+
+	return client, secret, actionErr
+}
+
+// DeleteOAuthClient deletes client with its sessions, consents and authorization codes.
+func (s *AuthService) DeleteOAuthClient(ctx context.Context, credential string, clientID string) error {
+	return s.runAdminAction(ctx, credential, entity.ManageClientsPermission, deleteOAuthClientMethod, 0, "client "+clientID, func() error {
+		deleteClientErr := s.repo.DeleteOAuthClient(ctx, clientID)
+		if errors.Is(deleteClientErr, ErrEntityNotFound) {
+			s.logger.Printf(logPattern, requestUUID(ctx), deleteOAuthClientMethod, autherrors.OAuthClientNotExists)
+			return autherrors.NewStatusError(autherrors.OAuthClientNotExists, nil)
+		}
+		if deleteClientErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), deleteOAuthClientMethod, deleteClientErr)
+			return fmt.Errorf("can't delete oauth client: %w", deleteClientErr)
+		}
+		s.clientCache.Delete(clientID)
+
+		return nil
+	})
+}
+
+// ValidateAuthorizationRequest checks request to authorization endpoint and describes it for consent page.
+// With access token it also tells whether user already gave consent.
+func (s *AuthService) ValidateAuthorizationRequest(ctx context.Context, accessToken string,
+	req entity.AuthorizationRequest) (entity.AuthorizationPrompt, error) {
+	client, scope, checkReqErr := s.checkAuthorizationRequest(ctx, req, validateAuthorizationMethod)
+	if checkReqErr != nil {
+		return entity.AuthorizationPrompt{}, checkReqErr
+	}
+
+	prompt := entity.AuthorizationPrompt{ClientName: client.Name, Scope: scope}
+	if accessToken == "" {
+		return prompt, nil
+	}
+
+	user, _, authErr := s.authenticate(ctx, accessToken, validateAuthorizationMethod)
+	if authErr != nil {
+		return entity.AuthorizationPrompt{}, authErr
+	}
+
+	consent, getConsentErr := s.repo.GetOAuthConsent(ctx, user.ID, client.ID)
+	if errors.Is(getConsentErr, ErrEntityNotFound) {
+		return prompt, nil
+	}
+	if getConsentErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), validateAuthorizationMethod, getConsentErr)
+		return entity.AuthorizationPrompt{}, fmt.Errorf("can't get consent: %w", getConsentErr)
+	}
+	prompt.ConsentGranted = scopeCovers(consent.Scope, scope)
+
+	return prompt, nil
+}
+
+// Authorize records user's decision on authorization request and returns URI to redirect user back
+// to client with authorization code, or with access_denied error if user declined.
+func (s *AuthService) Authorize(ctx context.Context, accessToken string, req entity.AuthorizationRequest,
+	approved bool) (string, error) {
+	client, scope, checkReqErr := s.checkAuthorizationRequest(ctx, req, authorizeMethod)
+	if checkReqErr != nil {
+		return "", checkReqErr
+	}
+
+	user, claims, authErr := s.authenticate(ctx, accessToken, authorizeMethod)
+	if authErr != nil {
+		return "", authErr
+	}
+
+	if !approved {
+		return redirectWithQuery(req.RedirectURI, map[string]string{"error": accessDeniedError, "state": req.State})
+	}
+
+	if grantConsentErr := s.grantConsent(ctx, user.ID, client.ID, scope, authorizeMethod); grantConsentErr != nil {
+		return "", grantConsentErr
+	}
+
+	code, generateCodeErr := securetoken.Generate(securetoken.DefaultSize)
+	if generateCodeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), authorizeMethod, generateCodeErr)
+		return "", fmt.Errorf("can't generate authorization code: %w", generateCodeErr)
+	}
+	createCodeErr := s.repo.CreateAuthorizationCode(ctx, entity.AuthorizationCode{
+		CodeHash:      securetoken.Hash(code),
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         scope,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.cfg.OAuth.CodeTTL),
+		Nonce:         req.Nonce,
+		AuthTime:      claims.AuthTime,
+		AMR:           strings.Join(claims.AMR, " "),
+	})
+	if createCodeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), authorizeMethod, createCodeErr)
+		return "", fmt.Errorf("can't save authorization code: %w", createCodeErr)
+	}
+	s.recordAudit(ctx, entity.AuditOAuthAuthorize, user.Email, user.ID, "client "+client.ID+" scope "+scope, nil)
+
+	return redirectWithQuery(req.RedirectURI, map[string]string{"code": code, "state": req.State})
+}
+
+// ExchangeOAuthToken is token endpoint: it issues tokens for authorization code, refresh token,
+// client credentials or device code. Refresh token can be used only by the client it was issued to.
+func (s *AuthService) ExchangeOAuthToken(ctx context.Context, req entity.OAuthTokenRequest) (entity.OAuthTokens, error) {
+	client, authClientErr := s.authenticateOAuthClient(ctx, req.ClientID, req.ClientSecret, exchangeOAuthTokenMethod)
+	if authClientErr != nil {
+		return entity.OAuthTokens{}, authClientErr
+	}
+
+	switch req.GrantType {
+	case entity.AuthorizationCodeGrant:
+		return s.exchangeAuthorizationCode(ctx, client, req)
+	case entity.RefreshTokenGrant:
+		tokenPair, rotateErr := s.rotateSession(ctx, req.RefreshToken, clientFingerprint(client.ID).String(),
+			nil, client.ID, exchangeOAuthTokenMethod)
+		if rotateErr != nil {
+			return entity.OAuthTokens{}, rotateErr
+		}
+
+		return entity.OAuthTokens{TokenPair: tokenPair}, nil
+	case entity.ClientCredentialsGrant:
+		return s.issueClientToken(ctx, client, req.Scope)
+	case entity.DeviceCodeGrant:
+		return s.exchangeDeviceCode(ctx, client, req.DeviceCode)
+	default:
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.UnsupportedGrantType)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.UnsupportedGrantType, nil)
+	}
+}
+
+func (s *AuthService) exchangeAuthorizationCode(ctx context.Context, client entity.OAuthClient,
+	req entity.OAuthTokenRequest) (entity.OAuthTokens, error) {
+	code, consumeCodeErr := s.repo.ConsumeAuthorizationCode(ctx, securetoken.Hash(req.Code), time.Now())
+	if errors.Is(consumeCodeErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.InvalidGrant)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.InvalidGrant, nil)
+	}
+	if consumeCodeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, consumeCodeErr)
+		return entity.OAuthTokens{}, fmt.Errorf("can't consume authorization code: %w", consumeCodeErr)
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != req.RedirectURI {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.InvalidGrant)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.InvalidGrant,
+			errors.New("code was issued to another client or redirect uri"))
+	}
+	if !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.InvalidGrant)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.InvalidGrant,
+			errors.New("code verifier doesn't match challenge"))
+	}
+
+	user, getUserErr := s.getUserByID(ctx, code.UserID, exchangeOAuthTokenMethod)
+	if getUserErr != nil {
+		return entity.OAuthTokens{}, getUserErr
+	}
+
+	clientID := client.ID
+	tokenPair, openSessionErr := s.openSession(ctx, user, entity.Session{
+		Fingerprint: clientFingerprint(client.ID),
+		ClientID:    &clientID,
+		Scope:       code.Scope,
+		AuthTime:    code.AuthTime,
+		AMR:         code.AMR,
+	}, exchangeOAuthTokenMethod)
+	if openSessionErr != nil {
+		return entity.OAuthTokens{}, openSessionErr
+	}
+
+	tokens := entity.OAuthTokens{TokenPair: tokenPair, Scope: code.Scope}
+	if scopeCovers(code.Scope, entity.OpenIDScope) {
+		idToken, generateIDTokenErr := s.generateIDToken(user, client.ID, code.Nonce, code.AuthTime, code.AMR)
+		if generateIDTokenErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, generateIDTokenErr)
+			return entity.OAuthTokens{}, fmt.Errorf("can't generate id token: %w", generateIDTokenErr)
+		}
+		tokens.IDToken = idToken
+	}
+
+	return tokens, nil
+}
+
+// issueClientToken gives machine client access token for requested part of its scope, or for all of it
+// if scope isn't requested. No session is started and no refresh token is issued: client authenticates again.
+func (s *AuthService) issueClientToken(ctx context.Context, client entity.OAuthClient,
+	requestedScope string) (entity.OAuthTokens, error) {
+	if client.Scope == "" {
+		s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.UnauthorizedClient)
+		return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.UnauthorizedClient, nil)
+	}
+
+	scope := client.Scope
+	if requestedScope != "" {
+		normalized, normalizeErr := normalizePermissions(strings.Fields(requestedScope))
+		if normalizeErr != nil || !scopeCovers(client.Scope, requestedScope) {
+			s.logger.Printf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, autherrors.InvalidScope)
+			return entity.OAuthTokens{}, autherrors.NewStatusError(autherrors.InvalidScope, normalizeErr)
+		}
+		scope = strings.Join(normalized, " ")
+	}
+
+	token, generateTokenErr := s.tokenGenerator.GenerateClientToken(client.ID, strings.Fields(scope), s.cfg.OAuth.ClientTokenTTL)
+	if generateTokenErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), exchangeOAuthTokenMethod, generateTokenErr)
+		return entity.OAuthTokens{}, fmt.Errorf("can't generate client token: %w", generateTokenErr)
+	}
+	s.recordAudit(ctx, entity.AuditClientToken, clientActor(client.ID), 0, "scope "+scope, nil)
+
+	return entity.OAuthTokens{TokenPair: entity.TokenPair{Access: token}, Scope: scope}, nil
+}
+
+// isClientActive tells whether client of machine token wasn't deleted.
+func (s *AuthService) isClientActive(ctx context.Context, clientID string) (bool, error) {
+	if active, cached := s.clientCache.Get(clientID); cached {
+		return active, nil
+	}
+
+	_, getClientErr := s.repo.GetOAuthClient(ctx, clientID)
+	if getClientErr != nil && !errors.Is(getClientErr, ErrEntityNotFound) {
+		return false, getClientErr
+	}
+	active := getClientErr == nil
+	s.clientCache.Set(clientID, active)
+
+	return active, nil
+}
+
+// grantConsent adds scope to consent user gave to client before.
+func (s *AuthService) grantConsent(ctx context.Context, userID int64, clientID string, scope string,
+	method string) error {
+	consentScope := scope
+	consent, getConsentErr := s.repo.GetOAuthConsent(ctx, userID, clientID)
+	if getConsentErr != nil && !errors.Is(getConsentErr, ErrEntityNotFound) {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getConsentErr)
+		return fmt.Errorf("can't get consent: %w", getConsentErr)
+	}
+	if getConsentErr == nil {
+		consentScope = mergeScopes(consent.Scope, scope)
+	}
+
+	saveConsentErr := s.repo.SaveOAuthConsent(ctx, entity.OAuthConsent{
+		UserID:    userID,
+		ClientID:  clientID,
+		Scope:     consentScope,
+		GrantedAt: time.Now(),
+	})
+	if saveConsentErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, saveConsentErr)
+		return fmt.Errorf("can't save consent: %w", saveConsentErr)
+	}
+
+	return nil
+}
+
+// checkAuthorizationRequest validates client and redirect URI first: until they are valid,
+// errors can't be reported to client by redirect. Returns normalized scope.
+func (s *AuthService) checkAuthorizationRequest(ctx context.Context, req entity.AuthorizationRequest,
+	method string) (entity.OAuthClient, string, error) {
+	client, getClientErr := s.repo.GetOAuthClient(ctx, req.ClientID)
+	if errors.Is(getClientErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.OAuthClientNotExists)
+		return entity.OAuthClient{}, "", autherrors.NewStatusError(autherrors.OAuthClientNotExists, nil)
+	}
+	if getClientErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getClientErr)
+		return entity.OAuthClient{}, "", fmt.Errorf("can't get oauth client: %w", getClientErr)
+	}
+	if !client.HasRedirectURI(req.RedirectURI) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidRedirectURI)
+		return entity.OAuthClient{}, "", autherrors.NewStatusError(autherrors.InvalidRedirectURI, nil)
+	}
+
+	if req.ResponseType != entity.CodeResponseType {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.UnsupportedResponseType)
+		return entity.OAuthClient{}, "", autherrors.NewStatusError(autherrors.UnsupportedResponseType, nil)
+	}
+	if req.CodeChallengeMethod != entity.PKCEMethodS256 || !isValidPKCEValue(req.CodeChallenge) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidCodeChallenge)
+		return entity.OAuthClient{}, "", autherrors.NewStatusError(autherrors.InvalidCodeChallenge, nil)
+	}
+	if len(req.Nonce) > maxNonceLength {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidNonce)
+		return entity.OAuthClient{}, "", autherrors.NewStatusError(autherrors.InvalidNonce, nil)
+	}
+
+	scope, normalizeErr := normalizePermissions(strings.Fields(req.Scope))
+	if normalizeErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidScope)
+		return entity.OAuthClient{}, "", autherrors.NewStatusError(autherrors.InvalidScope, normalizeErr)
+	}
+	if checkScopeErr := s.checkOpenIDScope(ctx, strings.Join(scope, " "), method); checkScopeErr != nil {
+		return entity.OAuthClient{}, "", checkScopeErr
+	}
+
+	return client, strings.Join(scope, " "), nil
+}
+
+// authenticateOAuthClient checks secret of confidential client. Public clients are identified
+// by client ID only, their code exchange is protected by PKCE.
+func (s *AuthService) authenticateOAuthClient(ctx context.Context, clientID string,
+	secret string, method string) (entity.OAuthClient, error) {
+	client, getClientErr := s.repo.GetOAuthClient(ctx, clientID)
+	if errors.Is(getClientErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidClientCredentials)
+		return entity.OAuthClient{}, autherrors.NewStatusError(autherrors.InvalidClientCredentials, nil)
+	}
+	if getClientErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getClientErr)
+		return entity.OAuthClient{}, fmt.Errorf("can't get oauth client: %w", getClientErr)
+	}
+
+	if client.IsConfidential() &&
+		subtle.ConstantTimeCompare([]byte(securetoken.Hash(secret)), []byte(*client.SecretHash)) != 1 {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidClientCredentials)
+		return entity.OAuthClient{}, autherrors.NewStatusError(autherrors.InvalidClientCredentials, nil)
+	}
+
+	return client, nil
+}
+
+// validateRedirectURI accepts absolute URIs without fragment. Plain http is allowed only for
+// loopback. Other schemes must be private-use schemes of native apps, which are reverse domain
+// names (RFC 8252, section 7.1), so javascript:, data: and the like are rejected.
+func validateRedirectURI(redirectURI string) error {
+	u, parseErr := url.Parse(redirectURI)
+	if parseErr != nil {
+		return fmt.Errorf("can't parse %q: %w", redirectURI, parseErr)
+	}
+	if !u.IsAbs() {
+		return fmt.Errorf("%q isn't absolute", redirectURI)
+	}
+	if u.Fragment != "" {
+		return fmt.Errorf("%q has fragment", redirectURI)
+	}
+
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("%q has no host", redirectURI)
+		}
+	case "http":
+		if !isLoopbackHost(u.Hostname()) {
+			return fmt.Errorf("%q uses http for non-loopback host", redirectURI)
+		}
+	default:
+		if !strings.Contains(u.Scheme, ".") {
+			return fmt.Errorf("%q uses scheme that isn't https, loopback http or reverse domain name", redirectURI)
+		}
+	}
+
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// isValidPKCEValue checks code verifier or challenge format of RFC 7636.
+func isValidPKCEValue(value string) bool {
+	if len(value) < minPKCEValueLength || len(value) > maxPKCEValueLength {
+		return false
+	}
+
+	for _, r := range value {
+		isUnreserved := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '-' || r == '.' || r == '_' || r == '~'
+		if !isUnreserved {
+			return false
+		}
+	}
+
+	return true
+}
+
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if !isValidPKCEValue(verifier) {
+		return false
+	}
+
+	verifierHash := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(verifierHash[:])
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// clientFingerprint binds sessions of OAuth client to it instead of fingerprint sent by browser.
+func clientFingerprint(clientID string) uuid.UUID {
+	return uuid.NewSHA1(oauthFingerprintNamespace, []byte(clientID))
+}
+
+func sessionClientID(session entity.Session) string {
+	if session.ClientID == nil {
+		return ""
+	}
+
+	return *session.ClientID
+}
+
+// scopedAccess leaves permissions granted to client. Roles are dropped: they would let
+// resource servers give client more than the user allowed. openid is kept for userinfo endpoint.
+func scopedAccess(access entity.UserAccess, scope string) entity.UserAccess {
+	var scoped entity.UserAccess
+	if scopeCovers(scope, entity.OpenIDScope) {
+		scoped.Permissions = append(scoped.Permissions, entity.OpenIDScope)
+	}
+	for _, permission := range access.Permissions {
+		if permission != entity.OpenIDScope && scopeCovers(scope, permission) {
+			scoped.Permissions = append(scoped.Permissions, permission)
+		}
+	}
+
+	return scoped
+}
+
+// scopeCovers checks that every scope value of requested is in granted.
+func scopeCovers(granted string, requested string) bool {
+	grantedValues := make(map[string]struct{})
+	for _, value := range strings.Fields(granted) {
+		grantedValues[value] = struct{}{}
+	}
+
+	for _, value := range strings.Fields(requested) {
+		if _, ok := grantedValues[value]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+func mergeScopes(first string, second string) string {
+	merged, _ := normalizePermissions(append(strings.Fields(first), strings.Fields(second)...))
+
+	return strings.Join(merged, " ")
+}
+
+func redirectWithQuery(redirectURI string, params map[string]string) (string, error) {
+	u, parseErr := url.Parse(redirectURI)
+	if parseErr != nil {
+		return "", fmt.Errorf("can't parse redirect uri: %w", parseErr)
+	}
+
+	query := u.Query()
+	for key, value := range params {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
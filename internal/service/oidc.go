@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	authorizationEndpointPath = "/oauth/authorize"
+	tokenEndpointPath         = "/oauth/token"
+	deviceEndpointPath        = "/oauth/device_authorization"
+	userInfoEndpointPath      = "/userinfo"
+	jwksPath                  = "/.well-known/jwks.json"
+)
+
+const hmacAlgorithmPrefix = "HS"
+
+// UserInfo returns claims about owner of access token. Tokens of OAuth clients must have openid scope,
+// tokens of machine clients have no user to describe.
+func (s *AuthService) UserInfo(ctx context.Context, accessToken string) (entity.UserInfo, error) {
+	claims, validateErr := s.Validate(ctx, accessToken)
+	if validateErr != nil {
+		return entity.UserInfo{}, validateErr
+	}
+	if claims.Principal == entity.ClientPrincipal ||
+		claims.ClientID != "" && !scopeCovers(strings.Join(claims.Permissions, " "), entity.OpenIDScope) {
+		s.logger.Printf(logPattern, requestUUID(ctx), userInfoMethod, autherrors.InsufficientScope)
+		return entity.UserInfo{}, autherrors.NewStatusError(autherrors.InsufficientScope, nil)
+	}
+
+	user, getUserErr := s.repo.GetUserByEmail(ctx, claims.Email)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), userInfoMethod, autherrors.InvalidToken)
+		return entity.UserInfo{}, autherrors.NewStatusError(autherrors.InvalidToken, errors.New("user doesn't exist"))
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), userInfoMethod, getUserErr)
+		return entity.UserInfo{}, fmt.Errorf("can't get user: %w", getUserErr)
+	}
+	if checkStatusErr := s.checkUserStatus(ctx, user, userInfoMethod); checkStatusErr != nil {
+		return entity.UserInfo{}, checkStatusErr
+	}
+
+	return entity.UserInfo{
+		Subject:       strconv.FormatInt(user.ID, 10),
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+	}, nil
+}
+
+// OpenIDConfiguration describes the provider for OpenID Connect Discovery. Authorization
+// and token endpoints are served by http proxy, so issuer must be its public URL.
+func (s *AuthService) OpenIDConfiguration(_ context.Context) entity.OpenIDConfiguration {
+	issuer := strings.TrimSuffix(s.cfg.OIDC.Issuer, "/")
+
+	var scopes, idTokenAlgorithms []string
+	if s.issuesIDTokens() {
+		scopes = []string{entity.OpenIDScope}
+		idTokenAlgorithms = []string{s.tokenGenerator.SigningAlgorithm()}
+	}
+
+	return entity.OpenIDConfiguration{
+		Issuer:                      s.cfg.OIDC.Issuer,
+		AuthorizationEndpoint:       issuer + authorizationEndpointPath,
+		TokenEndpoint:               issuer + tokenEndpointPath,
+		UserInfoEndpoint:            issuer + userInfoEndpointPath,
+		DeviceAuthorizationEndpoint: issuer + deviceEndpointPath,
+		JWKSURI:                     issuer + jwksPath,
+		ScopesSupported:             scopes,
+		ResponseTypesSupported:      []string{entity.CodeResponseType},
+		GrantTypesSupported: []string{
+			entity.AuthorizationCodeGrant, entity.RefreshTokenGrant, entity.ClientCredentialsGrant, entity.DeviceCodeGrant,
+		},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: idTokenAlgorithms,
+		TokenEndpointAuthMethodsSupported: []string{
+			"client_secret_basic", "client_secret_post", "none",
+		},
+		CodeChallengeMethodsSupported: []string{entity.PKCEMethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "email", "email_verified", "amr", "acr",
+		},
+		ACRValuesSupported: []string{entity.SingleFactorACR, entity.MultiFactorACR},
+	}
+}
+
+// issuesIDTokens tells whether ID tokens can be verified by clients with JWKS. Keys of HMAC
+// algorithms are shared secrets: they aren't published, so openid scope is refused with them.
+func (s *AuthService) issuesIDTokens() bool {
+	algorithm := s.tokenGenerator.SigningAlgorithm()
+
+	return algorithm != "" && !strings.HasPrefix(algorithm, hmacAlgorithmPrefix)
+}
+
+// checkOpenIDScope refuses openid scope if ID tokens can't be issued.
+func (s *AuthService) checkOpenIDScope(ctx context.Context, scope string, method string) error {
+	if scopeCovers(scope, entity.OpenIDScope) && !s.issuesIDTokens() {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.InvalidScope)
+		return autherrors.NewStatusError(autherrors.InvalidScope,
+			errors.New("openid scope requires asymmetric signing key"))
+	}
+
+	return nil
+}
+
+// generateIDToken issues ID token to client about user that authorized it.
+func (s *AuthService) generateIDToken(user entity.AuthUser, clientID string, nonce string,
+	authTime time.Time, amr string) (string, error) {
+	issuedAt := time.Now()
+
+	return s.tokenGenerator.GenerateIDToken(entity.IDTokenClaims{
+		Issuer:        s.cfg.OIDC.Issuer,
+		Subject:       strconv.FormatInt(user.ID, 10),
+		Audience:      clientID,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Nonce:         nonce,
+		AuthTime:      authTime,
+		AMR:           strings.Fields(amr),
+		ACR:           authenticationClass(amr),
+		IssuedAt:      issuedAt,
+		ExpiresAt:     issuedAt.Add(s.cfg.OIDC.IDTokenTTL),
+	})
+}
+
+// sessionAMR tells how user proved identity by method that started session.
+func sessionAMR(method string) string {
+	switch method {
+	case loginMethod:
+		return entity.PasswordAMR
+	case completeEmailLoginMethod:
+		return entity.OneTimeCodeAMR
+	case verifyMFAMethod:
+		return entity.MultiFactorAMR + " " + entity.OneTimeCodeAMR
+	case finishPasskeyLoginMethod:
+		return entity.HardwareKeyAMR + " " + entity.UserPresenceAMR
+	default:
+		return ""
+	}
+}
+
+// authenticationClass counts passkey as second factor: the key is possessed and unlocked by user.
+func authenticationClass(amr string) string {
+	if scopeCovers(amr, entity.MultiFactorAMR) ||
+		scopeCovers(amr, entity.HardwareKeyAMR+" "+entity.UserPresenceAMR) {
+		return entity.MultiFactorACR
+	}
+
+	return entity.SingleFactorACR
+}
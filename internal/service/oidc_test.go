@@ -0,0 +1,277 @@
+package service_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	oidcIssuer      = "https://auth.example.com"
+	oidcClientID    = "native-app"
+	oidcRedirectURI = "com.example.app:/callback"
+)
+
+// relyingParty is OpenID Connect client that trusts only what provider publishes:
+// discovery document and JWKS.
+type relyingParty struct {
+	ctx         context.Context
+	svc         *service.AuthService
+	accessToken string
+}
+
+func newRelyingParty(t *testing.T, accessKey *tokengen.SigningKey) relyingParty {
+	t.Helper()
+
+	user := entity.AuthUser{ID: 42, Email: "oidc@example.com", EmailVerified: true, Status: entity.UserActive}
+	repo := newMemoryRepository(user)
+	repo.clients[oidcClientID] = entity.OAuthClient{
+		ID:           oidcClientID,
+		Name:         "Example App",
+		RedirectURIs: []string{oidcRedirectURI},
+	}
+	svc, tokenGenerator := newTestService(t, repo, accessKey, nil, service.Config{
+		OAuth: service.OAuthConfig{CodeTTL: time.Minute},
+		OIDC:  service.OIDCConfig{Issuer: oidcIssuer, IDTokenTTL: time.Minute},
+	})
+
+	return relyingParty{
+		ctx:         context.Background(),
+		svc:         svc,
+		accessToken: loginAs(t, tokenGenerator, repo, user),
+	}
+}
+
+func newRSAAccessKey(t *testing.T) *tokengen.SigningKey {
+	t.Helper()
+
+	privateKey, generateErr := rsa.GenerateKey(rand.Reader, 2048)
+	if generateErr != nil {
+		t.Fatalf("can't generate rsa key: %v", generateErr)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	accessKey, parseErr := tokengen.ParsePrivateKeyPEM("", pemBytes)
+	if parseErr != nil {
+		t.Fatalf("can't parse rsa key: %v", parseErr)
+	}
+
+	return accessKey
+}
+
+func authorizationRequest(scope string, codeVerifier string) entity.AuthorizationRequest {
+	challenge := sha256.Sum256([]byte(codeVerifier))
+
+	return entity.AuthorizationRequest{
+		ClientID:            oidcClientID,
+		RedirectURI:         oidcRedirectURI,
+		ResponseType:        entity.CodeResponseType,
+		Scope:               scope,
+		State:               "af0ifjsldkj",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(challenge[:]),
+		CodeChallengeMethod: entity.PKCEMethodS256,
+		Nonce:               "n-0S6_WzA2Mj",
+	}
+}
+
+// authorize approves request as user on consent page and returns code from redirect.
+func (rp relyingParty) authorize(t *testing.T, req entity.AuthorizationRequest) string {
+	t.Helper()
+
+	prompt, validateErr := rp.svc.ValidateAuthorizationRequest(rp.ctx, rp.accessToken, req)
+	if validateErr != nil {
+		t.Fatalf("ValidateAuthorizationRequest: %v", validateErr)
+	}
+	if prompt.ClientName != "Example App" || prompt.Scope != req.Scope {
+		t.Fatalf("unexpected consent prompt: %+v", prompt)
+	}
+
+	redirect, authorizeErr := rp.svc.Authorize(rp.ctx, rp.accessToken, req, true)
+	if authorizeErr != nil {
+		t.Fatalf("Authorize: %v", authorizeErr)
+	}
+	redirectURL, parseErr := url.Parse(redirect)
+	if parseErr != nil {
+		t.Fatalf("can't parse redirect %q: %v", redirect, parseErr)
+	}
+	if redirectURL.Scheme != "com.example.app" || redirectURL.Path != "/callback" {
+		t.Fatalf("redirected to %q, want %q", redirect, oidcRedirectURI)
+	}
+	if state := redirectURL.Query().Get("state"); state != req.State {
+		t.Fatalf("state = %q, want %q", state, req.State)
+	}
+	code := redirectURL.Query().Get("code")
+	if code == "" {
+		t.Fatalf("redirect has no code: %q", redirect)
+	}
+
+	return code
+}
+
+func (rp relyingParty) exchange(code string, codeVerifier string) (entity.OAuthTokens, error) {
+	return rp.svc.ExchangeOAuthToken(rp.ctx, entity.OAuthTokenRequest{
+		GrantType:    entity.AuthorizationCodeGrant,
+		ClientID:     oidcClientID,
+		Code:         code,
+		RedirectURI:  oidcRedirectURI,
+		CodeVerifier: codeVerifier,
+	})
+}
+
+// verifyIDToken checks signature with key found in JWKS by kid and algorithm advertised by discovery.
+func (rp relyingParty) verifyIDToken(t *testing.T, discovery entity.OpenIDConfiguration,
+	idToken string) jwt.MapClaims {
+	t.Helper()
+
+	claims := jwt.MapClaims{}
+	_, parseErr := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if len(discovery.IDTokenSigningAlgValuesSupported) != 1 ||
+			token.Method.Alg() != discovery.IDTokenSigningAlgValuesSupported[0] {
+			t.Fatalf("id token is signed with %s, discovery advertises %q",
+				token.Method.Alg(), discovery.IDTokenSigningAlgValuesSupported)
+		}
+
+		for _, jwk := range rp.svc.GetJWKS(rp.ctx) {
+			if jwk.Kid == token.Header["kid"] && jwk.Kty == "RSA" && jwk.Alg == token.Method.Alg() {
+				return rsaPublicKey(t, jwk), nil
+			}
+		}
+		t.Fatalf("jwks has no key %v", token.Header["kid"])
+		return nil, nil
+	})
+	if parseErr != nil {
+		t.Fatalf("id token isn't valid: %v", parseErr)
+	}
+
+	return claims
+}
+
+func rsaPublicKey(t *testing.T, jwk entity.JSONWebKey) *rsa.PublicKey {
+	t.Helper()
+
+	n, decodeNErr := base64.RawURLEncoding.DecodeString(jwk.N)
+	e, decodeEErr := base64.RawURLEncoding.DecodeString(jwk.E)
+	if decodeNErr != nil || decodeEErr != nil {
+		t.Fatalf("can't decode jwk %s: %v %v", jwk.Kid, decodeNErr, decodeEErr)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+}
+
+func TestOpenIDConnectCodeFlow(t *testing.T) {
+	rp := newRelyingParty(t, newRSAAccessKey(t))
+
+	discovery := rp.svc.OpenIDConfiguration(rp.ctx)
+	if discovery.Issuer != oidcIssuer || discovery.JWKSURI != oidcIssuer+"/.well-known/jwks.json" {
+		t.Fatalf("unexpected discovery endpoints: %+v", discovery)
+	}
+	if !contains(discovery.ScopesSupported, entity.OpenIDScope) ||
+		!contains(discovery.ResponseTypesSupported, entity.CodeResponseType) ||
+		!contains(discovery.GrantTypesSupported, entity.AuthorizationCodeGrant) ||
+		!contains(discovery.CodeChallengeMethodsSupported, entity.PKCEMethodS256) ||
+		!contains(discovery.IDTokenSigningAlgValuesSupported, "RS256") {
+		t.Fatalf("discovery doesn't advertise code flow with PKCE and RS256 id tokens: %+v", discovery)
+	}
+
+	codeVerifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	req := authorizationRequest(entity.OpenIDScope, codeVerifier)
+
+	_, exchangeErr := rp.exchange(rp.authorize(t, req), "wrong-verifier-wrong-verifier-wrong-verifier")
+	if !autherrors.Is(exchangeErr, autherrors.InvalidGrant) {
+		t.Fatalf("code is exchanged with wrong verifier: %v", exchangeErr)
+	}
+
+	code := rp.authorize(t, req)
+	tokens, exchangeErr := rp.exchange(code, codeVerifier)
+	if exchangeErr != nil {
+		t.Fatalf("ExchangeOAuthToken: %v", exchangeErr)
+	}
+	if tokens.Scope != entity.OpenIDScope || tokens.IDToken == "" || tokens.Access.Token == "" {
+		t.Fatalf("unexpected tokens: scope %q, id token issued %t", tokens.Scope, tokens.IDToken != "")
+	}
+	if _, replayErr := rp.exchange(code, codeVerifier); !autherrors.Is(replayErr, autherrors.InvalidGrant) {
+		t.Fatalf("code is exchanged twice: %v", replayErr)
+	}
+
+	claims := rp.verifyIDToken(t, discovery, tokens.IDToken)
+	if !claims.VerifyIssuer(discovery.Issuer, true) || !claims.VerifyAudience(oidcClientID, true) ||
+		!claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		t.Fatalf("id token is issued for another party: %v", claims)
+	}
+	if claims["sub"] != "42" || claims["nonce"] != req.Nonce || claims["email"] != "oidc@example.com" {
+		t.Fatalf("unexpected id token claims: %v", claims)
+	}
+	if _, ok := claims["auth_time"]; !ok {
+		t.Fatalf("id token has no auth_time: %v", claims)
+	}
+
+	userInfo, userInfoErr := rp.svc.UserInfo(rp.ctx, tokens.Access.Token)
+	if userInfoErr != nil {
+		t.Fatalf("UserInfo: %v", userInfoErr)
+	}
+	if userInfo.Subject != claims["sub"] || userInfo.Email != claims["email"] || !userInfo.EmailVerified {
+		t.Fatalf("userinfo doesn't match id token: %+v", userInfo)
+	}
+}
+
+func TestOpenIDScopeRequiresAsymmetricKey(t *testing.T) {
+	rp := newRelyingParty(t, tokengen.NewHMACSigningKey("", "access-secret"))
+
+	discovery := rp.svc.OpenIDConfiguration(rp.ctx)
+	if len(discovery.ScopesSupported) != 0 || len(discovery.IDTokenSigningAlgValuesSupported) != 0 {
+		t.Fatalf("discovery advertises id tokens signed with shared secret: %+v", discovery)
+	}
+
+	const codeVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	_, validateErr := rp.svc.ValidateAuthorizationRequest(rp.ctx, rp.accessToken,
+		authorizationRequest(entity.OpenIDScope, codeVerifier))
+	if !autherrors.Is(validateErr, autherrors.InvalidScope) {
+		t.Fatalf("openid scope is accepted: %v", validateErr)
+	}
+
+	tokens, exchangeErr := rp.exchange(rp.authorize(t, authorizationRequest("", codeVerifier)), codeVerifier)
+	if exchangeErr != nil {
+		t.Fatalf("ExchangeOAuthToken: %v", exchangeErr)
+	}
+	if tokens.IDToken != "" {
+		t.Fatalf("id token is issued without openid scope")
+	}
+}
+
+func TestOpenIDRejectsLongNonce(t *testing.T) {
+	rp := newRelyingParty(t, newRSAAccessKey(t))
+
+	req := authorizationRequest(entity.OpenIDScope, "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	req.Nonce = strings.Repeat("n", 513)
+
+	if _, validateErr := rp.svc.ValidateAuthorizationRequest(rp.ctx, rp.accessToken, req); !autherrors.Is(validateErr, autherrors.InvalidNonce) {
+		t.Fatalf("long nonce is accepted on consent page: %v", validateErr)
+	}
+	if _, authorizeErr := rp.svc.Authorize(rp.ctx, rp.accessToken, req, true); !autherrors.Is(authorizeErr, autherrors.InvalidNonce) {
+		t.Fatalf("long nonce is accepted on approval: %v", authorizeErr)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxOrganizationNameLength = 128
+
+// CreateOrganization creates organization and makes its creator the owner.
+func (s *AuthService) CreateOrganization(ctx context.Context, accessToken string,
+	name string) (entity.Organization, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, createOrganizationMethod)
+	if authErr != nil {
+		return entity.Organization{}, authErr
+	}
+
+	org, createErr := s.createOrganization(ctx, user, name)
+	s.recordAudit(ctx, createOrganizationMethod, user.Email, user.ID, fmt.Sprintf("organization %q", name), createErr)
+
+	return org, createErr
+}
+
+func (s *AuthService) createOrganization(ctx context.Context, user entity.AuthUser, name string) (entity.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxOrganizationNameLength {
+		s.logger.Printf(logPattern, requestUUID(ctx), createOrganizationMethod, autherrors.InvalidOrganizationName)
+		return entity.Organization{}, autherrors.NewStatusError(autherrors.InvalidOrganizationName, nil)
+	}
+
+	org, createOrgErr := s.repo.CreateOrganization(ctx, name, entity.Membership{
+		UserID: user.ID,
+		Role:   entity.OrgOwnerRole,
+	})
+	if createOrgErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), createOrganizationMethod, createOrgErr)
+		return entity.Organization{}, fmt.Errorf("can't create organization: %w", createOrgErr)
+	}
+
+	return org, nil
+}
+
+func (s *AuthService) ListOrganizations(ctx context.Context, accessToken string) ([]entity.Membership, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, listOrganizationsMethod)
+	if authErr != nil {
+		return nil, authErr
+	}
+
+	memberships, getMembershipsErr := s.repo.GetUserMemberships(ctx, user.ID)
+	if getMembershipsErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), listOrganizationsMethod, getMembershipsErr)
+		return nil, fmt.Errorf("can't get user's organizations: %w", getMembershipsErr)
+	}
+
+	return memberships, nil
+}
+
+// InviteMember mails invitation link to email. Invited role can't grant permissions
+// the inviter doesn't have in organization.
+func (s *AuthService) InviteMember(ctx context.Context, accessToken string,
+	invitation entity.OrganizationInvitation) error {
+	inviter, membership, access, authErr := s.authorizeMember(ctx, accessToken, invitation.OrgID,
+		entity.ManageMembersPermission, inviteMemberMethod)
+	if authErr != nil {
+		return authErr
+	}
+
+	if invitation.Role == "" {
+		invitation.Role = entity.OrgMemberRole
+	}
+	inviteErr := s.inviteMember(ctx, inviter, membership, access, invitation)
+	s.recordAudit(ctx, inviteMemberMethod, inviter.Email, 0, fmt.Sprintf("%s to organization %d as %q",
+		invitation.Email, invitation.OrgID, invitation.Role), inviteErr)
+
+	return inviteErr
+}
+
+func (s *AuthService) inviteMember(ctx context.Context, inviter entity.AuthUser, membership entity.Membership,
+	access entity.UserAccess, invitation entity.OrganizationInvitation) error {
+	if validateEmailErr := validateEmail(invitation.Email); validateEmailErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), inviteMemberMethod, autherrors.InvalidEmail)
+		return autherrors.NewStatusError(autherrors.InvalidEmail, validateEmailErr)
+	}
+
+	role, getRoleErr := s.getRoleWithPermissions(ctx, invitation.Role)
+	if errors.Is(getRoleErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), inviteMemberMethod, autherrors.RoleNotExists)
+		return autherrors.NewStatusError(autherrors.RoleNotExists, nil)
+	}
+	if getRoleErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), inviteMemberMethod, getRoleErr)
+		return fmt.Errorf("can't get role: %w", getRoleErr)
+	}
+	for _, permission := range role.Permissions {
+		if !access.HasPermission(permission) {
+			s.logger.Printf(logPattern, requestUUID(ctx), inviteMemberMethod, autherrors.AccessDenied)
+			return autherrors.NewStatusError(autherrors.AccessDenied,
+				fmt.Errorf("role grants %s that inviter doesn't have", permission))
+		}
+	}
+
+	token, generateTokenErr := securetoken.Generate(securetoken.DefaultSize)
+	if generateTokenErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), inviteMemberMethod, generateTokenErr)
+		return fmt.Errorf("can't generate invitation token: %w", generateTokenErr)
+	}
+
+	invitation.TokenHash = securetoken.Hash(token)
+	invitation.InvitedBy = inviter.ID
+	invitation.ExpiresAt = time.Now().Add(s.cfg.Organization.InvitationTTL)
+	if createInvitationErr := s.repo.CreateInvitation(ctx, invitation); createInvitationErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), inviteMemberMethod, createInvitationErr)
+		return fmt.Errorf("can't save invitation: %w", createInvitationErr)
+	}
+
+	s.sendMail(ctx, inviteMemberMethod, invitationMail(invitation.Email, membership.OrgName,
+		linkWithToken(s.cfg.Organization.InvitationURL, token), s.cfg.Organization.InvitationTTL))
+
+	return nil
+}
+
+// AcceptInvitation adds user to organization. Invitation works only for account
+// with the email it was sent to.
+func (s *AuthService) AcceptInvitation(ctx context.Context, accessToken string,
+	invitationToken string) (entity.Membership, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, acceptInvitationMethod)
+	if authErr != nil {
+		return entity.Membership{}, authErr
+	}
+
+	membership, acceptErr := s.acceptInvitation(ctx, user, invitationToken)
+	s.recordAudit(ctx, acceptInvitationMethod, user.Email, user.ID, fmt.Sprintf("organization %d as %q",
+		membership.OrgID, membership.Role), acceptErr)
+
+	return membership, acceptErr
+}
+
+func (s *AuthService) acceptInvitation(ctx context.Context, user entity.AuthUser,
+	invitationToken string) (entity.Membership, error) {
+	invitation, acceptErr := s.repo.AcceptInvitation(ctx, securetoken.Hash(invitationToken), user.Email, time.Now())
+	if errors.Is(acceptErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), acceptInvitationMethod, autherrors.InvitationNotExists)
+		return entity.Membership{}, autherrors.NewStatusError(autherrors.InvitationNotExists, nil)
+	}
+	if acceptErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), acceptInvitationMethod, acceptErr)
+		return entity.Membership{}, fmt.Errorf("can't accept invitation: %w", acceptErr)
+	}
+
+	saveMembershipErr := s.repo.SaveMembership(ctx, entity.Membership{
+		OrgID:  invitation.OrgID,
+		UserID: user.ID,
+		Role:   invitation.Role,
+	})
+	if saveMembershipErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), acceptInvitationMethod, saveMembershipErr)
+		return entity.Membership{}, fmt.Errorf("can't add member: %w", saveMembershipErr)
+	}
+
+	membership, getMembershipErr := s.repo.GetMembership(ctx, invitation.OrgID, user.ID)
+	if getMembershipErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), acceptInvitationMethod, getMembershipErr)
+		return entity.Membership{}, fmt.Errorf("can't get membership: %w", getMembershipErr)
+	}
+
+	return membership, nil
+}
+
+// SwitchOrganization rotates refresh token into token pair that acts in organization.
+// Zero orgID switches back to personal context.
+func (s *AuthService) SwitchOrganization(ctx context.Context, refreshToken string, fingerprint string,
+	orgID int64) (entity.TokenPair, error) {
+	return s.rotateSession(ctx, refreshToken, fingerprint, &orgID, "", switchOrganizationMethod)
+}
+
+// authorizeMember is authorize for organization: it checks permission of user's role in organization.
+func (s *AuthService) authorizeMember(ctx context.Context, accessToken string, orgID int64,
+	permission string, method string) (entity.AuthUser, entity.Membership, entity.UserAccess, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, method)
+	if authErr != nil {
+		return entity.AuthUser{}, entity.Membership{}, entity.UserAccess{}, authErr
+	}
+
+	membership, getMembershipErr := s.repo.GetMembership(ctx, orgID, user.ID)
+	if errors.Is(getMembershipErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.NotOrganizationMember)
+		return entity.AuthUser{}, entity.Membership{}, entity.UserAccess{},
+			autherrors.NewStatusError(autherrors.NotOrganizationMember, nil)
+	}
+	if getMembershipErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getMembershipErr)
+		return entity.AuthUser{}, entity.Membership{}, entity.UserAccess{},
+			fmt.Errorf("can't get membership: %w", getMembershipErr)
+	}
+
+	access, getAccessErr := s.repo.GetMemberAccess(ctx, orgID, user.ID)
+	if getAccessErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getAccessErr)
+		return entity.AuthUser{}, entity.Membership{}, entity.UserAccess{},
+			fmt.Errorf("can't get member's permissions: %w", getAccessErr)
+	}
+	if !access.HasPermission(permission) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.AccessDenied)
+		return entity.AuthUser{}, entity.Membership{}, entity.UserAccess{},
+			autherrors.NewStatusError(autherrors.AccessDenied, fmt.Errorf("%s is required", permission))
+	}
+
+	return user, membership, access, nil
+}
+
+func (s *AuthService) checkMembership(ctx context.Context, orgID int64, userID int64, method string) error {
+	_, getMembershipErr := s.repo.GetMembership(ctx, orgID, userID)
+	if errors.Is(getMembershipErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.NotOrganizationMember)
+		return autherrors.NewStatusError(autherrors.NotOrganizationMember, nil)
+	}
+	if getMembershipErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getMembershipErr)
+		return fmt.Errorf("can't get membership: %w", getMembershipErr)
+	}
+
+	return nil
+}
+
+func (s *AuthService) getRoleWithPermissions(ctx context.Context, name string) (entity.Role, error) {
+	roles, getRolesErr := s.repo.GetRoles(ctx)
+	if getRolesErr != nil {
+		return entity.Role{}, getRolesErr
+	}
+
+	for _, role := range roles {
+		if role.Name == name {
+			return role, nil
+		}
+	}
+
+	return entity.Role{}, ErrEntityNotFound
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/google/uuid"
+	"time"
+)
+
+func (s *AuthService) BeginPasskeyRegistration(ctx context.Context, accessToken string) (entity.PasskeyChallenge, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, beginPasskeyRegistrationMethod)
+	if authErr != nil {
+		return entity.PasskeyChallenge{}, authErr
+	}
+
+	passkeys, getPasskeysErr := s.repo.GetUserPasskeys(ctx, user.ID)
+	if getPasskeysErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), beginPasskeyRegistrationMethod, getPasskeysErr)
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't get user's passkeys: %w", getPasskeysErr)
+	}
+
+	challenge, beginErr := s.passkeys.BeginRegistration(user, passkeys)
+	if beginErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), beginPasskeyRegistrationMethod, beginErr)
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't begin passkey registration: %w", beginErr)
+	}
+
+	return s.startCeremony(ctx, &user.ID, entity.PasskeyRegistrationPurpose, challenge, beginPasskeyRegistrationMethod)
+}
+
+func (s *AuthService) FinishPasskeyRegistration(ctx context.Context, accessToken string,
+	ceremonyID string, credential []byte) error {
+	ceremonyUUID, parseCeremonyIDErr := uuid.Parse(ceremonyID)
+	if parseCeremonyIDErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), finishPasskeyRegistrationMethod, autherrors.InvalidCeremonyID)
+		return autherrors.NewStatusError(autherrors.InvalidCeremonyID, nil)
+	}
+
+	user, _, authErr := s.authenticate(ctx, accessToken, finishPasskeyRegistrationMethod)
+	if authErr != nil {
+		return authErr
+	}
+
+	ceremony, consumeErr := s.consumeCeremony(ctx, ceremonyUUID, entity.PasskeyRegistrationPurpose, finishPasskeyRegistrationMethod)
+	if consumeErr != nil {
+		return consumeErr
+	}
+	if ceremony.UserID == nil || *ceremony.UserID != user.ID {
+		s.logger.Printf(logPattern, requestUUID(ctx), finishPasskeyRegistrationMethod, autherrors.CeremonyNotExists)
+		return autherrors.NewStatusError(autherrors.CeremonyNotExists, nil)
+	}
+
+	passkeys, getPasskeysErr := s.repo.GetUserPasskeys(ctx, user.ID)
+	if getPasskeysErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), finishPasskeyRegistrationMethod, getPasskeysErr)
+		return fmt.Errorf("can't get user's passkeys: %w", getPasskeysErr)
+	}
+
+	passkey, finishErr := s.passkeys.FinishRegistration(user, passkeys, ceremony.SessionData, credential)
+	if errors.Is(finishErr, ErrInvalidPasskey) {
+		s.logger.Printf(logPattern, requestUUID(ctx), finishPasskeyRegistrationMethod, finishErr)
+		return autherrors.NewStatusError(autherrors.InvalidPasskey, finishErr)
+	}
+	if finishErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), finishPasskeyRegistrationMethod, finishErr)
+		return fmt.Errorf("can't finish passkey registration: %w", finishErr)
+	}
+
+	if createPasskeyErr := s.repo.CreatePasskey(ctx, passkey); createPasskeyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), finishPasskeyRegistrationMethod, createPasskeyErr)
+		return fmt.Errorf("can't save passkey: %w", createPasskeyErr)
+	}
+
+	return nil
+}
+
+func (s *AuthService) BeginPasskeyLogin(ctx context.Context) (entity.PasskeyChallenge, error) {
+	challenge, beginErr := s.passkeys.BeginLogin()
+	if beginErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), beginPasskeyLoginMethod, beginErr)
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't begin passkey login: %w", beginErr)
+	}
+
+	return s.startCeremony(ctx, nil, entity.PasskeyLoginPurpose, challenge, beginPasskeyLoginMethod)
+}
+
+// FinishPasskeyLogin verifies assertion of discoverable passkey and starts session of its owner.
+// Passkey verifies user itself, so neither password nor second factor is asked.
+func (s *AuthService) FinishPasskeyLogin(ctx context.Context, ceremonyID string,
+	credential []byte, fingerprint string) (entity.TokenPair, error) {
+	fingerprintUUID, parseFingerprintErr := uuid.Parse(fingerprint)
+	if parseFingerprintErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), finishPasskeyLoginMethod, autherrors.InvalidFingerprint)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidFingerprint, nil)
+	}
+
+	ceremonyUUID, parseCeremonyIDErr := uuid.Parse(ceremonyID)
+	if parseCeremonyIDErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), finishPasskeyLoginMethod, autherrors.InvalidCeremonyID)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidCeremonyID, nil)
+	}
+
+	ceremony, consumeErr := s.consumeCeremony(ctx, ceremonyUUID, entity.PasskeyLoginPurpose, finishPasskeyLoginMethod)
+	if consumeErr != nil {
+		return entity.TokenPair{}, consumeErr
+	}
+
+	findUser := func(userID int64) (entity.AuthUser, []entity.PasskeyCredential, error) {
+		user, getUserErr := s.repo.GetUserByID(ctx, userID)
+		if getUserErr != nil {
+			return entity.AuthUser{}, nil, getUserErr
+		}
+
+		passkeys, getPasskeysErr := s.repo.GetUserPasskeys(ctx, userID)
+		if getPasskeysErr != nil {
+			return entity.AuthUser{}, nil, getPasskeysErr
+		}
+
+		return user, passkeys, nil
+	}
+
+	user, passkey, finishErr := s.passkeys.FinishLogin(ceremony.SessionData, credential, findUser)
+	if errors.Is(finishErr, ErrInvalidPasskey) {
+		s.logger.Printf(logPattern, requestUUID(ctx), finishPasskeyLoginMethod, finishErr)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.InvalidPasskey, finishErr)
+	}
+	if finishErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), finishPasskeyLoginMethod, finishErr)
+		return entity.TokenPair{}, fmt.Errorf("can't finish passkey login: %w", finishErr)
+	}
+
+	if passkey.CloneWarning {
+		s.logger.Warnf(logPattern, requestUUID(ctx), finishPasskeyLoginMethod, autherrors.PasskeyCloneSuspected)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.PasskeyCloneSuspected, nil)
+	}
+
+	if updatePasskeyErr := s.repo.UpdatePasskeyUsage(ctx, passkey, time.Now()); updatePasskeyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), finishPasskeyLoginMethod, updatePasskeyErr)
+		return entity.TokenPair{}, fmt.Errorf("can't update passkey sign count: %w", updatePasskeyErr)
+	}
+
+	if s.cfg.EmailVerification.Required && !user.EmailVerified {
+		s.logger.Printf(logPattern, requestUUID(ctx), finishPasskeyLoginMethod, autherrors.EmailNotVerified)
+		return entity.TokenPair{}, autherrors.NewStatusError(autherrors.EmailNotVerified, nil)
+	}
+
+	return s.startSession(ctx, user, fingerprintUUID, finishPasskeyLoginMethod)
+}
+
+// startCeremony stores session data of begin step until client answers the challenge.
+func (s *AuthService) startCeremony(ctx context.Context, userID *int64, purpose string,
+	challenge entity.PasskeyChallenge, method string) (entity.PasskeyChallenge, error) {
+	challenge.CeremonyID = uuid.New()
+
+	createCeremonyErr := s.repo.CreatePasskeyCeremony(ctx, entity.PasskeyCeremony{
+		ID:          challenge.CeremonyID,
+		UserID:      userID,
+		Purpose:     purpose,
+		SessionData: challenge.SessionData,
+		ExpiresAt:   time.Now().Add(s.cfg.Passkey.CeremonyTTL),
+	})
+	if createCeremonyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, createCeremonyErr)
+		return entity.PasskeyChallenge{}, fmt.Errorf("can't save passkey ceremony: %w", createCeremonyErr)
+	}
+
+	return challenge, nil
+}
+
+func (s *AuthService) consumeCeremony(ctx context.Context, ceremonyID uuid.UUID,
+	purpose string, method string) (entity.PasskeyCeremony, error) {
+	ceremony, consumeErr := s.repo.ConsumePasskeyCeremony(ctx, ceremonyID, purpose, time.Now())
+	if errors.Is(consumeErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.CeremonyNotExists)
+		return entity.PasskeyCeremony{}, autherrors.NewStatusError(autherrors.CeremonyNotExists, nil)
+	}
+	if consumeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, consumeErr)
+		return entity.PasskeyCeremony{}, fmt.Errorf("can't get passkey ceremony: %w", consumeErr)
+	}
+
+	return ceremony, nil
+}
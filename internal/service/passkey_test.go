@@ -0,0 +1,354 @@
+package service_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/passkey"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:8950"
+)
+
+const (
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagAttestedCredential = 0x40
+)
+
+// softAuthenticator is platform authenticator with ES256 key kept in memory.
+// It answers options of begin steps the way browser with real authenticator does.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, generateErr := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if generateErr != nil {
+		t.Fatalf("can't generate authenticator key: %v", generateErr)
+	}
+	credentialID := make([]byte, 16)
+	if _, readErr := rand.Read(credentialID); readErr != nil {
+		t.Fatalf("can't generate credential id: %v", readErr)
+	}
+
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// create answers navigator.credentials.create() with "none" attestation.
+func (a *softAuthenticator) create(t *testing.T, options []byte) []byte {
+	t.Helper()
+
+	var creation struct {
+		PublicKey struct {
+			Challenge protocol.URLEncodedBase64 `json:"challenge"`
+			RP        struct {
+				ID string `json:"id"`
+			} `json:"rp"`
+			User struct {
+				ID protocol.URLEncodedBase64 `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	}
+	mustUnmarshal(t, options, &creation)
+	a.userHandle = creation.PublicKey.User.ID
+
+	coseKey, marshalKeyErr := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if marshalKeyErr != nil {
+		t.Fatalf("can't encode public key: %v", marshalKeyErr)
+	}
+
+	authData := a.authenticatorData(creation.PublicKey.RP.ID, flagUserPresent|flagUserVerified|flagAttestedCredential)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialID)))
+	authData = append(authData, a.credentialID...)
+	authData = append(authData, coseKey...)
+
+	attestationObject, marshalAttestationErr := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if marshalAttestationErr != nil {
+		t.Fatalf("can't encode attestation object: %v", marshalAttestationErr)
+	}
+
+	return mustMarshal(t, map[string]any{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData(t, protocol.CreateCeremony, creation.PublicKey.Challenge)),
+			"attestationObject": encode(attestationObject),
+		},
+	})
+}
+
+// get answers navigator.credentials.get() with signed assertion of discoverable credential.
+func (a *softAuthenticator) get(t *testing.T, options []byte) []byte {
+	t.Helper()
+
+	var assertion struct {
+		PublicKey struct {
+			Challenge protocol.URLEncodedBase64 `json:"challenge"`
+			RPID      string                    `json:"rpId"`
+		} `json:"publicKey"`
+	}
+	mustUnmarshal(t, options, &assertion)
+
+	rpID := assertion.PublicKey.RPID
+	if rpID == "" {
+		rpID = testRPID
+	}
+	a.signCount++
+	authData := a.authenticatorData(rpID, flagUserPresent|flagUserVerified)
+	clientDataJSON := clientData(t, protocol.AssertCeremony, assertion.PublicKey.Challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signedData := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, signErr := ecdsa.SignASN1(rand.Reader, a.key, signedData[:])
+	if signErr != nil {
+		t.Fatalf("can't sign assertion: %v", signErr)
+	}
+
+	return mustMarshal(t, map[string]any{
+		"id":    encode(a.credentialID),
+		"rawId": encode(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientDataJSON),
+			"authenticatorData": encode(authData),
+			"signature":         encode(signature),
+			"userHandle":        encode(a.userHandle),
+		},
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(rpID string, flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	authData := append(rpIDHash[:], flags)
+
+	return binary.BigEndian.AppendUint32(authData, a.signCount)
+}
+
+func clientData(t *testing.T, ceremony protocol.CeremonyType, challenge []byte) []byte {
+	return mustMarshal(t, map[string]string{
+		"type":      string(ceremony),
+		"challenge": encode(challenge),
+		"origin":    testOrigin,
+	})
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+
+	b, marshalErr := json.Marshal(v)
+	if marshalErr != nil {
+		t.Fatalf("can't encode json: %v", marshalErr)
+	}
+
+	return b
+}
+
+func mustUnmarshal(t *testing.T, b []byte, v any) {
+	t.Helper()
+
+	if unmarshalErr := json.Unmarshal(b, v); unmarshalErr != nil {
+		t.Fatalf("can't decode json: %v", unmarshalErr)
+	}
+}
+
+type passkeyTest struct {
+	ctx         context.Context
+	svc         *service.AuthService
+	repo        *memoryRepository
+	accessToken string
+}
+
+func newPasskeyTest(t *testing.T) passkeyTest {
+	t.Helper()
+
+	provider, createProviderErr := passkey.NewWebAuthnProvider(passkey.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Test",
+		RPOrigins:     []string{testOrigin},
+	})
+	if createProviderErr != nil {
+		t.Fatalf("can't create passkey provider: %v", createProviderErr)
+	}
+
+	user := entity.AuthUser{ID: 42, Email: "passkey@example.com", EmailVerified: true, Status: entity.UserActive}
+	repo := newMemoryRepository(user)
+	svc, tokenGenerator := newTestService(t, repo, tokengen.NewHMACSigningKey("", "access-secret"), provider,
+		service.Config{Passkey: service.PasskeyConfig{CeremonyTTL: time.Minute}})
+
+	return passkeyTest{
+		ctx:         context.Background(),
+		svc:         svc,
+		repo:        repo,
+		accessToken: loginAs(t, tokenGenerator, repo, user),
+	}
+}
+
+func (pt passkeyTest) register(t *testing.T, authenticator *softAuthenticator) {
+	t.Helper()
+
+	challenge, beginErr := pt.svc.BeginPasskeyRegistration(pt.ctx, pt.accessToken)
+	if beginErr != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", beginErr)
+	}
+
+	finishErr := pt.svc.FinishPasskeyRegistration(pt.ctx, pt.accessToken, challenge.CeremonyID.String(),
+		authenticator.create(t, challenge.Options))
+	if finishErr != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", finishErr)
+	}
+}
+
+func (pt passkeyTest) login(t *testing.T, authenticator *softAuthenticator) (entity.TokenPair, error) {
+	t.Helper()
+
+	challenge, beginErr := pt.svc.BeginPasskeyLogin(pt.ctx)
+	if beginErr != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", beginErr)
+	}
+
+	return pt.svc.FinishPasskeyLogin(pt.ctx, challenge.CeremonyID.String(),
+		authenticator.get(t, challenge.Options), uuid.NewString())
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	pt := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+
+	pt.register(t, authenticator)
+	if len(pt.repo.passkeys) != 1 || pt.repo.passkeys[0].UserID != 42 {
+		t.Fatalf("passkey isn't saved for user: %+v", pt.repo.passkeys)
+	}
+
+	for i := 1; i <= 2; i++ {
+		tokenPair, loginErr := pt.login(t, authenticator)
+		if loginErr != nil {
+			t.Fatalf("login %d: %v", i, loginErr)
+		}
+		if tokenPair.Access.Token == "" || tokenPair.Refresh.Token == "" {
+			t.Fatalf("login %d: tokens aren't issued", i)
+		}
+		if pt.repo.passkeys[0].SignCount != uint32(i) {
+			t.Fatalf("login %d: sign count = %d", i, pt.repo.passkeys[0].SignCount)
+		}
+	}
+
+	claims, validateErr := pt.svc.Validate(pt.ctx, pt.accessToken)
+	if validateErr != nil {
+		t.Fatalf("Validate: %v", validateErr)
+	}
+	if claims.Email != "passkey@example.com" {
+		t.Fatalf("token is issued for %q", claims.Email)
+	}
+}
+
+func TestPasskeyLoginRejectsSignCountReplay(t *testing.T) {
+	pt := newPasskeyTest(t)
+	authenticator := newSoftAuthenticator(t)
+	pt.register(t, authenticator)
+
+	if _, loginErr := pt.login(t, authenticator); loginErr != nil {
+		t.Fatalf("first login: %v", loginErr)
+	}
+
+	clone := *authenticator
+	clone.signCount = 0
+	_, loginErr := pt.login(t, &clone)
+	if !autherrors.Is(loginErr, autherrors.PasskeyCloneSuspected) {
+		t.Fatalf("login with stale sign count: got %v, want %s", loginErr, autherrors.PasskeyCloneSuspected)
+	}
+	if pt.repo.passkeys[0].SignCount != 1 {
+		t.Fatalf("sign count changed by rejected login: %d", pt.repo.passkeys[0].SignCount)
+	}
+}
+
+func TestPasskeyRejectsChallengeMismatch(t *testing.T) {
+	t.Run("registration", func(t *testing.T) {
+		pt := newPasskeyTest(t)
+		authenticator := newSoftAuthenticator(t)
+
+		answered, beginErr := pt.svc.BeginPasskeyRegistration(pt.ctx, pt.accessToken)
+		if beginErr != nil {
+			t.Fatalf("BeginPasskeyRegistration: %v", beginErr)
+		}
+		other, beginErr := pt.svc.BeginPasskeyRegistration(pt.ctx, pt.accessToken)
+		if beginErr != nil {
+			t.Fatalf("BeginPasskeyRegistration: %v", beginErr)
+		}
+
+		finishErr := pt.svc.FinishPasskeyRegistration(pt.ctx, pt.accessToken, other.CeremonyID.String(),
+			authenticator.create(t, answered.Options))
+		if !autherrors.Is(finishErr, autherrors.InvalidPasskey) {
+			t.Fatalf("got %v, want %s", finishErr, autherrors.InvalidPasskey)
+		}
+		if len(pt.repo.passkeys) != 0 {
+			t.Fatalf("passkey is saved: %+v", pt.repo.passkeys)
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		pt := newPasskeyTest(t)
+		authenticator := newSoftAuthenticator(t)
+		pt.register(t, authenticator)
+
+		answered, beginErr := pt.svc.BeginPasskeyLogin(pt.ctx)
+		if beginErr != nil {
+			t.Fatalf("BeginPasskeyLogin: %v", beginErr)
+		}
+		other, beginErr := pt.svc.BeginPasskeyLogin(pt.ctx)
+		if beginErr != nil {
+			t.Fatalf("BeginPasskeyLogin: %v", beginErr)
+		}
+
+		response := authenticator.get(t, answered.Options)
+		_, finishErr := pt.svc.FinishPasskeyLogin(pt.ctx, other.CeremonyID.String(), response, uuid.NewString())
+		if !autherrors.Is(finishErr, autherrors.InvalidPasskey) {
+			t.Fatalf("got %v, want %s", finishErr, autherrors.InvalidPasskey)
+		}
+
+		_, replayErr := pt.svc.FinishPasskeyLogin(pt.ctx, other.CeremonyID.String(), response, uuid.NewString())
+		if !autherrors.Is(replayErr, autherrors.CeremonyNotExists) {
+			t.Fatalf("ceremony is reusable: got %v, want %s", replayErr, autherrors.CeremonyNotExists)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"time"
+)
+
+func (s *AuthService) ChangePassword(ctx context.Context, accessToken string,
+	passwordChange entity.PasswordChange) error {
+	user, claims, authErr := s.authenticate(ctx, accessToken, changePasswordMethod)
+	if authErr != nil {
+		return authErr
+	}
+
+	// Current password is guessed against the same counters as login, so stolen access token
+	// doesn't give more attempts.
+	attempt, throttleErr := s.checkLoginThrottle(ctx, user.Email)
+	if throttleErr != nil {
+		return throttleErr
+	}
+	if !s.hasher.CompareHashes(passwordChange.CurrentPassword, user.Password) {
+		s.logger.Printf(logPattern, requestUUID(ctx), changePasswordMethod, autherrors.UserInvalidPassword)
+		invalidPasswordErr := autherrors.NewStatusError(autherrors.UserInvalidPassword, nil)
+		s.recordAudit(ctx, entity.AuditPasswordChange, user.Email, user.ID, "", invalidPasswordErr)
+		return invalidPasswordErr
+	}
+	s.passLoginAttempt(ctx, user.Email, attempt)
+
+	if validatePasswordErr := validatePassword(passwordChange.NewPassword); validatePasswordErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), changePasswordMethod, validatePasswordErr)
+		return autherrors.NewStatusError(autherrors.WeakPassword, validatePasswordErr)
+	}
+
+	hashedPassword, hashPwErr := s.hasher.Hash(passwordChange.NewPassword)
+	if hashPwErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), changePasswordMethod, hashPwErr)
+		return fmt.Errorf("can't create password hash: %w", hashPwErr)
+	}
+
+	if updatePwErr := s.repo.UpdateUserPassword(ctx, user.ID, hashedPassword); updatePwErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), changePasswordMethod, updatePwErr)
+		return fmt.Errorf("can't update password: %w", updatePwErr)
+	}
+
+	if passwordChange.RevokeOtherSessions {
+		revokeErr := s.revokeOtherUserSessions(ctx, user.ID, claims.SessionID, changePasswordMethod)
+		if revokeErr != nil {
+			return revokeErr
+		}
+	}
+
+	if passwordChange.RevokeCurrentSession {
+		deleteSessionErr := s.repo.DeleteSessionsByFamily(ctx, claims.SessionID)
+		if deleteSessionErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), changePasswordMethod, deleteSessionErr)
+			return fmt.Errorf("can't delete current session: %w", deleteSessionErr)
+		}
+		s.sessionCache.Delete(claims.SessionID)
+	}
+	s.recordAudit(ctx, entity.AuditPasswordChange, user.Email, user.ID, "", nil)
+
+	return nil
+}
+
+// RequestPasswordReset mails reset link. Limited mail is refused whether account exists or not.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	reserveSendErr := s.reserveMailSend(ctx, entity.PasswordResetPurpose, email,
+		s.cfg.PasswordReset.MaxSendsPerEmail, s.cfg.PasswordReset.MaxSendsPerIP, requestPasswordResetMethod)
+	if reserveSendErr != nil {
+		return reserveSendErr
+	}
+
+	user, getUserErr := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), requestPasswordResetMethod, autherrors.UserNotExists)
+		return nil
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), requestPasswordResetMethod, getUserErr)
+		return fmt.Errorf("can't get user: %w", getUserErr)
+	}
+
+	resetToken, issueTokenErr := s.issueOneTimeToken(ctx, user.ID, entity.PasswordResetPurpose, s.cfg.PasswordReset.TokenTTL)
+	if issueTokenErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), requestPasswordResetMethod, issueTokenErr)
+		return fmt.Errorf("can't issue reset token: %w", issueTokenErr)
+	}
+
+	s.sendMail(ctx, requestPasswordResetMethod, passwordResetMail(user.Email,
+		linkWithToken(s.cfg.PasswordReset.URL, resetToken), s.cfg.PasswordReset.TokenTTL))
+
+	return nil
+}
+
+func (s *AuthService) ConfirmPasswordReset(ctx context.Context, resetToken string, newPassword string) error {
+	if validatePasswordErr := validatePassword(newPassword); validatePasswordErr != nil {
+		s.logger.Printf(logPattern, requestUUID(ctx), confirmPasswordResetMethod, validatePasswordErr)
+		return autherrors.NewStatusError(autherrors.WeakPassword, validatePasswordErr)
+	}
+
+	token, consumeErr := s.repo.ConsumeOneTimeToken(ctx, securetoken.Hash(resetToken),
+		entity.PasswordResetPurpose, time.Now())
+	if errors.Is(consumeErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), confirmPasswordResetMethod, autherrors.InvalidToken)
+		return autherrors.NewStatusError(autherrors.InvalidToken, errors.New("reset token is unknown, used or expired"))
+	}
+	if consumeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmPasswordResetMethod, consumeErr)
+		return fmt.Errorf("can't use reset token: %w", consumeErr)
+	}
+
+	hashedPassword, hashPwErr := s.hasher.Hash(newPassword)
+	if hashPwErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmPasswordResetMethod, hashPwErr)
+		return fmt.Errorf("can't create password hash: %w", hashPwErr)
+	}
+
+	if updatePwErr := s.repo.UpdateUserPassword(ctx, token.UserID, hashedPassword); updatePwErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmPasswordResetMethod, updatePwErr)
+		return fmt.Errorf("can't update password: %w", updatePwErr)
+	}
+
+	if deleteTokensErr := s.repo.DeleteUserOneTimeTokens(ctx, token.UserID, entity.PasswordResetPurpose); deleteTokensErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), confirmPasswordResetMethod, deleteTokensErr)
+		return fmt.Errorf("can't delete other reset tokens: %w", deleteTokensErr)
+	}
+
+	if revokeErr := s.revokeUserSessions(ctx, token.UserID, confirmPasswordResetMethod); revokeErr != nil {
+		return revokeErr
+	}
+	s.recordAudit(ctx, entity.AuditPasswordReset, userActor(token.UserID), token.UserID, "", nil)
+
+	return nil
+}
+
+// issueOneTimeToken creates random token and stores only its hash.
+func (s *AuthService) issueOneTimeToken(ctx context.Context, userID int64,
+	purpose string, ttl time.Duration) (string, error) {
+	token, generateErr := securetoken.Generate(securetoken.DefaultSize)
+	if generateErr != nil {
+		return "", generateErr
+	}
+
+	createTokenErr := s.repo.CreateOneTimeToken(ctx, entity.OneTimeToken{
+		TokenHash: securetoken.Hash(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if createTokenErr != nil {
+		return "", createTokenErr
+	}
+
+	return token, nil
+}
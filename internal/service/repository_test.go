@@ -0,0 +1,683 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer     = "test-auth"
+	testAccessTTL  = time.Minute * 10
+	testRefreshTTL = time.Hour
+)
+
+// memoryRepository keeps in memory what flows under test touch.
+// Other methods come from nil embedded interface and panic when called.
+type memoryRepository struct {
+	service.AuthRepository
+
+	mu            sync.Mutex
+	users         map[int64]entity.AuthUser
+	accesses      map[int64]entity.UserAccess
+	sessions      map[uuid.UUID]entity.Session
+	passkeys      []entity.PasskeyCredential
+	ceremonies    map[uuid.UUID]entity.PasskeyCeremony
+	auditEvents   []entity.AuditEvent
+	clients       map[string]entity.OAuthClient
+	consents      map[string]entity.OAuthConsent
+	codes         map[string]entity.AuthorizationCode
+	loginAttempts map[string]entity.LoginAttempt
+	oneTimeTokens map[string]entity.OneTimeToken
+	totps         map[int64]entity.UserTOTP
+	recoveryCodes map[string]bool
+	apiKeys       map[uuid.UUID]entity.APIKey
+	devices       map[string]entity.DeviceAuthorization
+}
+
+func newMemoryRepository(users ...entity.AuthUser) *memoryRepository {
+	repo := &memoryRepository{
+		users:      make(map[int64]entity.AuthUser),
+		accesses:   make(map[int64]entity.UserAccess),
+		sessions:   make(map[uuid.UUID]entity.Session),
+		ceremonies: make(map[uuid.UUID]entity.PasskeyCeremony),
+		clients:    make(map[string]entity.OAuthClient),
+		consents:   make(map[string]entity.OAuthConsent),
+		codes:      make(map[string]entity.AuthorizationCode),
+
+		loginAttempts: make(map[string]entity.LoginAttempt),
+		oneTimeTokens: make(map[string]entity.OneTimeToken),
+		totps:         make(map[int64]entity.UserTOTP),
+		recoveryCodes: make(map[string]bool),
+		apiKeys:       make(map[uuid.UUID]entity.APIKey),
+		devices:       make(map[string]entity.DeviceAuthorization),
+	}
+	for _, user := range users {
+		repo.users[user.ID] = user
+	}
+
+	return repo
+}
+
+func (r *memoryRepository) GetUserByEmail(_ context.Context, email string) (entity.AuthUser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+
+	return entity.AuthUser{}, service.ErrEntityNotFound
+}
+
+func (r *memoryRepository) GetUserByID(_ context.Context, id int64) (entity.AuthUser, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return entity.AuthUser{}, service.ErrEntityNotFound
+	}
+
+	return user, nil
+}
+
+func (r *memoryRepository) GetUserAccess(_ context.Context, userID int64) (entity.UserAccess, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.accesses[userID], nil
+}
+
+func (r *memoryRepository) CreateSession(_ context.Context, session entity.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[session.ID] = session
+
+	return nil
+}
+
+func (r *memoryRepository) HasActiveSession(_ context.Context, familyID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, session := range r.sessions {
+		if session.FamilyID == familyID && session.ExpiresAt.After(time.Now()) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *memoryRepository) AppendAuditEvent(_ context.Context, event entity.AuditEvent) (entity.AuditEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = int64(len(r.auditEvents) + 1)
+	r.auditEvents = append(r.auditEvents, event)
+
+	return event, nil
+}
+
+func (r *memoryRepository) GetUserPasskeys(_ context.Context, userID int64) ([]entity.PasskeyCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var passkeys []entity.PasskeyCredential
+	for _, passkey := range r.passkeys {
+		if passkey.UserID == userID {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+
+	return passkeys, nil
+}
+
+func (r *memoryRepository) CreatePasskey(_ context.Context, passkey entity.PasskeyCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.passkeys = append(r.passkeys, passkey)
+
+	return nil
+}
+
+func (r *memoryRepository) UpdatePasskeyUsage(_ context.Context, passkey entity.PasskeyCredential, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.passkeys {
+		if bytes.Equal(r.passkeys[i].ID, passkey.ID) {
+			r.passkeys[i].SignCount = passkey.SignCount
+			r.passkeys[i].LastUsedAt = &usedAt
+			return nil
+		}
+	}
+
+	return service.ErrEntityNotFound
+}
+
+func (r *memoryRepository) CreatePasskeyCeremony(_ context.Context, ceremony entity.PasskeyCeremony) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ceremonies[ceremony.ID] = ceremony
+
+	return nil
+}
+
+func (r *memoryRepository) ConsumePasskeyCeremony(_ context.Context, ceremonyID uuid.UUID, purpose string,
+	now time.Time) (entity.PasskeyCeremony, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ceremony, ok := r.ceremonies[ceremonyID]
+	if !ok || ceremony.Purpose != purpose || !ceremony.ExpiresAt.After(now) {
+		return entity.PasskeyCeremony{}, service.ErrEntityNotFound
+	}
+	delete(r.ceremonies, ceremonyID)
+
+	return ceremony, nil
+}
+
+func (r *memoryRepository) CreateOAuthClient(_ context.Context, client entity.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clients[client.ID] = client
+
+	return nil
+}
+
+func (r *memoryRepository) GetOAuthClient(_ context.Context, clientID string) (entity.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, ok := r.clients[clientID]
+	if !ok {
+		return entity.OAuthClient{}, service.ErrEntityNotFound
+	}
+
+	return client, nil
+}
+
+func (r *memoryRepository) GetOAuthConsent(_ context.Context, userID int64, clientID string) (entity.OAuthConsent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	consent, ok := r.consents[consentKey(userID, clientID)]
+	if !ok {
+		return entity.OAuthConsent{}, service.ErrEntityNotFound
+	}
+
+	return consent, nil
+}
+
+func (r *memoryRepository) SaveOAuthConsent(_ context.Context, consent entity.OAuthConsent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.consents[consentKey(consent.UserID, consent.ClientID)] = consent
+
+	return nil
+}
+
+func (r *memoryRepository) CreateAuthorizationCode(_ context.Context, code entity.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.codes[code.CodeHash] = code
+
+	return nil
+}
+
+func (r *memoryRepository) ConsumeAuthorizationCode(_ context.Context, codeHash string,
+	now time.Time) (entity.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	code, ok := r.codes[codeHash]
+	if !ok || !code.ExpiresAt.After(now) {
+		return entity.AuthorizationCode{}, service.ErrEntityNotFound
+	}
+	delete(r.codes, codeHash)
+
+	return code, nil
+}
+
+func consentKey(userID int64, clientID string) string {
+	return fmt.Sprintf("%d:%s", userID, clientID)
+}
+
+func (r *memoryRepository) GetLoginAttempts(_ context.Context, keys []string) ([]entity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var attempts []entity.LoginAttempt
+	for _, key := range keys {
+		if attempt, ok := r.loginAttempts[key]; ok {
+			attempts = append(attempts, attempt)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (r *memoryRepository) ReserveLoginAttempt(_ context.Context, key string, attemptAt time.Time,
+	windowStart time.Time, blockDelay func(failures int) time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.loginAttempts[key]
+	if ok && attempt.BlockedUntil != nil && attempt.BlockedUntil.After(attemptAt) {
+		return 0, service.ErrEntityNotFound
+	}
+	if !ok || attempt.LastFailureAt.Before(windowStart) {
+		attempt = entity.LoginAttempt{Key: key}
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = attemptAt
+	if delay := blockDelay(attempt.Failures); delay > 0 {
+		blockedUntil := attemptAt.Add(delay)
+		attempt.BlockedUntil = &blockedUntil
+	}
+	r.loginAttempts[key] = attempt
+
+	return attempt.Failures, nil
+}
+
+func (r *memoryRepository) ReleaseLoginAttempt(_ context.Context, key string, liftBlock bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.loginAttempts[key]
+	if !ok {
+		return nil
+	}
+	if attempt.Failures > 0 {
+		attempt.Failures--
+	}
+	if liftBlock {
+		attempt.BlockedUntil = nil
+	}
+	r.loginAttempts[key] = attempt
+
+	return nil
+}
+
+func (r *memoryRepository) DeleteLoginAttempts(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.loginAttempts, key)
+
+	return nil
+}
+
+// liftBlocks ends every block as if its delay has passed. Failures are still counted.
+func (r *memoryRepository) liftBlocks() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, attempt := range r.loginAttempts {
+		attempt.BlockedUntil = nil
+		r.loginAttempts[key] = attempt
+	}
+}
+
+func (r *memoryRepository) failures(key string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.loginAttempts[key].Failures
+}
+
+func (r *memoryRepository) CreateOneTimeToken(_ context.Context, token entity.OneTimeToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.oneTimeTokens[token.TokenHash] = token
+
+	return nil
+}
+
+func (r *memoryRepository) GetOneTimeToken(_ context.Context, tokenHash string, purpose string,
+	now time.Time) (entity.OneTimeToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.oneTimeTokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || !token.ExpiresAt.After(now) {
+		return entity.OneTimeToken{}, service.ErrEntityNotFound
+	}
+
+	return token, nil
+}
+
+func (r *memoryRepository) ConsumeOneTimeToken(_ context.Context, tokenHash string, purpose string,
+	usedAt time.Time) (entity.OneTimeToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.oneTimeTokens[tokenHash]
+	if !ok || token.Purpose != purpose || token.UsedAt != nil || !token.ExpiresAt.After(usedAt) {
+		return entity.OneTimeToken{}, service.ErrEntityNotFound
+	}
+	token.UsedAt = &usedAt
+	r.oneTimeTokens[tokenHash] = token
+
+	return token, nil
+}
+
+func (r *memoryRepository) GetUserTOTP(_ context.Context, userID int64) (entity.UserTOTP, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userTOTP, ok := r.totps[userID]
+	if !ok {
+		return entity.UserTOTP{}, service.ErrEntityNotFound
+	}
+
+	return userTOTP, nil
+}
+
+func (r *memoryRepository) SaveUnconfirmedTOTP(_ context.Context, userID int64, sealedSecret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.totps[userID].Enabled() {
+		return service.ErrTOTPConfirmed
+	}
+	r.totps[userID] = entity.UserTOTP{UserID: userID, Secret: sealedSecret, Encrypted: true, CreatedAt: time.Now()}
+
+	return nil
+}
+
+func (r *memoryRepository) EncryptUserTOTP(_ context.Context, userID int64, sealedSecret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userTOTP, ok := r.totps[userID]
+	if ok && !userTOTP.Encrypted {
+		userTOTP.Secret = sealedSecret
+		userTOTP.Encrypted = true
+		r.totps[userID] = userTOTP
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) ConfirmUserTOTP(_ context.Context, userID int64, step int64, confirmedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userTOTP, ok := r.totps[userID]
+	if !ok || userTOTP.Enabled() {
+		return service.ErrTOTPConfirmed
+	}
+	userTOTP.ConfirmedAt = &confirmedAt
+	userTOTP.LastUsedStep = step
+	r.totps[userID] = userTOTP
+
+	return nil
+}
+
+func (r *memoryRepository) UseTOTPStep(_ context.Context, userID int64, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	userTOTP, ok := r.totps[userID]
+	if !ok || userTOTP.LastUsedStep >= step {
+		return service.ErrTOTPCodeUsed
+	}
+	userTOTP.LastUsedStep = step
+	r.totps[userID] = userTOTP
+
+	return nil
+}
+
+func (r *memoryRepository) ReplaceRecoveryCodes(_ context.Context, userID int64, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.recoveryCodes {
+		if strings.HasPrefix(key, recoveryCodeKey(userID, "")) {
+			delete(r.recoveryCodes, key)
+		}
+	}
+	for _, codeHash := range codeHashes {
+		r.recoveryCodes[recoveryCodeKey(userID, codeHash)] = false
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) UseRecoveryCode(_ context.Context, userID int64, codeHash string, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	used, ok := r.recoveryCodes[recoveryCodeKey(userID, codeHash)]
+	if !ok || used {
+		return service.ErrEntityNotFound
+	}
+	r.recoveryCodes[recoveryCodeKey(userID, codeHash)] = true
+
+	return nil
+}
+
+func recoveryCodeKey(userID int64, codeHash string) string {
+	return fmt.Sprintf("%d:%s", userID, codeHash)
+}
+
+func (r *memoryRepository) CreateAPIKey(_ context.Context, key entity.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.apiKeys[key.ID] = key
+
+	return nil
+}
+
+func (r *memoryRepository) GetAPIKeyByHash(_ context.Context, keyHash string) (entity.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.apiKeys {
+		if key.KeyHash == keyHash {
+			return key, nil
+		}
+	}
+
+	return entity.APIKey{}, service.ErrEntityNotFound
+}
+
+func (r *memoryRepository) TouchAPIKey(_ context.Context, keyID uuid.UUID, usedAt time.Time, usedBefore time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[keyID]
+	if ok && (key.LastUsedAt == nil || key.LastUsedAt.Before(usedBefore)) {
+		key.LastUsedAt = &usedAt
+		r.apiKeys[keyID] = key
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) DeleteUserAPIKey(_ context.Context, userID int64, keyID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[keyID]
+	if !ok || key.UserID != userID {
+		return service.ErrEntityNotFound
+	}
+	delete(r.apiKeys, keyID)
+
+	return nil
+}
+
+func (r *memoryRepository) CreateDeviceAuthorization(_ context.Context, authorization entity.DeviceAuthorization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.devices[authorization.DeviceCodeHash] = authorization
+
+	return nil
+}
+
+func (r *memoryRepository) GetDeviceAuthorization(_ context.Context,
+	deviceCodeHash string) (entity.DeviceAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	authorization, ok := r.devices[deviceCodeHash]
+	if !ok {
+		return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+	}
+
+	return authorization, nil
+}
+
+func (r *memoryRepository) GetPendingDeviceAuthorization(_ context.Context, userCode string,
+	now time.Time) (entity.DeviceAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, authorization := range r.devices {
+		if authorization.UserCode == userCode && authorization.Status == entity.DevicePending &&
+			authorization.ExpiresAt.After(now) {
+			return authorization, nil
+		}
+	}
+
+	return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+}
+
+func (r *memoryRepository) ResolveDeviceAuthorization(_ context.Context, userCode string,
+	resolution entity.DeviceAuthorization, now time.Time) (entity.DeviceAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for deviceCodeHash, authorization := range r.devices {
+		if authorization.UserCode == userCode && authorization.Status == entity.DevicePending &&
+			authorization.ExpiresAt.After(now) {
+			authorization.Status = resolution.Status
+			authorization.UserID = resolution.UserID
+			authorization.AuthTime = resolution.AuthTime
+			authorization.AMR = resolution.AMR
+			r.devices[deviceCodeHash] = authorization
+
+			return authorization, nil
+		}
+	}
+
+	return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+}
+
+func (r *memoryRepository) RecordDevicePoll(_ context.Context, deviceCodeHash string, polledAt time.Time,
+	pollInterval int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if authorization, ok := r.devices[deviceCodeHash]; ok {
+		authorization.LastPolledAt = &polledAt
+		authorization.PollInterval = pollInterval
+		r.devices[deviceCodeHash] = authorization
+	}
+
+	return nil
+}
+
+func (r *memoryRepository) ConsumeDeviceAuthorization(_ context.Context,
+	deviceCodeHash string) (entity.DeviceAuthorization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	authorization, ok := r.devices[deviceCodeHash]
+	if !ok || authorization.Status == entity.DevicePending {
+		return entity.DeviceAuthorization{}, service.ErrEntityNotFound
+	}
+	delete(r.devices, deviceCodeHash)
+
+	return authorization, nil
+}
+
+// rewindDevicePolls moves last polls to the past as if device waited d.
+func (r *memoryRepository) rewindDevicePolls(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for deviceCodeHash, authorization := range r.devices {
+		if authorization.LastPolledAt != nil {
+			polledAt := authorization.LastPolledAt.Add(-d)
+			authorization.LastPolledAt = &polledAt
+			r.devices[deviceCodeHash] = authorization
+		}
+	}
+}
+
+// plainHasher keeps passwords readable, so tests don't spend time on real hashing.
+type plainHasher struct{}
+
+func (plainHasher) Hash(toHash string) (string, error) {
+	return "hashed:" + toHash, nil
+}
+
+func (plainHasher) CompareHashes(notHashed string, hashed string) bool {
+	return "hashed:"+notHashed == hashed
+}
+
+// newTestService wires service to memory repository and real token generator.
+func newTestService(t *testing.T, repo *memoryRepository, accessKey *tokengen.SigningKey,
+	passkeys service.PasskeyProvider, cfg service.Config) (*service.AuthService, *tokengen.JWTGenerator) {
+	t.Helper()
+
+	refreshKey := tokengen.NewHMACSigningKey("", "refresh-secret")
+	tokenGenerator := tokengen.NewJWTGenerator(tokengen.NewKeyRing(accessKey), tokengen.NewKeyRing(refreshKey),
+		testIssuer, testAccessTTL, testRefreshTTL)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return service.NewAuthService(logger, repo, plainHasher{}, tokenGenerator, nil, passkeys, cfg), tokenGenerator
+}
+
+// loginAs issues access token of user as first-party login would.
+func loginAs(t *testing.T, tokenGenerator *tokengen.JWTGenerator, repo *memoryRepository,
+	user entity.AuthUser) string {
+	t.Helper()
+
+	session := entity.Session{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		FamilyID:  uuid.New(),
+		CreatedAt: time.Now(),
+		AuthTime:  time.Now(),
+		AMR:       entity.PasswordAMR,
+	}
+	tokenPair, generateErr := tokenGenerator.GenerateTokenPair(user, session, entity.UserAccess{})
+	if generateErr != nil {
+		t.Fatalf("can't generate token pair: %v", generateErr)
+	}
+	session.ExpiresAt = tokenPair.Refresh.ExpiresAt
+	if createErr := repo.CreateSession(context.Background(), session); createErr != nil {
+		t.Fatalf("can't create session: %v", createErr)
+	}
+
+	return tokenPair.Access.Token
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SaveRole creates role or replaces description and permissions of existing one.
+// Users get new permissions in tokens issued after the change.
+func (s *AuthService) SaveRole(ctx context.Context, accessToken string, role entity.Role) error {
+	details := fmt.Sprintf("role %q with permissions %q", role.Name, role.Permissions)
+	return s.runAdminAction(ctx, accessToken, entity.ManageRolesPermission, saveRoleMethod, 0, details, func() error {
+		role.Name = strings.TrimSpace(role.Name)
+		if !isValidAccessName(role.Name) {
+			s.logger.Printf(logPattern, requestUUID(ctx), saveRoleMethod, autherrors.InvalidRoleName)
+			return autherrors.NewStatusError(autherrors.InvalidRoleName, nil)
+		}
+
+		permissions, normalizeErr := normalizePermissions(role.Permissions)
+		if normalizeErr != nil {
+			s.logger.Printf(logPattern, requestUUID(ctx), saveRoleMethod, normalizeErr)
+			return autherrors.NewStatusError(autherrors.InvalidPermission, normalizeErr)
+		}
+		role.Permissions = permissions
+
+		if role.Name == entity.AdminRole && !(entity.UserAccess{Permissions: permissions}).HasPermission(entity.ManageRolesPermission) {
+			s.logger.Printf(logPattern, requestUUID(ctx), saveRoleMethod, autherrors.AdminRoleLocked)
+			return autherrors.NewStatusError(autherrors.AdminRoleLocked, nil)
+		}
+
+		if saveRoleErr := s.repo.SaveRole(ctx, role); saveRoleErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), saveRoleMethod, saveRoleErr)
+			return fmt.Errorf("can't save role: %w", saveRoleErr)
+		}
+
+		return nil
+	})
+}
+
+func (s *AuthService) ListRoles(ctx context.Context, accessToken string) ([]entity.Role, error) {
+	var roles []entity.Role
+	actionErr := s.runAdminAction(ctx, accessToken, entity.ManageRolesPermission, listRolesMethod, 0, "", func() error {
+		var getRolesErr error
+		roles, getRolesErr = s.repo.GetRoles(ctx)
+		if getRolesErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), listRolesMethod, getRolesErr)
+			return fmt.Errorf("can't get roles: %w", getRolesErr)
+		}
+
+		return nil
+	})
+
+	return roles, actionErr
+}
+
+func (s *AuthService) AssignRole(ctx context.Context, accessToken string, email string, role string) error {
+	details := fmt.Sprintf("role %q to %s", role, email)
+	return s.runAdminAction(ctx, accessToken, entity.ManageRolesPermission, assignRoleMethod, 0, details, func() error {
+		user, getUserErr := s.repo.GetUserByEmail(ctx, email)
+		if errors.Is(getUserErr, ErrEntityNotFound) {
+			s.logger.Printf(logPattern, requestUUID(ctx), assignRoleMethod, autherrors.UserNotExists)
+			return autherrors.NewStatusError(autherrors.UserNotExists, nil)
+		}
+		if getUserErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), assignRoleMethod, getUserErr)
+			return fmt.Errorf("can't get user: %w", getUserErr)
+		}
+
+		_, getRoleErr := s.repo.GetRole(ctx, role)
+		if errors.Is(getRoleErr, ErrEntityNotFound) {
+			s.logger.Printf(logPattern, requestUUID(ctx), assignRoleMethod, autherrors.RoleNotExists)
+			return autherrors.NewStatusError(autherrors.RoleNotExists, nil)
+		}
+		if getRoleErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), assignRoleMethod, getRoleErr)
+			return fmt.Errorf("can't get role: %w", getRoleErr)
+		}
+
+		if assignRoleErr := s.repo.AssignUserRole(ctx, user.ID, role); assignRoleErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), assignRoleMethod, assignRoleErr)
+			return fmt.Errorf("can't assign role: %w", assignRoleErr)
+		}
+
+		return nil
+	})
+}
+
+func (s *AuthService) RevokeRole(ctx context.Context, accessToken string, email string, role string) error {
+	details := fmt.Sprintf("role %q from %s", role, email)
+	return s.runAdminAction(ctx, accessToken, entity.ManageRolesPermission, revokeRoleMethod, 0, details, func() error {
+		user, getUserErr := s.repo.GetUserByEmail(ctx, email)
+		if errors.Is(getUserErr, ErrEntityNotFound) {
+			s.logger.Printf(logPattern, requestUUID(ctx), revokeRoleMethod, autherrors.UserNotExists)
+			return autherrors.NewStatusError(autherrors.UserNotExists, nil)
+		}
+		if getUserErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), revokeRoleMethod, getUserErr)
+			return fmt.Errorf("can't get user: %w", getUserErr)
+		}
+
+		revokeRoleErr := s.repo.RevokeUserRole(ctx, user.ID, role, role == entity.AdminRole)
+		if errors.Is(revokeRoleErr, ErrEntityNotFound) {
+			s.logger.Printf(logPattern, requestUUID(ctx), revokeRoleMethod, autherrors.RoleNotAssigned)
+			return autherrors.NewStatusError(autherrors.RoleNotAssigned, nil)
+		}
+		if errors.Is(revokeRoleErr, ErrLastRoleHolder) {
+			s.logger.Printf(logPattern, requestUUID(ctx), revokeRoleMethod, autherrors.AdminRoleLocked)
+			return autherrors.NewStatusError(autherrors.AdminRoleLocked, revokeRoleErr)
+		}
+		if revokeRoleErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), revokeRoleMethod, revokeRoleErr)
+			return fmt.Errorf("can't revoke role: %w", revokeRoleErr)
+		}
+
+		return nil
+	})
+}
+
+// authorize resolves the user that owns access token and checks that they have permission.
+// Permissions are read from database, not from token.
+func (s *AuthService) authorize(ctx context.Context, accessToken string,
+	permission string, method string) (entity.AuthUser, error) {
+	user, _, authErr := s.authenticate(ctx, accessToken, method)
+	if authErr != nil {
+		return entity.AuthUser{}, authErr
+	}
+
+	access, getAccessErr := s.repo.GetUserAccess(ctx, user.ID)
+	if getAccessErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, getAccessErr)
+		return entity.AuthUser{}, fmt.Errorf("can't get user's permissions: %w", getAccessErr)
+	}
+	if !access.HasPermission(permission) {
+		s.logger.Printf(logPattern, requestUUID(ctx), method, autherrors.AccessDenied)
+		return entity.AuthUser{}, autherrors.NewStatusError(autherrors.AccessDenied, fmt.Errorf("%s is required", permission))
+	}
+
+	return user, nil
+}
+
+// normalizePermissions drops duplicates and sorts permissions, so tokens have stable scope.
+func normalizePermissions(permissions []string) ([]string, error) {
+	unique := make(map[string]struct{}, len(permissions))
+	normalized := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		permission = strings.TrimSpace(permission)
+		if !isValidAccessName(permission) {
+			return nil, fmt.Errorf("invalid permission %q", permission)
+		}
+		if _, ok := unique[permission]; ok {
+			continue
+		}
+
+		unique[permission] = struct{}{}
+		normalized = append(normalized, permission)
+	}
+	sort.Strings(normalized)
+
+	return normalized, nil
+}
+
+// isValidAccessName checks role or permission name: it can't contain spaces,
+// because permissions are joined with spaces in token scope.
+func isValidAccessName(name string) bool {
+	return name != "" && strings.IndexFunc(name, unicode.IsSpace) == -1
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	emailAttemptPrefix = "email:"
+	ipAttemptPrefix    = "ip:"
+	mfaAttemptPrefix   = "mfa:"
+	emailSendPrefix    = "send-email:"
+	ipSendPrefix       = "send-ip:"
+	userCodePrefix     = "user-code:"
+)
+
+// loginAttempt is attempt reserved by checkLoginThrottle: keys it counted and whether it blocked them.
+type loginAttempt struct {
+	keys    []string
+	blocked map[string]bool
+}
+
+// checkLoginThrottle refuses login before password is compared if email or client IP is blocked.
+// Attempt is counted as failure up front and blocks keys as failure would, so parallel guesses
+// can't all pass the check. passLoginAttempt takes it back once login succeeds.
+func (s *AuthService) checkLoginThrottle(ctx context.Context, email string) (loginAttempt, error) {
+	now := time.Now()
+	windowStart := now.Add(-s.cfg.LoginThrottle.Window)
+	attempt := loginAttempt{blocked: make(map[string]bool)}
+
+	for _, key := range loginAttemptKeys(ctx, email) {
+		blockDelay := func(failures int) time.Duration {
+			return s.cfg.LoginThrottle.blockDelay(failures, s.cfg.LoginThrottle.LockoutThreshold)
+		}
+		if strings.HasPrefix(key, ipAttemptPrefix) {
+			blockDelay = func(failures int) time.Duration {
+				return s.cfg.LoginThrottle.lockoutDelay(failures, s.cfg.LoginThrottle.IPLockoutThreshold)
+			}
+		}
+
+		failures, reserveErr := s.repo.ReserveLoginAttempt(ctx, key, now, windowStart, blockDelay)
+		if reserveErr == nil {
+			attempt.keys = append(attempt.keys, key)
+			attempt.blocked[key] = blockDelay(failures) > 0
+			continue
+		}
+
+		s.releaseLoginAttempt(ctx, attempt)
+		if !errors.Is(reserveErr, ErrEntityNotFound) {
+			s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, reserveErr)
+			return loginAttempt{}, fmt.Errorf("can't reserve login attempt: %w", reserveErr)
+		}
+
+		// Block may have just ended, but this attempt was refused anyway.
+		if throttleErr := s.throttledError(ctx, []string{key}); throttleErr != nil {
+			return loginAttempt{}, throttleErr
+		}
+		return loginAttempt{}, autherrors.NewRetryableStatusError(autherrors.TooManyAttempts, time.Second)
+	}
+
+	return attempt, nil
+}
+
+// checkLoginBlocked refuses request if email or client IP is blocked without counting an attempt.
+func (s *AuthService) checkLoginBlocked(ctx context.Context, email string) error {
+	return s.throttledError(ctx, loginAttemptKeys(ctx, email))
+}
+
+// reserveUserAttempt is checkLoginThrottle for secrets guessed on behalf of known user:
+// second factor codes (mfaAttemptPrefix) and user codes of other devices (userCodePrefix,
+// RFC 8628, section 5.1). Attempt is counted as failure up front and refused while user is blocked.
+func (s *AuthService) reserveUserAttempt(ctx context.Context, prefix string, userID int64,
+	lockoutThreshold int, method string) (loginAttempt, error) {
+	key := prefix + strconv.FormatInt(userID, 10)
+	now := time.Now()
+	blockDelay := func(failures int) time.Duration {
+		return s.cfg.LoginThrottle.blockDelay(failures, lockoutThreshold)
+	}
+
+	failures, reserveErr := s.repo.ReserveLoginAttempt(ctx, key, now, now.Add(-s.cfg.LoginThrottle.Window), blockDelay)
+	if errors.Is(reserveErr, ErrEntityNotFound) {
+		if throttleErr := s.throttledError(ctx, []string{key}); throttleErr != nil {
+			return loginAttempt{}, throttleErr
+		}
+		return loginAttempt{}, autherrors.NewRetryableStatusError(autherrors.TooManyAttempts, time.Second)
+	}
+	if reserveErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, reserveErr)
+		return loginAttempt{}, fmt.Errorf("can't reserve attempt: %w", reserveErr)
+	}
+
+	return loginAttempt{keys: []string{key}, blocked: map[string]bool{key: blockDelay(failures) > 0}}, nil
+}
+
+// reserveMailSend counts mail of purpose per email and per client IP within login attempt window
+// and refuses it once either limit is reached, so nobody can flood a mailbox or the mailer.
+// Mail is counted whether account exists or not. Zero limit is not checked.
+func (s *AuthService) reserveMailSend(ctx context.Context, purpose string, email string,
+	maxPerEmail int, maxPerIP int, method string) error {
+	now := time.Now()
+	windowStart := now.Add(-s.cfg.LoginThrottle.Window)
+
+	keys := []string{emailSendPrefix + purpose + ":" + normalizeEmail(email)}
+	if ip := clientIP(ctx); ip != "" {
+		keys = append(keys, ipSendPrefix+purpose+":"+ip)
+	}
+
+	for _, key := range keys {
+		limit := maxPerEmail
+		if strings.HasPrefix(key, ipSendPrefix) {
+			limit = maxPerIP
+		}
+		if limit <= 0 {
+			continue
+		}
+
+		_, reserveErr := s.repo.ReserveLoginAttempt(ctx, key, now, windowStart, func(sends int) time.Duration {
+			if sends >= limit {
+				return s.cfg.LoginThrottle.Window
+			}
+			return 0
+		})
+		if errors.Is(reserveErr, ErrEntityNotFound) {
+			if throttleErr := s.throttledError(ctx, []string{key}); throttleErr != nil {
+				return throttleErr
+			}
+			return autherrors.NewRetryableStatusError(autherrors.TooManyAttempts, time.Second)
+		}
+		if reserveErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), method, reserveErr)
+			return fmt.Errorf("can't reserve mail: %w", reserveErr)
+		}
+	}
+
+	return nil
+}
+
+// throttledError tells how long client must wait until keys are unblocked, nil if they are not blocked.
+func (s *AuthService) throttledError(ctx context.Context, keys []string) error {
+	attempts, getAttemptsErr := s.repo.GetLoginAttempts(ctx, keys)
+	if getAttemptsErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, getAttemptsErr)
+		return fmt.Errorf("can't get login attempts: %w", getAttemptsErr)
+	}
+
+	var retryAfter time.Duration
+	now := time.Now()
+	for _, attempt := range attempts {
+		if attempt.BlockedUntil != nil && attempt.BlockedUntil.Sub(now) > retryAfter {
+			retryAfter = attempt.BlockedUntil.Sub(now)
+		}
+	}
+
+	if retryAfter > 0 {
+		s.logger.Printf(logPattern, requestUUID(ctx), loginMethod, autherrors.TooManyAttempts)
+		return autherrors.NewRetryableStatusError(autherrors.TooManyAttempts, retryAfter.Round(time.Second)+time.Second)
+	}
+
+	return nil
+}
+
+// passLoginAttempt forgets failures of email and takes attempt back from client IP.
+func (s *AuthService) passLoginAttempt(ctx context.Context, email string, attempt loginAttempt) {
+	s.resetLoginFailures(ctx, email)
+
+	for _, key := range attempt.keys {
+		if strings.HasPrefix(key, emailAttemptPrefix) {
+			continue
+		}
+		if releaseErr := s.repo.ReleaseLoginAttempt(ctx, key, attempt.blocked[key]); releaseErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, releaseErr)
+		}
+	}
+}
+
+func (s *AuthService) releaseLoginAttempt(ctx context.Context, attempt loginAttempt) {
+	for _, key := range attempt.keys {
+		if releaseErr := s.repo.ReleaseLoginAttempt(ctx, key, attempt.blocked[key]); releaseErr != nil {
+			s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, releaseErr)
+		}
+	}
+}
+
+func (s *AuthService) resetLoginFailures(ctx context.Context, email string) {
+	if deleteErr := s.repo.DeleteLoginAttempts(ctx, emailAttemptPrefix+normalizeEmail(email)); deleteErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, deleteErr)
+	}
+}
+
+// resetUserFailures forgets failures counted by reserveUserAttempt.
+func (s *AuthService) resetUserFailures(ctx context.Context, prefix string, userID int64) {
+	if deleteErr := s.repo.DeleteLoginAttempts(ctx, prefix+strconv.FormatInt(userID, 10)); deleteErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), loginMethod, deleteErr)
+	}
+}
+
+// blockDelay grows exponentially once failures exceed BackoffAfter and turns into lockout at threshold.
+func (c LoginThrottleConfig) blockDelay(failures int, lockoutThreshold int) time.Duration {
+	if failures >= lockoutThreshold {
+		return c.LockoutDuration
+	}
+	if failures < c.BackoffAfter {
+		return 0
+	}
+
+	delay := c.BackoffBase
+	for i := c.BackoffAfter; i < failures && delay < c.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > c.BackoffMax {
+		delay = c.BackoffMax
+	}
+
+	return delay
+}
+
+// lockoutDelay is blockDelay without backoff.
+func (c LoginThrottleConfig) lockoutDelay(failures int, lockoutThreshold int) time.Duration {
+	if failures >= lockoutThreshold {
+		return c.LockoutDuration
+	}
+
+	return 0
+}
+
+// loginAttemptKeys doesn't depend on whether account exists, so lockout doesn't reveal it.
+func loginAttemptKeys(ctx context.Context, email string) []string {
+	keys := []string{emailAttemptPrefix + normalizeEmail(email)}
+	if ip := clientIP(ctx); ip != "" {
+		keys = append(keys, ipAttemptPrefix+ip)
+	}
+
+	return keys
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (s *AuthService) clearStaleLoginAttempts(ctx context.Context) {
+	if deleteErr := s.repo.DeleteStaleLoginAttempts(ctx, time.Now().Add(-s.cfg.LoginThrottle.Window)); deleteErr != nil {
+		s.logger.Warnf("can't clear stale login attempts: %v", deleteErr)
+	}
+}
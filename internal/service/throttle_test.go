@@ -0,0 +1,214 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"github.com/google/uuid"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testPassword = "correct-horse-battery"
+
+var testThrottle = service.LoginThrottleConfig{
+	Window:             time.Hour,
+	BackoffAfter:       100,
+	BackoffBase:        time.Minute,
+	BackoffMax:         time.Hour,
+	LockoutThreshold:   3,
+	IPLockoutThreshold: 5,
+	LockoutDuration:    time.Minute * 15,
+}
+
+type throttleFixture struct {
+	svc            *service.AuthService
+	repo           *memoryRepository
+	tokenGenerator *tokengen.JWTGenerator
+}
+
+func newThrottleFixture(t *testing.T, throttle service.LoginThrottleConfig, users ...entity.AuthUser) throttleFixture {
+	t.Helper()
+
+	for i := range users {
+		users[i].Password = "hashed:" + testPassword
+		users[i].Status = entity.UserActive
+	}
+	repo := newMemoryRepository(users...)
+	svc, tokenGenerator := newTestService(t, repo, tokengen.NewHMACSigningKey("", "access-secret"), nil, service.Config{
+		LoginThrottle: throttle,
+		MFA:           testMFA,
+	})
+
+	return throttleFixture{svc: svc, repo: repo, tokenGenerator: tokenGenerator}
+}
+
+func fromIP(ip string) context.Context {
+	return context.WithValue(context.Background(), "client_ip", ip)
+}
+
+func (f throttleFixture) login(ctx context.Context, email string, password string) (entity.LoginResult, error) {
+	return f.svc.Login(ctx, entity.AuthUser{Email: email, Password: password}, uuid.NewString())
+}
+
+func requireThrottled(t *testing.T, err error, minRetryAfter time.Duration) {
+	t.Helper()
+
+	if !autherrors.Is(err, autherrors.TooManyAttempts) {
+		t.Fatalf("attempt isn't throttled: %v", err)
+	}
+	if retryAfter, ok := autherrors.RetryAfter(err); !ok || retryAfter < minRetryAfter {
+		t.Fatalf("retry after %s, want at least %s", retryAfter, minRetryAfter)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	f := newThrottleFixture(t, testThrottle, entity.AuthUser{ID: 1, Email: "user@example.com"})
+	ctx := fromIP("10.0.0.1")
+
+	for i := 1; i <= testThrottle.LockoutThreshold; i++ {
+		if _, loginErr := f.login(ctx, "user@example.com", "wrong"); !autherrors.Is(loginErr, autherrors.UserInvalidPassword) {
+			t.Fatalf("failure %d: %v", i, loginErr)
+		}
+	}
+
+	_, loginErr := f.login(fromIP("10.0.0.2"), "User@Example.com ", testPassword)
+	requireThrottled(t, loginErr, testThrottle.LockoutDuration-time.Second)
+
+	f.repo.liftBlocks()
+	f.repo.mu.Lock()
+	for key, attempt := range f.repo.loginAttempts {
+		attempt.LastFailureAt = attempt.LastFailureAt.Add(-testThrottle.Window - time.Second)
+		f.repo.loginAttempts[key] = attempt
+	}
+	f.repo.mu.Unlock()
+
+	if _, loginErr := f.login(ctx, "user@example.com", testPassword); loginErr != nil {
+		t.Fatalf("login after window: %v", loginErr)
+	}
+	if failures := f.repo.failures("email:user@example.com"); failures != 0 {
+		t.Fatalf("successful login left %d failures", failures)
+	}
+}
+
+func TestLoginBackoff(t *testing.T) {
+	throttle := testThrottle
+	throttle.BackoffAfter = 2
+	throttle.LockoutThreshold = 10
+	f := newThrottleFixture(t, throttle, entity.AuthUser{ID: 1, Email: "user@example.com"})
+	ctx := fromIP("10.0.0.1")
+
+	for i := 1; i <= throttle.BackoffAfter; i++ {
+		if _, loginErr := f.login(ctx, "user@example.com", "wrong"); !autherrors.Is(loginErr, autherrors.UserInvalidPassword) {
+			t.Fatalf("failure %d: %v", i, loginErr)
+		}
+	}
+
+	for _, wantDelay := range []time.Duration{throttle.BackoffBase, throttle.BackoffBase * 2, throttle.BackoffBase * 4} {
+		_, loginErr := f.login(ctx, "user@example.com", testPassword)
+		requireThrottled(t, loginErr, wantDelay-time.Second)
+		if retryAfter, _ := autherrors.RetryAfter(loginErr); retryAfter > wantDelay+time.Second*2 {
+			t.Fatalf("retry after %s, want %s", retryAfter, wantDelay)
+		}
+
+		f.repo.liftBlocks()
+		if _, loginErr := f.login(ctx, "user@example.com", "wrong"); !autherrors.Is(loginErr, autherrors.UserInvalidPassword) {
+			t.Fatalf("attempt after backoff: %v", loginErr)
+		}
+	}
+}
+
+func TestIPLockoutDoesNotBackOff(t *testing.T) {
+	throttle := testThrottle
+	throttle.BackoffAfter = 1
+	f := newThrottleFixture(t, throttle,
+		entity.AuthUser{ID: 1, Email: "first@example.com"}, entity.AuthUser{ID: 2, Email: "second@example.com"})
+	sharedIP := fromIP("192.0.2.1")
+
+	// Typos of other users behind the same address only count towards IP lockout.
+	for i := 1; i < throttle.IPLockoutThreshold; i++ {
+		_, loginErr := f.login(sharedIP, fmt.Sprintf("typo%d@example.com", i), "wrong")
+		if !autherrors.OneOf(loginErr, autherrors.UserNotExists, autherrors.InvalidCredentials) {
+			t.Fatalf("failure %d: %v", i, loginErr)
+		}
+	}
+	if _, loginErr := f.login(sharedIP, "first@example.com", testPassword); loginErr != nil {
+		t.Fatalf("shared address backs off before IP lockout: %v", loginErr)
+	}
+
+	if _, loginErr := f.login(sharedIP, "typo@example.com", "wrong"); !autherrors.Is(loginErr, autherrors.UserNotExists) {
+		t.Fatalf("failure at IP threshold: %v", loginErr)
+	}
+	_, loginErr := f.login(sharedIP, "second@example.com", testPassword)
+	requireThrottled(t, loginErr, throttle.LockoutDuration-time.Second)
+
+	if _, loginErr := f.login(fromIP("192.0.2.2"), "second@example.com", testPassword); loginErr != nil {
+		t.Fatalf("lockout of IP blocks another address: %v", loginErr)
+	}
+}
+
+func TestLoginReservesAttempt(t *testing.T) {
+	f := newThrottleFixture(t, testThrottle, entity.AuthUser{ID: 1, Email: "user@example.com"})
+
+	var wg sync.WaitGroup
+	results := make(chan error, 20)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			_, loginErr := f.login(fromIP(ip), "user@example.com", "wrong")
+			results <- loginErr
+		}(fmt.Sprintf("10.0.1.%d", i))
+	}
+	wg.Wait()
+	close(results)
+
+	var compared int
+	for loginErr := range results {
+		switch {
+		case autherrors.Is(loginErr, autherrors.UserInvalidPassword):
+			compared++
+		case !autherrors.Is(loginErr, autherrors.TooManyAttempts):
+			t.Fatalf("unexpected error: %v", loginErr)
+		}
+	}
+	if compared != testThrottle.LockoutThreshold {
+		t.Fatalf("%d parallel guesses were compared, want %d", compared, testThrottle.LockoutThreshold)
+	}
+}
+
+func TestVerifyMFAThrottleSurvivesLogin(t *testing.T) {
+	user := entity.AuthUser{ID: 1, Email: "user@example.com"}
+	f := newThrottleFixture(t, testThrottle, user)
+	secret, _ := f.enableTOTP(t, user)
+	ctx := fromIP("10.0.0.1")
+
+	// Password is known, so every wrong code can be followed by new login.
+	for i := 1; i <= testThrottle.LockoutThreshold; i++ {
+		_, verifyErr := f.svc.VerifyMFA(ctx, f.mfaChallenge(t, user.Email), wrongTOTPCode(t, secret), uuid.NewString())
+		if !autherrors.Is(verifyErr, autherrors.InvalidMFACode) {
+			t.Fatalf("wrong code %d: %v", i, verifyErr)
+		}
+	}
+
+	mfaToken := f.mfaChallenge(t, user.Email)
+	_, verifyErr := f.svc.VerifyMFA(ctx, mfaToken, totpCode(t, secret, 1), uuid.NewString())
+	requireThrottled(t, verifyErr, testThrottle.LockoutDuration-time.Second)
+
+	// Refused attempt doesn't use challenge.
+	f.repo.liftBlocks()
+	tokenPair, verifyErr := f.svc.VerifyMFA(ctx, mfaToken, totpCode(t, secret, 1), uuid.NewString())
+	if verifyErr != nil {
+		t.Fatalf("VerifyMFA after lockout: %v", verifyErr)
+	}
+	if tokenPair.Access.Token == "" {
+		t.Fatalf("no access token issued")
+	}
+	if failures := f.repo.failures("mfa:1"); failures != 0 {
+		t.Fatalf("accepted code left %d failures", failures)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/autherrors"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/pkg/securetoken"
+	"time"
+)
+
+func (s *AuthService) VerifyEmail(ctx context.Context, verificationToken string) error {
+	token, consumeErr := s.repo.ConsumeOneTimeToken(ctx, securetoken.Hash(verificationToken),
+		entity.EmailVerificationPurpose, time.Now())
+	if errors.Is(consumeErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), verifyEmailMethod, autherrors.InvalidToken)
+		return autherrors.NewStatusError(autherrors.InvalidToken, errors.New("verification token is unknown, used or expired"))
+	}
+	if consumeErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), verifyEmailMethod, consumeErr)
+		return fmt.Errorf("can't use verification token: %w", consumeErr)
+	}
+
+	if verifyErr := s.repo.SetUserEmailVerified(ctx, token.UserID); verifyErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), verifyEmailMethod, verifyErr)
+		return fmt.Errorf("can't mark email as verified: %w", verifyErr)
+	}
+	if activateErr := s.activatePendingUser(ctx, token.UserID, verifyEmailMethod); activateErr != nil {
+		return activateErr
+	}
+
+	if deleteTokensErr := s.repo.DeleteUserOneTimeTokens(ctx, token.UserID, entity.EmailVerificationPurpose); deleteTokensErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), verifyEmailMethod, deleteTokensErr)
+		return fmt.Errorf("can't delete other verification tokens: %w", deleteTokensErr)
+	}
+
+	return nil
+}
+
+// ResendVerification mails new verification link. Limited mail is refused whether account exists or not.
+func (s *AuthService) ResendVerification(ctx context.Context, email string) error {
+	reserveSendErr := s.reserveMailSend(ctx, entity.EmailVerificationPurpose, email,
+		s.cfg.EmailVerification.MaxSendsPerEmail, s.cfg.EmailVerification.MaxSendsPerIP, resendVerificationMethod)
+	if reserveSendErr != nil {
+		return reserveSendErr
+	}
+
+	user, getUserErr := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(getUserErr, ErrEntityNotFound) {
+		s.logger.Printf(logPattern, requestUUID(ctx), resendVerificationMethod, autherrors.UserNotExists)
+		return nil
+	}
+	if getUserErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), resendVerificationMethod, getUserErr)
+		return fmt.Errorf("can't get user: %w", getUserErr)
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	if deleteTokensErr := s.repo.DeleteUserOneTimeTokens(ctx, user.ID, entity.EmailVerificationPurpose); deleteTokensErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), resendVerificationMethod, deleteTokensErr)
+		return fmt.Errorf("can't delete previous verification tokens: %w", deleteTokensErr)
+	}
+
+	return s.sendVerification(ctx, user, resendVerificationMethod)
+}
+
+func (s *AuthService) sendVerification(ctx context.Context, user entity.AuthUser, method string) error {
+	verificationToken, issueTokenErr := s.issueOneTimeToken(ctx, user.ID,
+		entity.EmailVerificationPurpose, s.cfg.EmailVerification.TokenTTL)
+	if issueTokenErr != nil {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, issueTokenErr)
+		return fmt.Errorf("can't issue verification token: %w", issueTokenErr)
+	}
+
+	s.sendMail(ctx, method, emailVerificationMail(user.Email,
+		linkWithToken(s.cfg.EmailVerification.URL, verificationToken), s.cfg.EmailVerification.TokenTTL))
+
+	return nil
+}
+
+// activatePendingUser finishes registration once email is verified. Users in other statuses stay as they are.
+func (s *AuthService) activatePendingUser(ctx context.Context, userID int64, method string) error {
+	activateErr := s.repo.UpdateUserStatus(ctx, userID, entity.UserPending, entity.UserActive, time.Now())
+	if activateErr != nil && !errors.Is(activateErr, ErrEntityNotFound) {
+		s.logger.Warnf(logPattern, requestUUID(ctx), method, activateErr)
+		return fmt.Errorf("can't activate user: %w", activateErr)
+	}
+
+	return nil
+}
@@ -7,12 +7,37 @@ import (
 	"github.com/DmitySH/go-auth-service/internal/service"
 	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
+	"strings"
 	"time"
 )
 
+const scopeSeparator = " "
+
 type jwtAccessClaims struct {
 	jwt.StandardClaims
-	Email string
+	Email         string
+	EmailVerified bool      `json:"email_verified"`
+	SessionID     uuid.UUID `json:"sid"`
+	Roles         []string  `json:"roles,omitempty"`
+	// Scope is space separated list of permissions, as in OAuth 2.0.
+	Scope string `json:"scope,omitempty"`
+	OrgID int64  `json:"org_id,omitempty"`
+	// ClientID is OAuth client the token was issued to.
+	ClientID string   `json:"client_id,omitempty"`
+	AuthTime int64    `json:"auth_time,omitempty"`
+	AMR      []string `json:"amr,omitempty"`
+	// Principal is set for tokens of client principal only, user tokens omit it.
+	Principal string `json:"principal,omitempty"`
+}
+
+type jwtIDClaims struct {
+	jwt.StandardClaims
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Nonce         string   `json:"nonce,omitempty"`
+	AuthTime      int64    `json:"auth_time"`
+	AMR           []string `json:"amr,omitempty"`
+	ACR           string   `json:"acr,omitempty"`
 }
 
 type jwtRefreshClaims struct {
@@ -21,20 +46,21 @@ type jwtRefreshClaims struct {
 }
 
 type JWTGenerator struct {
-	secretAccessKey  string
-	secretRefreshKey string
-	issuer           string
-	accessTTL        time.Duration
-	refreshTTL       time.Duration
+	accessKeys  *KeyRing
+	refreshKeys *KeyRing
+	issuer      string
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
 }
 
-func (g *JWTGenerator) GenerateTokenPair(userEmail string, sessionUUID uuid.UUID) (entity.TokenPair, error) {
-	accessToken, accessTokenErr := g.generateAccessToken(userEmail)
+func (g *JWTGenerator) GenerateTokenPair(user entity.AuthUser, session entity.Session,
+	access entity.UserAccess) (entity.TokenPair, error) {
+	accessToken, accessTokenErr := g.generateAccessToken(user, session, access)
 	if accessTokenErr != nil {
 		return entity.TokenPair{}, accessTokenErr
 	}
 
-	refreshToken, refreshTokenErr := g.generateRefreshToken(sessionUUID)
+	refreshToken, refreshTokenErr := g.generateRefreshToken(session.ID)
 	if refreshTokenErr != nil {
 		return entity.TokenPair{}, refreshTokenErr
 	}
@@ -45,19 +71,37 @@ func (g *JWTGenerator) GenerateTokenPair(userEmail string, sessionUUID uuid.UUID
 	}, nil
 }
 
-func (g *JWTGenerator) generateAccessToken(userEmail string) (entity.Token, error) {
+func (g *JWTGenerator) generateAccessToken(user entity.AuthUser, session entity.Session,
+	access entity.UserAccess) (entity.Token, error) {
 	expiresAt := time.Now().Local().Add(g.accessTTL).Unix()
 
+	var orgID int64
+	if session.OrgID != nil {
+		orgID = *session.OrgID
+	}
+	var clientID string
+	if session.ClientID != nil {
+		clientID = *session.ClientID
+	}
+
 	claims := &jwtAccessClaims{
-		Email: userEmail,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		SessionID:     session.FamilyID,
+		Roles:         access.Roles,
+		Scope:         strings.Join(access.Permissions, scopeSeparator),
+		OrgID:         orgID,
+		ClientID:      clientID,
+		AuthTime:      session.AuthTime.Unix(),
+		AMR:           strings.Fields(session.AMR),
 		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
 			ExpiresAt: expiresAt,
 			Issuer:    g.issuer,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, signErr := token.SignedString([]byte(g.secretAccessKey))
+	signedToken, signErr := g.accessKeys.sign(claims)
 	if signErr != nil {
 		return entity.Token{}, fmt.Errorf("can't sign access token:%w", signErr)
 	}
@@ -65,6 +109,31 @@ func (g *JWTGenerator) generateAccessToken(userEmail string) (entity.Token, erro
 	return entity.Token{Token: signedToken, ExpiresAt: time.Unix(expiresAt, 0)}, nil
 }
 
+// GenerateClientToken issues access token to OAuth client acting on its own behalf:
+// it has client as subject and no session, so it can't be refreshed.
+func (g *JWTGenerator) GenerateClientToken(clientID string, scope []string, ttl time.Duration) (entity.Token, error) {
+	expiresAt := time.Now().Local().Add(ttl).Unix()
+
+	claims := &jwtAccessClaims{
+		Scope:     strings.Join(scope, scopeSeparator),
+		ClientID:  clientID,
+		Principal: entity.ClientPrincipal,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			ExpiresAt: expiresAt,
+			Issuer:    g.issuer,
+			Subject:   clientID,
+		},
+	}
+
+	signedToken, signErr := g.accessKeys.sign(claims)
+	if signErr != nil {
+		return entity.Token{}, fmt.Errorf("can't sign client token:%w", signErr)
+	}
+
+	return entity.Token{Token: signedToken, ExpiresAt: time.Unix(expiresAt, 0)}, nil
+}
+
 func (g *JWTGenerator) generateRefreshToken(sessionUUID uuid.UUID) (entity.Token, error) {
 	expiresAt := time.Now().Local().Add(g.refreshTTL).Unix()
 
@@ -76,8 +145,7 @@ func (g *JWTGenerator) generateRefreshToken(sessionUUID uuid.UUID) (entity.Token
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, signErr := token.SignedString([]byte(g.secretRefreshKey))
+	signedToken, signErr := g.refreshKeys.sign(claims)
 	if signErr != nil {
 		return entity.Token{}, fmt.Errorf("can't sign refresh token:%w", signErr)
 	}
@@ -85,42 +153,83 @@ func (g *JWTGenerator) generateRefreshToken(sessionUUID uuid.UUID) (entity.Token
 	return entity.Token{Token: signedToken, ExpiresAt: time.Unix(expiresAt, 0)}, nil
 }
 
-func (g *JWTGenerator) ValidateAccessTokenAndGetEmail(signedToken string) (string, error) {
-	token, parseTokenErr := jwt.ParseWithClaims(signedToken, &jwtAccessClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(g.secretAccessKey), nil
+// GenerateIDToken signs OpenID Connect ID token with access token key, so clients verify it by JWKS.
+func (g *JWTGenerator) GenerateIDToken(idClaims entity.IDTokenClaims) (string, error) {
+	claims := &jwtIDClaims{
+		Email:         idClaims.Email,
+		EmailVerified: idClaims.EmailVerified,
+		Nonce:         idClaims.Nonce,
+		AuthTime:      idClaims.AuthTime.Unix(),
+		AMR:           idClaims.AMR,
+		ACR:           idClaims.ACR,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  idClaims.Audience,
+			ExpiresAt: idClaims.ExpiresAt.Unix(),
+			IssuedAt:  idClaims.IssuedAt.Unix(),
+			Issuer:    idClaims.Issuer,
+			Subject:   idClaims.Subject,
 		},
-	)
+	}
+
+	signedToken, signErr := g.accessKeys.sign(claims)
+	if signErr != nil {
+		return "", fmt.Errorf("can't sign id token:%w", signErr)
+	}
+
+	return signedToken, nil
+}
+
+func (g *JWTGenerator) ValidateAccessToken(signedToken string) (entity.AccessClaims, error) {
+	token, parseTokenErr := jwt.ParseWithClaims(signedToken, &jwtAccessClaims{}, g.accessKeys.keyFunc)
 
 	var ve *jwt.ValidationError
 	if errors.As(parseTokenErr, &ve) {
 		if ve.Errors&jwt.ValidationErrorExpired != 0 {
-			return "", errors.New("token has expired")
+			return entity.AccessClaims{}, errors.New("token has expired")
 		}
 	}
 
 	if parseTokenErr != nil {
-		return "", errors.New("can't parse token")
+		return entity.AccessClaims{}, errors.New("can't parse token")
 	}
 
 	claims, ok := token.Claims.(*jwtAccessClaims)
 	if !ok {
-		return "", errors.New("invalid claims passed")
+		return entity.AccessClaims{}, errors.New("invalid claims passed")
 	}
 
 	if claims.Issuer != g.issuer {
-		return "", errors.New("invalid issuer")
+		return entity.AccessClaims{}, errors.New("invalid issuer")
+	}
+
+	tokenID, parseTokenIDErr := uuid.Parse(claims.Id)
+	if parseTokenIDErr != nil {
+		return entity.AccessClaims{}, errors.New("invalid token id")
 	}
 
-	return claims.Email, nil
+	principal := entity.UserPrincipal
+	if claims.Principal != "" {
+		principal = claims.Principal
+	}
+
+	return entity.AccessClaims{
+		TokenID:       tokenID,
+		Principal:     principal,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		SessionID:     claims.SessionID,
+		Roles:         claims.Roles,
+		Permissions:   strings.Fields(claims.Scope),
+		OrgID:         claims.OrgID,
+		ClientID:      claims.ClientID,
+		AuthTime:      time.Unix(claims.AuthTime, 0),
+		AMR:           claims.AMR,
+		ExpiresAt:     time.Unix(claims.ExpiresAt, 0),
+	}, nil
 }
 
 func (g *JWTGenerator) ValidateRefreshTokenAndGetSessionUUID(signedToken string) (uuid.UUID, error) {
-	token, parseTokenErr := jwt.ParseWithClaims(signedToken, &jwtRefreshClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return []byte(g.secretRefreshKey), nil
-		},
-	)
+	token, parseTokenErr := jwt.ParseWithClaims(signedToken, &jwtRefreshClaims{}, g.refreshKeys.keyFunc)
 
 	var ve *jwt.ValidationError
 	if errors.As(parseTokenErr, &ve) {
@@ -145,13 +254,27 @@ func (g *JWTGenerator) ValidateRefreshTokenAndGetSessionUUID(signedToken string)
 	return claims.SessionUUID, nil
 }
 
-func NewJWTGenerator(secretAccessKey, secretRefreshKey, issuer string,
+func (g *JWTGenerator) JWKS() []entity.JSONWebKey {
+	return g.accessKeys.JWKS()
+}
+
+// SigningAlgorithm is algorithm of the key that signs access and ID tokens now.
+func (g *JWTGenerator) SigningAlgorithm() string {
+	signing := g.accessKeys.SigningKey()
+	if signing == nil {
+		return ""
+	}
+
+	return signing.Algorithm()
+}
+
+func NewJWTGenerator(accessKeys, refreshKeys *KeyRing, issuer string,
 	accessTTL, refreshTTL time.Duration) *JWTGenerator {
 	return &JWTGenerator{
-		secretAccessKey:  secretAccessKey,
-		secretRefreshKey: secretRefreshKey,
-		issuer:           issuer,
-		accessTTL:        accessTTL,
-		refreshTTL:       refreshTTL,
+		accessKeys:  accessKeys,
+		refreshKeys: refreshKeys,
+		issuer:      issuer,
+		accessTTL:   accessTTL,
+		refreshTTL:  refreshTTL,
 	}
 }
@@ -0,0 +1,84 @@
+package tokengen
+
+import (
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/golang-jwt/jwt"
+	"sync"
+)
+
+// KeyRing holds the key used for signing new tokens and all keys
+// that are still accepted for verification, selected by kid.
+type KeyRing struct {
+	mu           sync.RWMutex
+	signing      *SigningKey
+	verification map[string]*SigningKey
+}
+
+func NewKeyRing(signing *SigningKey, verification ...*SigningKey) *KeyRing {
+	ring := &KeyRing{}
+	ring.Set(signing, verification...)
+
+	return ring
+}
+
+func (r *KeyRing) Set(signing *SigningKey, verification ...*SigningKey) {
+	keys := make(map[string]*SigningKey, len(verification)+1)
+	for _, key := range verification {
+		keys[key.kid] = key
+	}
+	if signing != nil {
+		keys[signing.kid] = signing
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.signing = signing
+	r.verification = keys
+}
+
+func (r *KeyRing) SigningKey() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.signing
+}
+
+func (r *KeyRing) JWKS() []entity.JSONWebKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jwks := make([]entity.JSONWebKey, 0, len(r.verification))
+	for _, key := range r.verification {
+		if jwk, ok := key.JWK(); ok {
+			jwks = append(jwks, jwk)
+		}
+	}
+
+	return jwks
+}
+
+func (r *KeyRing) sign(claims jwt.Claims) (string, error) {
+	signing := r.SigningKey()
+	if signing == nil {
+		return "", errors.New("no signing key available")
+	}
+
+	return signing.sign(claims)
+}
+
+func (r *KeyRing) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	r.mu.RLock()
+	key, ok := r.verification[kid]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+
+	return key.keyFunc(token)
+}
@@ -0,0 +1,238 @@
+package tokengen
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+)
+
+const (
+	signatureKeyUse = "sig"
+	hmacSecretSize  = 32
+	rsaKeyBits      = 2048
+)
+
+type SigningKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACSigningKey(kid, secret string) *SigningKey {
+	return &SigningKey{
+		kid:       kid,
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// ParsePrivateKeyPEM creates asymmetric signing key. Algorithm is chosen by key type:
+// RSA keys sign with RS256, P-256 keys with ES256 and Ed25519 keys with EdDSA.
+// Empty kid is derived from the public key as RFC 7638 thumbprint.
+func ParsePrivateKeyPEM(kid string, pemBytes []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("key must be PEM encoded")
+	}
+
+	privateKey, parseErr := parsePrivateKey(block.Bytes)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	var signingKey *SigningKey
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		signingKey = &SigningKey{kid: kid, method: jwt.SigningMethodRS256, signKey: key, verifyKey: &key.PublicKey}
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported elliptic curve: %s", key.Curve.Params().Name)
+		}
+		signingKey = &SigningKey{kid: kid, method: jwt.SigningMethodES256, signKey: key, verifyKey: &key.PublicKey}
+	case ed25519.PrivateKey:
+		signingKey = &SigningKey{kid: kid, method: jwt.SigningMethodEdDSA, signKey: key, verifyKey: key.Public()}
+	default:
+		return nil, fmt.Errorf("unsupported private key type: %T", privateKey)
+	}
+
+	if signingKey.kid == "" {
+		thumbprint, thumbprintErr := signingKey.thumbprint()
+		if thumbprintErr != nil {
+			return nil, thumbprintErr
+		}
+		signingKey.kid = thumbprint
+	}
+
+	return signingKey, nil
+}
+
+// GenerateKeyMaterial creates new random key for the algorithm. HMAC secrets are
+// returned base64 encoded, asymmetric keys as PKCS#8 PEM.
+func GenerateKeyMaterial(algorithm string) (string, error) {
+	var privateKey interface{}
+	var generateErr error
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, hmacSecretSize)
+		if _, readErr := rand.Read(secret); readErr != nil {
+			return "", fmt.Errorf("can't generate secret: %w", readErr)
+		}
+		return base64.StdEncoding.EncodeToString(secret), nil
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, generateErr = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case jwt.SigningMethodES256.Alg():
+		privateKey, generateErr = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, privateKey, generateErr = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if generateErr != nil {
+		return "", fmt.Errorf("can't generate private key: %w", generateErr)
+	}
+
+	der, marshalErr := x509.MarshalPKCS8PrivateKey(privateKey)
+	if marshalErr != nil {
+		return "", fmt.Errorf("can't marshal private key: %w", marshalErr)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
+
+// ParseKeyMaterial restores signing key from material created by GenerateKeyMaterial.
+func ParseKeyMaterial(kid, algorithm, material string) (*SigningKey, error) {
+	if algorithm == jwt.SigningMethodHS256.Alg() {
+		secret, decodeErr := base64.StdEncoding.DecodeString(material)
+		if decodeErr != nil {
+			return nil, fmt.Errorf("can't decode secret: %w", decodeErr)
+		}
+		return NewHMACSigningKey(kid, string(secret)), nil
+	}
+
+	key, parseErr := ParsePrivateKeyPEM(kid, []byte(material))
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if key.Algorithm() != algorithm {
+		return nil, fmt.Errorf("key algorithm %s doesn't match %s", key.Algorithm(), algorithm)
+	}
+
+	return key, nil
+}
+
+func parsePrivateKey(der []byte) (interface{}, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("can't parse private key: PKCS#8, PKCS#1 and SEC 1 are supported")
+}
+
+func (k *SigningKey) KID() string {
+	return k.kid
+}
+
+func (k *SigningKey) IsEmpty() bool {
+	secret, isHMAC := k.signKey.([]byte)
+
+	return isHMAC && len(secret) == 0
+}
+
+func (k *SigningKey) Algorithm() string {
+	return k.method.Alg()
+}
+
+// JWK returns public part of the key. Symmetric keys are never exported.
+func (k *SigningKey) JWK() (entity.JSONWebKey, bool) {
+	jwk := entity.JSONWebKey{
+		Use: signatureKeyUse,
+		Kid: k.kid,
+		Alg: k.method.Alg(),
+	}
+
+	switch key := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64URL(key.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		byteLen := (key.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = key.Curve.Params().Name
+		jwk.X = encodeBase64URL(key.X.FillBytes(make([]byte, byteLen)))
+		jwk.Y = encodeBase64URL(key.Y.FillBytes(make([]byte, byteLen)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = encodeBase64URL(key)
+	default:
+		return entity.JSONWebKey{}, false
+	}
+
+	return jwk, true
+}
+
+// thumbprint is SHA-256 of the required public JWK members in lexicographic order (RFC 7638).
+func (k *SigningKey) thumbprint() (string, error) {
+	jwk, ok := k.JWK()
+	if !ok {
+		return "", errors.New("symmetric key has no thumbprint")
+	}
+
+	var members string
+	switch jwk.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, jwk.E, jwk.Kty, jwk.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, jwk.Crv, jwk.Kty, jwk.X)
+	}
+	digest := sha256.Sum256([]byte(members))
+
+	return encodeBase64URL(digest[:]), nil
+}
+
+func (k *SigningKey) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.method, claims)
+	if k.kid != "" {
+		token.Header["kid"] = k.kid
+	}
+
+	return token.SignedString(k.signKey)
+}
+
+func (k *SigningKey) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+	}
+	if kid, ok := token.Header["kid"].(string); ok && k.kid != "" && kid != k.kid {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+
+	return k.verifyKey, nil
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
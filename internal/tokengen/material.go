@@ -0,0 +1,26 @@
+package tokengen
+
+import (
+	"fmt"
+	"github.com/DmitySH/go-auth-service/pkg/secretbox"
+)
+
+// ParseKeyEncryptionKey decodes base64 encoded AES-256 key that encrypts stored signing keys.
+func ParseKeyEncryptionKey(encoded string) ([]byte, error) {
+	kek, parseErr := secretbox.ParseKey(encoded)
+	if parseErr != nil {
+		return nil, fmt.Errorf("can't parse key encryption key: %w", parseErr)
+	}
+
+	return kek, nil
+}
+
+// sealKeyMaterial encrypts material with AES-GCM. Key id is authenticated as additional data,
+// so ciphertext can't be moved to another row.
+func sealKeyMaterial(kek []byte, kid, material string) (string, error) {
+	return secretbox.Seal(kek, kid, material)
+}
+
+func openKeyMaterial(kek []byte, kid, sealed string) (string, error) {
+	return secretbox.Open(kek, kid, sealed)
+}
@@ -0,0 +1,230 @@
+package tokengen
+
+import (
+	"context"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+type KeyStorage interface {
+	GetSigningKeys(ctx context.Context, tokenType string) ([]entity.SigningKey, error)
+	CreateSigningKey(ctx context.Context, key entity.SigningKey, latestActivatesAt time.Time) error
+	EncryptSigningKey(ctx context.Context, id string, material string) error
+	DeleteExpiredSigningKeys(ctx context.Context, tokenType string, expiredAt time.Time) error
+}
+
+type KeyRotationConfig struct {
+	TokenType string
+	Algorithm string
+	// RotationInterval is how long a key signs new tokens.
+	RotationInterval time.Duration
+	// PrepublishPeriod is how long a successor is published for verification before it starts signing.
+	PrepublishPeriod time.Duration
+	// VerificationPeriod is how long a retired key still verifies tokens. Must cover token TTL.
+	VerificationPeriod time.Duration
+	CheckInterval      time.Duration
+	// EncryptionKey encrypts key material at rest.
+	EncryptionKey []byte
+}
+
+// KeyRotator keeps the key ring in sync with stored keys. Every replica runs it:
+// key lifecycle is defined by stored timestamps, so replicas agree on the current key,
+// and concurrent successor creation is resolved by the storage.
+// Legacy keys verify tokens only until VerificationPeriod passes after stored keys took over signing.
+type KeyRotator struct {
+	logger  service.Logger
+	storage KeyStorage
+	ring    *KeyRing
+	legacy  []*SigningKey
+	cfg     KeyRotationConfig
+}
+
+func NewKeyRotator(logger service.Logger, storage KeyStorage, ring *KeyRing,
+	cfg KeyRotationConfig, legacy ...*SigningKey) *KeyRotator {
+	return &KeyRotator{
+		logger:  logger,
+		storage: storage,
+		ring:    ring,
+		legacy:  legacy,
+		cfg:     cfg,
+	}
+}
+
+func (r *KeyRotator) Rotate(ctx context.Context) error {
+	now := time.Now()
+
+	if purgeErr := r.storage.DeleteExpiredSigningKeys(ctx, r.cfg.TokenType, now); purgeErr != nil {
+		return fmt.Errorf("can't purge expired keys: %w", purgeErr)
+	}
+
+	keys, getKeysErr := r.storage.GetSigningKeys(ctx, r.cfg.TokenType)
+	if getKeysErr != nil {
+		return fmt.Errorf("can't get signing keys: %w", getKeysErr)
+	}
+
+	if encryptErr := r.encryptPlaintextKeys(ctx, keys); encryptErr != nil {
+		return encryptErr
+	}
+
+	successor, needed := r.nextKeySchedule(keys, now)
+	if needed {
+		if createErr := r.createKey(ctx, successor, latestKey(keys).ActivatesAt); createErr != nil {
+			return createErr
+		}
+
+		keys, getKeysErr = r.storage.GetSigningKeys(ctx, r.cfg.TokenType)
+		if getKeysErr != nil {
+			return fmt.Errorf("can't get signing keys: %w", getKeysErr)
+		}
+	}
+
+	return r.reload(keys, now)
+}
+
+func (r *KeyRotator) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.cfg.CheckInterval)
+		defer func() {
+			ticker.Stop()
+			r.logger.Printf("stop rotating %s token keys", r.cfg.TokenType)
+		}()
+
+		r.logger.Printf("start rotating %s token keys", r.cfg.TokenType)
+
+		for {
+			select {
+			case <-ticker.C:
+				if rotateErr := r.Rotate(ctx); rotateErr != nil {
+					r.logger.Warnf("can't rotate %s token keys: %v", r.cfg.TokenType, rotateErr)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (r *KeyRotator) nextKeySchedule(keys []entity.SigningKey, now time.Time) (entity.SigningKey, bool) {
+	if len(keys) == 0 {
+		return r.newKeySchedule(now), true
+	}
+
+	latest := latestKey(keys)
+	if latest.RetiresAt.Sub(now) > r.cfg.PrepublishPeriod {
+		return entity.SigningKey{}, false
+	}
+
+	activatesAt := latest.RetiresAt
+	if activatesAt.Before(now) {
+		activatesAt = now
+	}
+
+	return r.newKeySchedule(activatesAt), true
+}
+
+func (r *KeyRotator) newKeySchedule(activatesAt time.Time) entity.SigningKey {
+	retiresAt := activatesAt.Add(r.cfg.RotationInterval)
+
+	return entity.SigningKey{
+		ID:          uuid.NewString(),
+		TokenType:   r.cfg.TokenType,
+		Algorithm:   r.cfg.Algorithm,
+		ActivatesAt: activatesAt,
+		RetiresAt:   retiresAt,
+		ExpiresAt:   retiresAt.Add(r.cfg.VerificationPeriod),
+	}
+}
+
+func (r *KeyRotator) createKey(ctx context.Context, key entity.SigningKey, latestActivatesAt time.Time) error {
+	material, generateErr := GenerateKeyMaterial(key.Algorithm)
+	if generateErr != nil {
+		return generateErr
+	}
+	sealedMaterial, sealErr := sealKeyMaterial(r.cfg.EncryptionKey, key.ID, material)
+	if sealErr != nil {
+		return fmt.Errorf("can't encrypt signing key: %w", sealErr)
+	}
+	key.Material = sealedMaterial
+	key.Encrypted = true
+
+	if createErr := r.storage.CreateSigningKey(ctx, key, latestActivatesAt); createErr != nil {
+		return fmt.Errorf("can't save signing key: %w", createErr)
+	}
+
+	r.logger.Printf("created %s token key %s active from %s", key.TokenType, key.ID, key.ActivatesAt)
+
+	return nil
+}
+
+func (r *KeyRotator) encryptPlaintextKeys(ctx context.Context, keys []entity.SigningKey) error {
+	for i, key := range keys {
+		if key.Encrypted {
+			continue
+		}
+
+		sealedMaterial, sealErr := sealKeyMaterial(r.cfg.EncryptionKey, key.ID, key.Material)
+		if sealErr != nil {
+			return fmt.Errorf("can't encrypt signing key %s: %w", key.ID, sealErr)
+		}
+		if encryptErr := r.storage.EncryptSigningKey(ctx, key.ID, sealedMaterial); encryptErr != nil {
+			return fmt.Errorf("can't save encrypted signing key %s: %w", key.ID, encryptErr)
+		}
+
+		keys[i].Material = sealedMaterial
+		keys[i].Encrypted = true
+	}
+
+	return nil
+}
+
+func (r *KeyRotator) reload(keys []entity.SigningKey, now time.Time) error {
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ActivatesAt.Before(keys[j].ActivatesAt)
+	})
+
+	var signing *SigningKey
+	var verification []*SigningKey
+	if len(keys) == 0 || keys[0].ActivatesAt.Add(r.cfg.VerificationPeriod).After(now) {
+		verification = append(verification, r.legacy...)
+	}
+
+	for _, storedKey := range keys {
+		material, openErr := openKeyMaterial(r.cfg.EncryptionKey, storedKey.ID, storedKey.Material)
+		if openErr != nil {
+			return fmt.Errorf("can't decrypt signing key %s: %w", storedKey.ID, openErr)
+		}
+
+		key, parseErr := ParseKeyMaterial(storedKey.ID, storedKey.Algorithm, material)
+		if parseErr != nil {
+			return fmt.Errorf("can't parse signing key %s: %w", storedKey.ID, parseErr)
+		}
+
+		verification = append(verification, key)
+		if !storedKey.ActivatesAt.After(now) {
+			signing = key
+		}
+	}
+
+	if signing == nil {
+		return fmt.Errorf("no active %s token key", r.cfg.TokenType)
+	}
+
+	r.ring.Set(signing, verification...)
+
+	return nil
+}
+
+func latestKey(keys []entity.SigningKey) entity.SigningKey {
+	var latest entity.SigningKey
+	for _, key := range keys {
+		if key.ActivatesAt.After(latest.ActivatesAt) {
+			latest = key
+		}
+	}
+
+	return latest
+}
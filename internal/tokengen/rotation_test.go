@@ -0,0 +1,167 @@
+package tokengen_test
+
+import (
+	"context"
+	"crypto/rand"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/tokengen"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testRotationInterval   = time.Hour
+	testPrepublishPeriod   = time.Minute * 10
+	testVerificationPeriod = time.Minute * 30
+)
+
+// memoryKeyStorage keeps signing keys in memory. shift moves their schedule to the past
+// as if time went by.
+type memoryKeyStorage struct {
+	mu   sync.Mutex
+	keys []entity.SigningKey
+}
+
+func (s *memoryKeyStorage) GetSigningKeys(_ context.Context, tokenType string) ([]entity.SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []entity.SigningKey
+	for _, key := range s.keys {
+		if key.TokenType == tokenType {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (s *memoryKeyStorage) CreateSigningKey(_ context.Context, key entity.SigningKey, _ time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, key)
+
+	return nil
+}
+
+func (s *memoryKeyStorage) EncryptSigningKey(_ context.Context, id string, material string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		if s.keys[i].ID == id {
+			s.keys[i].Material = material
+			s.keys[i].Encrypted = true
+		}
+	}
+
+	return nil
+}
+
+func (s *memoryKeyStorage) DeleteExpiredSigningKeys(_ context.Context, tokenType string, expiredAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := s.keys[:0]
+	for _, key := range s.keys {
+		if key.TokenType != tokenType || !key.ExpiresAt.Before(expiredAt) {
+			keys = append(keys, key)
+		}
+	}
+	s.keys = keys
+
+	return nil
+}
+
+func (s *memoryKeyStorage) shift(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.keys {
+		s.keys[i].ActivatesAt = s.keys[i].ActivatesAt.Add(-d)
+		s.keys[i].RetiresAt = s.keys[i].RetiresAt.Add(-d)
+		s.keys[i].ExpiresAt = s.keys[i].ExpiresAt.Add(-d)
+	}
+}
+
+func newTestRotator(t *testing.T, storage tokengen.KeyStorage, ring *tokengen.KeyRing) *tokengen.KeyRotator {
+	t.Helper()
+
+	encryptionKey := make([]byte, 32)
+	if _, readErr := rand.Read(encryptionKey); readErr != nil {
+		t.Fatalf("can't generate encryption key: %v", readErr)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return tokengen.NewKeyRotator(logger, storage, ring, tokengen.KeyRotationConfig{
+		TokenType:          "access",
+		Algorithm:          "ES256",
+		RotationInterval:   testRotationInterval,
+		PrepublishPeriod:   testPrepublishPeriod,
+		VerificationPeriod: testVerificationPeriod,
+		EncryptionKey:      encryptionKey,
+	})
+}
+
+func rotate(t *testing.T, rotator *tokengen.KeyRotator) {
+	t.Helper()
+
+	if rotateErr := rotator.Rotate(context.Background()); rotateErr != nil {
+		t.Fatalf("Rotate: %v", rotateErr)
+	}
+}
+
+func issueAccessToken(t *testing.T, generator *tokengen.JWTGenerator) string {
+	t.Helper()
+
+	tokenPair, generateErr := generator.GenerateTokenPair(entity.AuthUser{ID: 1, Email: "user@example.com"},
+		entity.Session{ID: uuid.New(), FamilyID: uuid.New(), AuthTime: time.Now()}, entity.UserAccess{})
+	if generateErr != nil {
+		t.Fatalf("can't generate token pair: %v", generateErr)
+	}
+
+	return tokenPair.Access.Token
+}
+
+func TestRetiredKeyVerifiesDuringOverlap(t *testing.T) {
+	storage := &memoryKeyStorage{}
+	ring := tokengen.NewKeyRing(nil)
+	rotator := newTestRotator(t, storage, ring)
+	generator := tokengen.NewJWTGenerator(ring, tokengen.NewKeyRing(tokengen.NewHMACSigningKey("", "refresh-secret")),
+		"test-auth", time.Hour*24, time.Hour*24)
+
+	rotate(t, rotator)
+	retiredKID := ring.SigningKey().KID()
+	retiredToken := issueAccessToken(t, generator)
+
+	// Retired key has stopped signing, but tokens it signed are still within their TTL.
+	storage.shift(testRotationInterval)
+	rotate(t, rotator)
+	if ring.SigningKey().KID() == retiredKID {
+		t.Fatalf("key %s still signs after its rotation interval", retiredKID)
+	}
+	if _, validateErr := generator.ValidateAccessToken(retiredToken); validateErr != nil {
+		t.Fatalf("token of retired key isn't valid during overlap: %v", validateErr)
+	}
+	if _, validateErr := generator.ValidateAccessToken(issueAccessToken(t, generator)); validateErr != nil {
+		t.Fatalf("token of successor isn't valid: %v", validateErr)
+	}
+
+	storage.shift(testVerificationPeriod + time.Second)
+	rotate(t, rotator)
+	for _, jwk := range ring.JWKS() {
+		if jwk.Kid == retiredKID {
+			t.Fatalf("key %s is published after verification period", retiredKID)
+		}
+	}
+	if _, validateErr := generator.ValidateAccessToken(retiredToken); validateErr == nil {
+		t.Fatalf("token of retired key is valid after verification period")
+	}
+}
@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/service"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	EventIDHeader   = "X-Webhook-Id"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+const maxErrorBodySize = 512
+
+type OutboxStorage interface {
+	ScheduleWebhookDeliveries(ctx context.Context, endpoints []string, limit int, scheduledAt time.Time) error
+	ClaimWebhookDeliveries(ctx context.Context, limit int, now time.Time, leaseUntil time.Time) ([]entity.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery entity.WebhookDelivery) error
+}
+
+type Config struct {
+	Endpoints []string
+	// Secret signs request bodies, receivers verify X-Webhook-Signature with it.
+	Secret       string
+	BatchSize    int
+	PollInterval time.Duration
+	// MaxAttempts is how many times delivery is tried before it becomes dead.
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Lease is how long claimed deliveries are hidden from other dispatchers.
+	// Must exceed time to send the whole batch.
+	Lease time.Duration
+}
+
+type eventBody struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher delivers outbox events to webhook endpoints at least once.
+// Receivers should deduplicate events by X-Webhook-Id.
+type Dispatcher struct {
+	logger  service.Logger
+	storage OutboxStorage
+	client  *http.Client
+	cfg     Config
+}
+
+func NewDispatcher(logger service.Logger, storage OutboxStorage, client *http.Client, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		logger:  logger,
+		storage: storage,
+		client:  client,
+		cfg:     cfg,
+	}
+}
+
+// Dispatch makes one pass: creates deliveries for new events and sends due ones.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	now := time.Now()
+
+	scheduleErr := d.storage.ScheduleWebhookDeliveries(ctx, d.cfg.Endpoints, d.cfg.BatchSize, now)
+	if scheduleErr != nil {
+		return fmt.Errorf("can't schedule deliveries: %w", scheduleErr)
+	}
+
+	deliveries, claimErr := d.storage.ClaimWebhookDeliveries(ctx, d.cfg.BatchSize, now, now.Add(d.cfg.Lease))
+	if claimErr != nil {
+		return fmt.Errorf("can't claim deliveries: %w", claimErr)
+	}
+
+	for _, delivery := range deliveries {
+		sendErr := d.send(ctx, delivery)
+		delivery = d.nextState(delivery, sendErr, time.Now())
+		if sendErr != nil {
+			d.logger.Warnf("can't deliver event %d to %s (attempt %d, %s): %v",
+				delivery.EventID, delivery.Endpoint, delivery.Attempts, delivery.Status, sendErr)
+		}
+
+		if updateErr := d.storage.UpdateWebhookDelivery(ctx, delivery); updateErr != nil {
+			return fmt.Errorf("can't update delivery of event %d: %w", delivery.EventID, updateErr)
+		}
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(d.cfg.PollInterval)
+		defer func() {
+			ticker.Stop()
+			d.logger.Printf("stop dispatching webhooks")
+		}()
+
+		d.logger.Printf("start dispatching webhooks to %d endpoints", len(d.cfg.Endpoints))
+
+		for {
+			select {
+			case <-ticker.C:
+				if dispatchErr := d.Dispatch(ctx); dispatchErr != nil {
+					d.logger.Warnf("can't dispatch webhooks: %v", dispatchErr)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery entity.WebhookDelivery) error {
+	body, marshalErr := json.Marshal(eventBody{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.EventCreatedAt.UTC(),
+		Data:      delivery.Payload,
+	})
+	if marshalErr != nil {
+		return fmt.Errorf("can't marshal event: %w", marshalErr)
+	}
+
+	req, newReqErr := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint, bytes.NewReader(body))
+	if newReqErr != nil {
+		return fmt.Errorf("can't create request: %w", newReqErr)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(d.cfg.Secret, timestamp, body))
+
+	resp, doErr := d.client.Do(req)
+	if doErr != nil {
+		return fmt.Errorf("can't send request: %w", doErr)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("endpoint responded %s: %s", resp.Status, respBody)
+	}
+
+	return nil
+}
+
+// nextState records attempt result. Failed delivery is retried with exponential backoff
+// until it runs out of attempts and becomes dead.
+func (d *Dispatcher) nextState(delivery entity.WebhookDelivery, sendErr error, now time.Time) entity.WebhookDelivery {
+	delivery.Attempts++
+	if sendErr == nil {
+		delivery.Status = entity.DeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return delivery
+	}
+
+	delivery.LastError = sendErr.Error()
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		delivery.Status = entity.DeliveryDead
+		return delivery
+	}
+
+	backoff := d.cfg.BackoffBase
+	for i := 1; i < delivery.Attempts && backoff < d.cfg.BackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > d.cfg.BackoffMax {
+		backoff = d.cfg.BackoffMax
+	}
+	delivery.NextAttemptAt = now.Add(backoff)
+
+	return delivery
+}
+
+// Sign returns signature of webhook request: HMAC-SHA256 of "timestamp.body".
+// Timestamp is signed too, so receivers can reject replayed requests.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
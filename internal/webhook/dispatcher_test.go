@@ -0,0 +1,227 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DmitySH/go-auth-service/internal/entity"
+	"github.com/DmitySH/go-auth-service/internal/webhook"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testSecret      = "webhook-secret"
+	testBackoffBase = time.Minute
+	testMaxAttempts = 3
+)
+
+// memoryOutbox holds deliveries that are already scheduled.
+type memoryOutbox struct {
+	mu         sync.Mutex
+	deliveries []entity.WebhookDelivery
+}
+
+func (o *memoryOutbox) ScheduleWebhookDeliveries(_ context.Context, _ []string, _ int, _ time.Time) error {
+	return nil
+}
+
+func (o *memoryOutbox) ClaimWebhookDeliveries(_ context.Context, limit int, now time.Time,
+	_ time.Time) ([]entity.WebhookDelivery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var claimed []entity.WebhookDelivery
+	for _, delivery := range o.deliveries {
+		if delivery.Status == entity.DeliveryPending && !delivery.NextAttemptAt.After(now) && len(claimed) < limit {
+			claimed = append(claimed, delivery)
+		}
+	}
+
+	return claimed, nil
+}
+
+func (o *memoryOutbox) UpdateWebhookDelivery(_ context.Context, delivery entity.WebhookDelivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.deliveries {
+		if o.deliveries[i].EventID == delivery.EventID && o.deliveries[i].Endpoint == delivery.Endpoint {
+			o.deliveries[i] = delivery
+		}
+	}
+
+	return nil
+}
+
+func (o *memoryOutbox) delivery(t *testing.T) entity.WebhookDelivery {
+	t.Helper()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.deliveries) != 1 {
+		t.Fatalf("outbox has %d deliveries, want 1", len(o.deliveries))
+	}
+
+	return o.deliveries[0]
+}
+
+// skipBackoff makes pending delivery due as if its backoff has passed.
+func (o *memoryOutbox) skipBackoff() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for i := range o.deliveries {
+		o.deliveries[i].NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+func newDispatcher(t *testing.T, handler http.HandlerFunc) (*webhook.Dispatcher, *memoryOutbox) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	outbox := &memoryOutbox{deliveries: []entity.WebhookDelivery{{
+		EventID:        7,
+		Endpoint:       server.URL,
+		Status:         entity.DeliveryPending,
+		NextAttemptAt:  time.Now().Add(-time.Second),
+		EventType:      entity.UserRegisteredEvent,
+		Payload:        []byte(`{"user_id":42,"email":"user@example.com"}`),
+		EventCreatedAt: time.Now(),
+	}}}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	return webhook.NewDispatcher(logger, outbox, server.Client(), webhook.Config{
+		Endpoints:   []string{server.URL},
+		Secret:      testSecret,
+		BatchSize:   10,
+		MaxAttempts: testMaxAttempts,
+		BackoffBase: testBackoffBase,
+		BackoffMax:  time.Hour,
+		Lease:       time.Minute,
+	}), outbox
+}
+
+func dispatch(t *testing.T, dispatcher *webhook.Dispatcher) {
+	t.Helper()
+
+	if dispatchErr := dispatcher.Dispatch(context.Background()); dispatchErr != nil {
+		t.Fatalf("Dispatch: %v", dispatchErr)
+	}
+}
+
+func TestDispatcherSignsRequest(t *testing.T) {
+	var requests atomic.Int32
+	dispatcher, outbox := newDispatcher(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		body, readErr := io.ReadAll(r.Body)
+		if readErr != nil {
+			t.Errorf("can't read body: %v", readErr)
+		}
+		timestamp, parseErr := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+		if parseErr != nil {
+			t.Errorf("invalid timestamp header: %v", parseErr)
+		}
+
+		if got := r.Header.Get(webhook.EventIDHeader); got != "7" {
+			t.Errorf("%s = %q, want 7", webhook.EventIDHeader, got)
+		}
+		if got, want := r.Header.Get(webhook.SignatureHeader), webhook.Sign(testSecret, timestamp, body); got != want {
+			t.Errorf("%s = %q, want %q", webhook.SignatureHeader, got, want)
+		}
+		if webhook.Sign("another-secret", timestamp, body) == r.Header.Get(webhook.SignatureHeader) {
+			t.Errorf("signature doesn't depend on secret")
+		}
+
+		var event struct {
+			ID   int64           `json:"id"`
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+		if unmarshalErr := json.Unmarshal(body, &event); unmarshalErr != nil {
+			t.Errorf("can't decode body: %v", unmarshalErr)
+		}
+		if event.ID != 7 || event.Type != entity.UserRegisteredEvent || string(event.Data) != `{"user_id":42,"email":"user@example.com"}` {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	})
+
+	dispatch(t, dispatcher)
+
+	if requests.Load() != 1 {
+		t.Fatalf("endpoint got %d requests, want 1", requests.Load())
+	}
+	delivery := outbox.delivery(t)
+	if delivery.Status != entity.DeliveryDelivered || delivery.Attempts != 1 || delivery.DeliveredAt == nil {
+		t.Fatalf("delivery isn't marked delivered: %+v", delivery)
+	}
+}
+
+func TestDispatcherRetriesWithBackoff(t *testing.T) {
+	var requests atomic.Int32
+	dispatcher, outbox := newDispatcher(t, func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		before := time.Now()
+		dispatch(t, dispatcher)
+
+		delivery := outbox.delivery(t)
+		if delivery.Status != entity.DeliveryPending || delivery.Attempts != attempt || delivery.LastError == "" {
+			t.Fatalf("attempt %d: delivery isn't left for retry: %+v", attempt, delivery)
+		}
+		wantBackoff := testBackoffBase << (attempt - 1)
+		if backoff := delivery.NextAttemptAt.Sub(before); backoff < wantBackoff || backoff > wantBackoff+time.Second {
+			t.Fatalf("attempt %d: retry in %s, want %s", attempt, backoff, wantBackoff)
+		}
+
+		dispatch(t, dispatcher)
+		if int(requests.Load()) != attempt {
+			t.Fatalf("attempt %d: delivery is retried before backoff has passed", attempt)
+		}
+		outbox.skipBackoff()
+	}
+
+	dispatch(t, dispatcher)
+
+	delivery := outbox.delivery(t)
+	if delivery.Status != entity.DeliveryDelivered || delivery.Attempts != 3 || delivery.LastError != "" {
+		t.Fatalf("delivery isn't marked delivered after retries: %+v", delivery)
+	}
+}
+
+func TestDispatcherMarksDeliveryDead(t *testing.T) {
+	var requests atomic.Int32
+	dispatcher, outbox := newDispatcher(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	for attempt := 1; attempt <= testMaxAttempts+1; attempt++ {
+		dispatch(t, dispatcher)
+		outbox.skipBackoff()
+	}
+
+	if requests.Load() != testMaxAttempts {
+		t.Fatalf("endpoint got %d requests, want %d", requests.Load(), testMaxAttempts)
+	}
+	delivery := outbox.delivery(t)
+	if delivery.Status != entity.DeliveryDead || delivery.Attempts != testMaxAttempts {
+		t.Fatalf("delivery isn't dead after %d attempts: %+v", testMaxAttempts, delivery)
+	}
+}
@@ -0,0 +1,12 @@
+CREATE TABLE "signing_key"
+(
+    "id"           VARCHAR(64) PRIMARY KEY,
+    "token_type"   VARCHAR(16) NOT NULL,
+    "algorithm"    VARCHAR(16) NOT NULL,
+    "material"     TEXT        NOT NULL,
+    "created_at"   TIMESTAMP   NOT NULL DEFAULT now(),
+    "activates_at" TIMESTAMP   NOT NULL,
+    "retires_at"   TIMESTAMP   NOT NULL,
+    "expires_at"   TIMESTAMP   NOT NULL,
+    UNIQUE ("token_type", "activates_at")
+);
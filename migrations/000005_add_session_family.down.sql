@@ -0,0 +1,2 @@
+ALTER TABLE "session" DROP COLUMN rotated_at;
+ALTER TABLE "session" DROP COLUMN family_id;
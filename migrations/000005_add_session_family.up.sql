@@ -0,0 +1,6 @@
+ALTER TABLE "session" ADD COLUMN family_id UUID;
+UPDATE "session" SET family_id = id;
+ALTER TABLE "session" ALTER COLUMN family_id SET NOT NULL;
+ALTER TABLE "session" ADD COLUMN rotated_at TIMESTAMP;
+
+CREATE INDEX ON "session" ("family_id");
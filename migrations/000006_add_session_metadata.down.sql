@@ -0,0 +1,4 @@
+ALTER TABLE "session" DROP COLUMN user_agent;
+ALTER TABLE "session" DROP COLUMN ip_address;
+ALTER TABLE "session" DROP COLUMN last_refreshed_at;
+ALTER TABLE "session" DROP COLUMN created_at;
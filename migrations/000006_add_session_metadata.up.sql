@@ -0,0 +1,6 @@
+ALTER TABLE "session" ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE "session" ADD COLUMN last_refreshed_at TIMESTAMP;
+ALTER TABLE "session" ADD COLUMN ip_address VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE "session" ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX ON "session" ("user_id");
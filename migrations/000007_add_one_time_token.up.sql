@@ -0,0 +1,11 @@
+CREATE TABLE "one_time_token"
+(
+    "token_hash" CHAR(64) PRIMARY KEY,
+    "user_id"    BIGINT      NOT NULL REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "purpose"    VARCHAR(32) NOT NULL,
+    "created_at" TIMESTAMP   NOT NULL DEFAULT now(),
+    "expires_at" TIMESTAMP   NOT NULL,
+    "used_at"    TIMESTAMP
+);
+
+CREATE INDEX ON "one_time_token" ("user_id", "purpose");
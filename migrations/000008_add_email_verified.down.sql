@@ -0,0 +1 @@
+ALTER TABLE "auth_user" DROP COLUMN email_verified;
@@ -0,0 +1,3 @@
+-- Accounts registered before verification was introduced are considered verified.
+ALTER TABLE "auth_user" ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT true;
+ALTER TABLE "auth_user" ALTER COLUMN email_verified SET DEFAULT false;
@@ -0,0 +1,7 @@
+CREATE TABLE "login_attempt"
+(
+    "key"             VARCHAR(192) PRIMARY KEY,
+    "failures"        INT       NOT NULL,
+    "last_failure_at" TIMESTAMP NOT NULL,
+    "blocked_until"   TIMESTAMP
+);
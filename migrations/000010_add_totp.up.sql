@@ -0,0 +1,17 @@
+CREATE TABLE "user_totp"
+(
+    "user_id"        BIGINT PRIMARY KEY REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "secret"         VARCHAR(64) NOT NULL,
+    "confirmed_at"   TIMESTAMP,
+    "last_used_step" BIGINT      NOT NULL DEFAULT 0,
+    "created_at"     TIMESTAMP   NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "recovery_code"
+(
+    "code_hash" CHAR(64) PRIMARY KEY,
+    "user_id"   BIGINT NOT NULL REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "used_at"   TIMESTAMP
+);
+
+CREATE INDEX ON "recovery_code" ("user_id");
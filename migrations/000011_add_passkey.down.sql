@@ -0,0 +1,2 @@
+DROP TABLE "passkey_ceremony";
+DROP TABLE "passkey_credential";
@@ -0,0 +1,25 @@
+CREATE TABLE "passkey_credential"
+(
+    "id"               BYTEA PRIMARY KEY,
+    "user_id"          BIGINT       NOT NULL REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "public_key"       BYTEA        NOT NULL,
+    "attestation_type" VARCHAR(32)  NOT NULL,
+    "aaguid"           BYTEA        NOT NULL,
+    "sign_count"       BIGINT       NOT NULL,
+    "transports"       VARCHAR(128) NOT NULL DEFAULT '',
+    "backup_eligible"  BOOLEAN      NOT NULL,
+    "backup_state"     BOOLEAN      NOT NULL,
+    "created_at"       TIMESTAMP    NOT NULL DEFAULT now(),
+    "last_used_at"     TIMESTAMP
+);
+
+CREATE INDEX ON "passkey_credential" ("user_id");
+
+CREATE TABLE "passkey_ceremony"
+(
+    "id"           UUID PRIMARY KEY,
+    "user_id"      BIGINT REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "purpose"      VARCHAR(32) NOT NULL,
+    "session_data" BYTEA       NOT NULL,
+    "expires_at"   TIMESTAMP   NOT NULL
+);
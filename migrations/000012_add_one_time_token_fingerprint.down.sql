@@ -0,0 +1 @@
+ALTER TABLE "one_time_token" DROP COLUMN fingerprint;
@@ -0,0 +1,4 @@
+DROP TABLE "user_role";
+DROP TABLE "role_permission";
+DROP TABLE "permission";
+DROP TABLE "role";
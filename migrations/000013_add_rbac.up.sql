@@ -0,0 +1,30 @@
+CREATE TABLE "role"
+(
+    "name"        VARCHAR(64) PRIMARY KEY,
+    "description" TEXT      NOT NULL DEFAULT '',
+    "created_at"  TIMESTAMP NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "permission"
+(
+    "name" VARCHAR(128) PRIMARY KEY
+);
+
+CREATE TABLE "role_permission"
+(
+    "role"       VARCHAR(64)  NOT NULL REFERENCES "role" (name) ON DELETE CASCADE,
+    "permission" VARCHAR(128) NOT NULL REFERENCES "permission" (name) ON DELETE CASCADE,
+    PRIMARY KEY ("role", "permission")
+);
+
+CREATE TABLE "user_role"
+(
+    "user_id" BIGINT      NOT NULL REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "role"    VARCHAR(64) NOT NULL REFERENCES "role" (name) ON DELETE CASCADE,
+    PRIMARY KEY ("user_id", "role")
+);
+
+-- The first admin is assigned manually: INSERT INTO user_role VALUES (<user id>, 'admin');
+INSERT INTO "role" (name, description) VALUES ('admin', 'Manages roles of users');
+INSERT INTO "permission" (name) VALUES ('auth:roles:manage');
+INSERT INTO "role_permission" (role, permission) VALUES ('admin', 'auth:roles:manage');
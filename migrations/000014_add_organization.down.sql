@@ -0,0 +1,8 @@
+ALTER TABLE "session" DROP COLUMN org_id;
+
+DROP TABLE "organization_invitation";
+DROP TABLE "organization_member";
+DROP TABLE "organization";
+
+DELETE FROM "role" WHERE name IN ('org_owner', 'org_member');
+DELETE FROM "permission" WHERE name = 'org:members:manage';
@@ -0,0 +1,35 @@
+CREATE TABLE "organization"
+(
+    "id"         BIGSERIAL PRIMARY KEY,
+    "name"       VARCHAR(128) NOT NULL,
+    "created_at" TIMESTAMP    NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "organization_member"
+(
+    "org_id"     BIGINT      NOT NULL REFERENCES "organization" (id) ON DELETE CASCADE,
+    "user_id"    BIGINT      NOT NULL REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "role"       VARCHAR(64) NOT NULL REFERENCES "role" (name),
+    "created_at" TIMESTAMP   NOT NULL DEFAULT now(),
+    PRIMARY KEY ("org_id", "user_id")
+);
+
+CREATE INDEX ON "organization_member" ("user_id");
+
+CREATE TABLE "organization_invitation"
+(
+    "token_hash"  CHAR(64) PRIMARY KEY,
+    "org_id"      BIGINT       NOT NULL REFERENCES "organization" (id) ON DELETE CASCADE,
+    "email"       VARCHAR(128) NOT NULL,
+    "role"        VARCHAR(64)  NOT NULL REFERENCES "role" (name),
+    "invited_by"  BIGINT       NOT NULL REFERENCES "auth_user" (id) ON DELETE CASCADE,
+    "expires_at"  TIMESTAMP    NOT NULL,
+    "accepted_at" TIMESTAMP
+);
+
+ALTER TABLE "session" ADD COLUMN org_id BIGINT REFERENCES "organization" (id) ON DELETE SET NULL;
+
+INSERT INTO "role" (name, description) VALUES ('org_owner', 'Manages members of organization');
+INSERT INTO "role" (name, description) VALUES ('org_member', 'Member of organization');
+INSERT INTO "permission" (name) VALUES ('org:members:manage');
+INSERT INTO "role_permission" (role, permission) VALUES ('org_owner', 'org:members:manage');
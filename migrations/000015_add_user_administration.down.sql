@@ -0,0 +1,3 @@
+DELETE FROM "permission" WHERE name = 'auth:users:manage';
+
+ALTER TABLE "auth_user" DROP COLUMN disabled_at;
@@ -0,0 +1,4 @@
+ALTER TABLE "auth_user" ADD COLUMN disabled_at TIMESTAMP;
+
+INSERT INTO "permission" (name) VALUES ('auth:users:manage');
+INSERT INTO "role_permission" (role, permission) VALUES ('admin', 'auth:users:manage');
@@ -0,0 +1,6 @@
+ALTER TABLE "auth_user" ADD COLUMN disabled_at TIMESTAMP;
+
+UPDATE "auth_user" SET disabled_at = coalesce(status_changed_at, now()) WHERE status IN ('suspended', 'deleted');
+
+ALTER TABLE "auth_user" DROP COLUMN status_changed_at;
+ALTER TABLE "auth_user" DROP COLUMN status;
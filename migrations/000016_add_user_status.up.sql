@@ -0,0 +1,7 @@
+ALTER TABLE "auth_user" ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('pending', 'active', 'suspended', 'deleted'));
+ALTER TABLE "auth_user" ADD COLUMN status_changed_at TIMESTAMP;
+
+UPDATE "auth_user" SET status = 'suspended', status_changed_at = disabled_at WHERE disabled_at IS NOT NULL;
+
+ALTER TABLE "auth_user" DROP COLUMN disabled_at;
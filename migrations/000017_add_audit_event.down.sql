@@ -0,0 +1,3 @@
+DELETE FROM "permission" WHERE name = 'auth:audit:read';
+
+DROP TABLE "audit_event";
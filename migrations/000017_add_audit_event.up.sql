@@ -0,0 +1,22 @@
+CREATE TABLE "audit_event"
+(
+    "id"              BIGSERIAL PRIMARY KEY,
+    "event_type"      VARCHAR(64)  NOT NULL,
+    "actor"           VARCHAR(128) NOT NULL,
+    "subject_user_id" BIGINT       NOT NULL DEFAULT 0,
+    "details"         TEXT         NOT NULL DEFAULT '',
+    "ip_address"      VARCHAR(45)  NOT NULL DEFAULT '',
+    "user_agent"      TEXT         NOT NULL DEFAULT '',
+    "request_id"      VARCHAR(36)  NOT NULL DEFAULT '',
+    "outcome"         TEXT         NOT NULL,
+    "created_at"      TIMESTAMP    NOT NULL,
+    "prev_hash"       CHAR(64)     NOT NULL,
+    "hash"            CHAR(64)     NOT NULL UNIQUE
+);
+
+-- Users are not referenced: events must outlive deleted accounts.
+CREATE INDEX ON "audit_event" ("subject_user_id", "created_at");
+CREATE INDEX ON "audit_event" ("created_at");
+
+INSERT INTO "permission" (name) VALUES ('auth:audit:read');
+INSERT INTO "role_permission" (role, permission) VALUES ('admin', 'auth:audit:read');
@@ -0,0 +1,25 @@
+CREATE TABLE "outbox_event"
+(
+    "id"           BIGSERIAL PRIMARY KEY,
+    "event_type"   VARCHAR(64) NOT NULL,
+    "payload"      JSONB       NOT NULL,
+    "created_at"   TIMESTAMP   NOT NULL,
+    "scheduled_at" TIMESTAMP   NULL
+);
+
+CREATE INDEX ON "outbox_event" ("id") WHERE "scheduled_at" IS NULL;
+
+CREATE TABLE "webhook_delivery"
+(
+    "event_id"        BIGINT       NOT NULL REFERENCES "outbox_event" ("id") ON DELETE CASCADE,
+    "endpoint"        VARCHAR(512) NOT NULL,
+    "status"          VARCHAR(16)  NOT NULL DEFAULT 'pending'
+        CHECK ("status" IN ('pending', 'delivered', 'dead')),
+    "attempts"        INT          NOT NULL DEFAULT 0,
+    "next_attempt_at" TIMESTAMP    NOT NULL,
+    "last_error"      TEXT         NOT NULL DEFAULT '',
+    "delivered_at"    TIMESTAMP    NULL,
+    PRIMARY KEY ("event_id", "endpoint")
+);
+
+CREATE INDEX ON "webhook_delivery" ("next_attempt_at") WHERE "status" = 'pending';
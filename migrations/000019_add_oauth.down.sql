@@ -0,0 +1,11 @@
+DELETE FROM "permission" WHERE name = 'auth:clients:manage';
+
+ALTER TABLE "session" DROP COLUMN "client_id", DROP COLUMN "scope";
+
+DROP TABLE "oauth_authorization_code";
+
+DROP TABLE "oauth_consent";
+
+DROP TABLE "oauth_client_redirect_uri";
+
+DROP TABLE "oauth_client";
@@ -0,0 +1,43 @@
+CREATE TABLE "oauth_client"
+(
+    "id"          VARCHAR(36)  PRIMARY KEY,
+    "name"        VARCHAR(128) NOT NULL,
+    "secret_hash" CHAR(64)     NULL,
+    "created_at"  TIMESTAMP    NOT NULL DEFAULT now()
+);
+
+CREATE TABLE "oauth_client_redirect_uri"
+(
+    "client_id"    VARCHAR(36)   NOT NULL REFERENCES "oauth_client" ("id") ON DELETE CASCADE,
+    "redirect_uri" VARCHAR(2048) NOT NULL,
+    PRIMARY KEY ("client_id", "redirect_uri")
+);
+
+CREATE TABLE "oauth_consent"
+(
+    "user_id"    BIGINT      NOT NULL REFERENCES "auth_user" ("id") ON DELETE CASCADE,
+    "client_id"  VARCHAR(36) NOT NULL REFERENCES "oauth_client" ("id") ON DELETE CASCADE,
+    "scope"      TEXT        NOT NULL,
+    "granted_at" TIMESTAMP   NOT NULL,
+    PRIMARY KEY ("user_id", "client_id")
+);
+
+CREATE TABLE "oauth_authorization_code"
+(
+    "code_hash"      CHAR(64)      PRIMARY KEY,
+    "client_id"      VARCHAR(36)   NOT NULL REFERENCES "oauth_client" ("id") ON DELETE CASCADE,
+    "user_id"        BIGINT        NOT NULL REFERENCES "auth_user" ("id") ON DELETE CASCADE,
+    "redirect_uri"   VARCHAR(2048) NOT NULL,
+    "scope"          TEXT          NOT NULL,
+    "code_challenge" VARCHAR(128)  NOT NULL,
+    "expires_at"     TIMESTAMP     NOT NULL
+);
+
+CREATE INDEX ON "oauth_authorization_code" ("expires_at");
+
+ALTER TABLE "session"
+    ADD COLUMN "client_id" VARCHAR(36) NULL REFERENCES "oauth_client" ("id") ON DELETE CASCADE,
+    ADD COLUMN "scope"     TEXT        NOT NULL DEFAULT '';
+
+INSERT INTO "permission" (name) VALUES ('auth:clients:manage');
+INSERT INTO "role_permission" (role, permission) VALUES ('admin', 'auth:clients:manage');
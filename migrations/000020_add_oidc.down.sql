@@ -0,0 +1,3 @@
+ALTER TABLE "oauth_authorization_code" DROP COLUMN "nonce", DROP COLUMN "auth_time", DROP COLUMN "amr";
+
+ALTER TABLE "session" DROP COLUMN "auth_time", DROP COLUMN "amr";
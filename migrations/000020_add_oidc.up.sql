@@ -0,0 +1,12 @@
+ALTER TABLE "session"
+    ADD COLUMN "auth_time" TIMESTAMP NULL,
+    ADD COLUMN "amr"       TEXT      NOT NULL DEFAULT '';
+
+UPDATE "session" SET "auth_time" = "created_at";
+
+ALTER TABLE "session" ALTER COLUMN "auth_time" SET NOT NULL;
+
+ALTER TABLE "oauth_authorization_code"
+    ADD COLUMN "nonce"     VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN "auth_time" TIMESTAMP    NOT NULL DEFAULT now(),
+    ADD COLUMN "amr"       TEXT         NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE "oauth_client" DROP COLUMN "scope";
@@ -0,0 +1 @@
+ALTER TABLE "oauth_client" ADD COLUMN "scope" TEXT NOT NULL DEFAULT '';
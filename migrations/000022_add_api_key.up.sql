@@ -0,0 +1,13 @@
+CREATE TABLE "api_key"
+(
+    "id"           UUID         PRIMARY KEY,
+    "user_id"      BIGINT       NOT NULL REFERENCES "auth_user" ("id") ON DELETE CASCADE,
+    "name"         VARCHAR(128) NOT NULL,
+    "key_hash"     CHAR(64)     NOT NULL UNIQUE,
+    "scope"        TEXT         NOT NULL,
+    "created_at"   TIMESTAMP    NOT NULL,
+    "expires_at"   TIMESTAMP    NULL,
+    "last_used_at" TIMESTAMP    NULL
+);
+
+CREATE INDEX ON "api_key" ("user_id");
@@ -0,0 +1,16 @@
+CREATE TABLE "device_authorization"
+(
+    "device_code_hash" CHAR(64)    PRIMARY KEY,
+    "user_code"        VARCHAR(16) NOT NULL UNIQUE,
+    "client_id"        VARCHAR(36) NOT NULL REFERENCES "oauth_client" ("id") ON DELETE CASCADE,
+    "scope"            TEXT        NOT NULL,
+    "status"           VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK ("status" IN ('pending', 'approved', 'denied')),
+    "user_id"          BIGINT      NULL REFERENCES "auth_user" ("id") ON DELETE CASCADE,
+    "auth_time"        TIMESTAMP   NULL,
+    "amr"              TEXT        NOT NULL DEFAULT '',
+    "poll_interval"    INT         NOT NULL,
+    "last_polled_at"   TIMESTAMP   NULL,
+    "expires_at"       TIMESTAMP   NOT NULL
+);
+
+CREATE INDEX ON "device_authorization" ("expires_at");
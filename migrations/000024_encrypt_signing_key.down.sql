@@ -0,0 +1,2 @@
+ALTER TABLE "signing_key"
+    DROP COLUMN "encrypted";
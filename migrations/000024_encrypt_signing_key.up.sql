@@ -0,0 +1,2 @@
+ALTER TABLE "signing_key"
+    ADD COLUMN "encrypted" BOOLEAN NOT NULL DEFAULT false;
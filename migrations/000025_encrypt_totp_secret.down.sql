@@ -0,0 +1,6 @@
+-- Encrypted secrets can't be read without the key, so their users enroll again.
+DELETE FROM "user_totp" WHERE "encrypted";
+
+ALTER TABLE "user_totp"
+    DROP COLUMN "encrypted",
+    ALTER COLUMN "secret" TYPE VARCHAR(64);
@@ -0,0 +1,3 @@
+ALTER TABLE "user_totp"
+    ALTER COLUMN "secret" TYPE TEXT,
+    ADD COLUMN "encrypted" BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,75 @@
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// KeySize is size of AES-256 key that encrypts secrets at rest.
+const KeySize = 32
+
+// ParseKey decodes base64 encoded AES-256 key.
+func ParseKey(encoded string) ([]byte, error) {
+	key, decodeErr := base64.StdEncoding.DecodeString(encoded)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("can't decode encryption key: %w", decodeErr)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes", KeySize)
+	}
+
+	return key, nil
+}
+
+// Seal encrypts secret with AES-GCM. Owner of the secret, such as row id, is authenticated
+// as additional data, so ciphertext can't be moved to another row.
+func Seal(key []byte, owner string, secret string) (string, error) {
+	aead, createAEADErr := newAEAD(key)
+	if createAEADErr != nil {
+		return "", createAEADErr
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, readErr := rand.Read(nonce); readErr != nil {
+		return "", fmt.Errorf("can't generate nonce: %w", readErr)
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), []byte(owner))), nil
+}
+
+// Open decrypts secret sealed for owner.
+func Open(key []byte, owner string, sealed string) (string, error) {
+	aead, createAEADErr := newAEAD(key)
+	if createAEADErr != nil {
+		return "", createAEADErr
+	}
+
+	ciphertext, decodeErr := base64.StdEncoding.DecodeString(sealed)
+	if decodeErr != nil {
+		return "", fmt.Errorf("can't decode sealed secret: %w", decodeErr)
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return "", errors.New("sealed secret is too short")
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	secret, openErr := aead.Open(nil, nonce, ciphertext, []byte(owner))
+	if openErr != nil {
+		return "", fmt.Errorf("can't decrypt secret: %w", openErr)
+	}
+
+	return string(secret), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, createCipherErr := aes.NewCipher(key)
+	if createCipherErr != nil {
+		return nil, fmt.Errorf("can't create cipher: %w", createCipherErr)
+	}
+
+	return cipher.NewGCM(block)
+}
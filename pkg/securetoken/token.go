@@ -0,0 +1,57 @@
+package securetoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+const DefaultSize = 32
+
+// Generate returns URL-safe random token of size bytes.
+func Generate(size int) (string, error) {
+	b := make([]byte, size)
+	if _, readErr := rand.Read(b); readErr != nil {
+		return "", fmt.Errorf("can't read random bytes: %w", readErr)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns hex encoded SHA-256 of token. High entropy tokens don't need slow hashing.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateNumericCode returns random code of digits decimal digits, that is easy to type.
+// Its entropy is low, so it must be short-lived and attempts must be limited.
+func GenerateNumericCode(digits int) (string, error) {
+	upper := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, randErr := rand.Int(rand.Reader, upper)
+	if randErr != nil {
+		return "", fmt.Errorf("can't generate random number: %w", randErr)
+	}
+
+	return fmt.Sprintf("%0*d", digits, n.Int64()), nil
+}
+
+// GenerateCode returns random code of length characters of alphabet. Like numeric code,
+// it is meant to be typed by user, so it must be short-lived.
+func GenerateCode(alphabet string, length int) (string, error) {
+	code := make([]byte, length)
+	upper := big.NewInt(int64(len(alphabet)))
+	for i := range code {
+		n, randErr := rand.Int(rand.Reader, upper)
+		if randErr != nil {
+			return "", fmt.Errorf("can't generate random number: %w", randErr)
+		}
+		code[i] = alphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,64 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"github.com/DmitySH/go-auth-service/pkg/totp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is SHA1 seed of RFC 6238, appendix B.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// RFC 6238 lists 8 digit codes, 6 digit code is their last 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{unix: 59, code: "287082"},
+	{unix: 1111111109, code: "081804"},
+	{unix: 1111111111, code: "050471"},
+	{unix: 1234567890, code: "005924"},
+	{unix: 2000000000, code: "279037"},
+	{unix: 20000000000, code: "353130"},
+}
+
+func TestCodeMatchesRFC6238(t *testing.T) {
+	for _, vector := range rfc6238Vectors {
+		code, codeErr := totp.Code(rfc6238Secret, totp.Step(time.Unix(vector.unix, 0)))
+		if codeErr != nil {
+			t.Fatalf("Code at %d: %v", vector.unix, codeErr)
+		}
+		if code != vector.code {
+			t.Errorf("code at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	step := totp.Step(at)
+
+	for _, shift := range []int64{-1, 0, 1} {
+		code, _ := totp.Code(rfc6238Secret, step+shift)
+		matched, valid := totp.Validate(rfc6238Secret, code, at, 1)
+		if !valid || matched != step+shift {
+			t.Errorf("code of step %+d: matched %d, valid %t", shift, matched, valid)
+		}
+	}
+
+	for _, shift := range []int64{-2, 2} {
+		code, _ := totp.Code(rfc6238Secret, step+shift)
+		if _, valid := totp.Validate(rfc6238Secret, code, at, 1); valid {
+			t.Errorf("code of step %+d is accepted outside skew", shift)
+		}
+	}
+
+	if _, valid := totp.Validate(rfc6238Secret, "50471", at, 1); valid {
+		t.Errorf("code of wrong length is accepted")
+	}
+	if _, valid := totp.Validate(strings.ToLower(rfc6238Secret), "050471", at, 0); !valid {
+		t.Errorf("secret typed in lowercase isn't accepted")
+	}
+}